@@ -0,0 +1,122 @@
+package graphqlapi
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/yourusername/product-service/internal/database"
+)
+
+// pagedFields are the fields returning a page of products. Their selection is
+// counted once per product the page can hold.
+var pagedFields = map[string]bool{"products": true}
+
+// Limits bound how much work a single query may ask for. Zero disables a limit.
+type Limits struct {
+	// MaxComplexity caps the number of fields a query can resolve, counting
+	// the selection of a page once for every product the page can hold
+	MaxComplexity int
+
+	// MaxDepth caps how deeply selections can be nested
+	MaxDepth int
+}
+
+// measurer computes the cost of one operation of a validated document
+type measurer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// measure returns the complexity and depth of the operation that will run.
+// Introspection fields are free, so tools can always load the schema.
+func measure(doc *ast.Document, operationName string, variables map[string]interface{}) (complexity, depth int) {
+	m := measurer{fragments: make(map[string]*ast.FragmentDefinition), variables: make(map[string]interface{})}
+
+	var operation *ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			m.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+	if operation == nil {
+		return 0, 0
+	}
+
+	for _, definition := range operation.VariableDefinitions {
+		if definition.DefaultValue != nil {
+			m.variables[definition.Variable.Name.Value] = definition.DefaultValue.GetValue()
+		}
+	}
+	for name, value := range variables {
+		m.variables[name] = value
+	}
+
+	return m.selectionSet(operation.SelectionSet)
+}
+
+func (m measurer) selectionSet(set *ast.SelectionSet) (complexity, depth int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, selection := range set.Selections {
+		var c, d int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			c, d = m.selectionSet(selection.SelectionSet)
+			if pagedFields[selection.Name.Value] {
+				c *= m.pageSize(selection)
+			}
+			c, d = c+1, d+1
+		case *ast.InlineFragment:
+			c, d = m.selectionSet(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			if fragment, ok := m.fragments[selection.Name.Value]; ok {
+				c, d = m.selectionSet(fragment.SelectionSet)
+			}
+		}
+		complexity += c
+		if d > depth {
+			depth = d
+		}
+	}
+
+	return complexity, depth
+}
+
+// pageSize returns the limit a paged field asks for
+func (m measurer) pageSize(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "limit" {
+			continue
+		}
+		var value interface{} = argument.Value.GetValue()
+		if variable, ok := argument.Value.(*ast.Variable); ok {
+			value = m.variables[variable.Name.Value]
+		}
+		switch value := value.(type) {
+		case string:
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				return n
+			}
+		case float64:
+			if value > 0 {
+				return int(value)
+			}
+		case int:
+			if value > 0 {
+				return value
+			}
+		}
+	}
+	return database.DefaultPageSize
+}
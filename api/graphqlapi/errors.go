@@ -0,0 +1,67 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/requestid"
+)
+
+// Error is a GraphQL error carrying one of the stable problem codes the REST
+// API uses, reported in the error's extensions
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Extensions implements gqlerrors.ExtendedError
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+// resolveError translates a repository error into a GraphQL error, following
+// the same classification as the REST API's problem responses. Errors the
+// repository does not classify are logged and reported as internal.
+func resolveError(ctx context.Context, err error) error {
+	var gqlErr *Error
+	switch {
+	case errors.As(err, &gqlErr):
+		return gqlErr
+	case errors.Is(err, database.ErrNotFound):
+		return &Error{Code: problem.CodeNotFound, Message: "Product not found"}
+	case errors.Is(err, database.ErrConflict):
+		return &Error{Code: problem.CodeConflict, Message: "A product with this ID already exists"}
+	case errors.Is(err, database.ErrPreconditionFailed):
+		return &Error{Code: problem.CodePreconditionFailed, Message: "Product was modified concurrently"}
+	case errors.Is(err, database.ErrInsufficientInventory):
+		return &Error{Code: problem.CodeInsufficientInventory, Message: err.Error()}
+	case errors.Is(err, database.ErrUnknownTenant):
+		return &Error{Code: problem.CodeUnknownTenant, Message: err.Error()}
+	case errors.Is(err, database.ErrQuotaExceeded):
+		return &Error{Code: problem.CodeQuotaExceeded, Message: err.Error()}
+	case errors.Is(err, database.ErrNotSupported):
+		return &Error{Code: problem.CodeNotSupported, Message: err.Error()}
+	case errors.Is(err, database.ErrInvalidCursor):
+		return &Error{Code: problem.CodeInvalidCursor, Message: err.Error()}
+	case errors.Is(err, database.ErrUnavailable):
+		return &Error{Code: problem.CodeUnavailable, Message: "Storage is temporarily unavailable"}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Code: problem.CodeTimeout, Message: "Request timed out"}
+	case errors.Is(err, context.Canceled):
+		return &Error{Code: problem.CodeUnavailable, Message: "Request was cancelled"}
+	default:
+		requestid.Printf(ctx, "Internal error: %v", err)
+		return &Error{Code: problem.CodeInternal, Message: "Internal server error"}
+	}
+}
+
+// invalidArgument reports arguments that failed validation
+func invalidArgument(err error) error {
+	return &Error{Code: problem.CodeValidationFailed, Message: err.Error()}
+}
@@ -0,0 +1,160 @@
+package graphqlapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/yourusername/product-service/api/middleware"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/requestid"
+)
+
+// CodeQueryTooComplex is the error code of a query rejected by Limits
+const CodeQueryTooComplex = "query_too_complex"
+
+// Request is a GraphQL request body
+type Request struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler serves GraphQL requests
+type Handler struct {
+	repo   database.ProductRepository
+	schema graphql.Schema
+	limits Limits
+}
+
+// NewHandler creates a GraphQL handler over repo
+func NewHandler(repo database.ProductRepository, limits Limits) *Handler {
+	schema, err := NewSchema(repo)
+	if err != nil {
+		// The schema is fixed, so this is a programming error
+		panic(fmt.Sprintf("graphqlapi: invalid schema: %v", err))
+	}
+
+	return &Handler{repo: repo, schema: schema, limits: limits}
+}
+
+// Serve godoc
+// @Summary Run a GraphQL query
+// @Description Run a GraphQL query or mutation against the product schema. Queries can also be sent with GET
+// @Description using the query, operationName and variables (JSON) parameters. Queries over the complexity or
+// @Description depth limit are rejected with 400 before they run; errors while running are reported in "errors"
+// @Description with a stable "code" extension, next to whatever "data" could be resolved.
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Param request body Request true "GraphQL request"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 422 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /graphql [post]
+func (h *Handler) Serve(c *gin.Context) {
+	var req Request
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				reject(c, http.StatusBadRequest, "invalid variables: "+err.Error(), problem.CodeValidationFailed)
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		reject(c, http.StatusBadRequest, err.Error(), problem.CodeValidationFailed)
+		return
+	}
+	if req.Query == "" {
+		reject(c, http.StatusBadRequest, "query is required", problem.CodeValidationFailed)
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		respond(c, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+	if validation := graphql.ValidateDocument(&h.schema, doc, nil); !validation.IsValid {
+		respond(c, http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	// Mutations must not be sent with GET, which caches and proxies treat as safe
+	if c.Request.Method == http.MethodGet && isMutation(doc, req.OperationName) {
+		reject(c, http.StatusMethodNotAllowed, "mutations must be sent with POST", "")
+		return
+	}
+
+	if isMutation(doc, req.OperationName) && !middleware.Authorized(c, middleware.RoleCatalogWrite) {
+		reject(c, http.StatusForbidden, "The catalog:write role is required for mutations", problem.CodeForbidden)
+		return
+	}
+
+	complexity, depth := measure(doc, req.OperationName, req.Variables)
+	if h.limits.MaxComplexity > 0 && complexity > h.limits.MaxComplexity {
+		reject(c, http.StatusBadRequest, fmt.Sprintf("query complexity %d exceeds the limit of %d", complexity, h.limits.MaxComplexity), CodeQueryTooComplex)
+		return
+	}
+	if h.limits.MaxDepth > 0 && depth > h.limits.MaxDepth {
+		reject(c, http.StatusBadRequest, fmt.Sprintf("query depth %d exceeds the limit of %d", depth, h.limits.MaxDepth), CodeQueryTooComplex)
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoader(c.Request.Context(), h.repo),
+	})
+	respond(c, http.StatusOK, result)
+}
+
+// reject responds with a single GraphQL error and no data
+func reject(c *gin.Context, status int, message, code string) {
+	err := gqlerrors.FormattedError{Message: message}
+	if code != "" {
+		err.Extensions = map[string]interface{}{"code": code}
+	}
+	respond(c, status, &graphql.Result{Errors: []gqlerrors.FormattedError{err}})
+	c.Abort()
+}
+
+// respond sends a result, naming the request in its extensions when it has
+// errors so they can be found in the logs
+func respond(c *gin.Context, status int, result *graphql.Result) {
+	if id := requestid.FromContext(c.Request.Context()); id != "" && result.HasErrors() {
+		if result.Extensions == nil {
+			result.Extensions = make(map[string]interface{})
+		}
+		result.Extensions["requestId"] = id
+	}
+	c.JSON(status, result)
+}
+
+// isMutation reports whether the operation that will run is a mutation
+func isMutation(doc *ast.Document, operationName string) bool {
+	for _, definition := range doc.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (operation.Name != nil && operation.Name.Value == operationName) {
+			return operation.Operation == ast.OperationTypeMutation
+		}
+	}
+	return false
+}
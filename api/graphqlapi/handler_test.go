@@ -0,0 +1,212 @@
+package graphqlapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/requestid"
+)
+
+// countingRepository counts the availability reads that reach the repository
+type countingRepository struct {
+	database.ProductRepository
+	single, batch int32
+}
+
+func (r *countingRepository) CheckProductAvailability(ctx context.Context, id string) (models.ProductAvailability, error) {
+	atomic.AddInt32(&r.single, 1)
+	return r.ProductRepository.CheckProductAvailability(ctx, id)
+}
+
+func (r *countingRepository) CheckProductsAvailability(ctx context.Context, ids []string) (map[string]models.ProductAvailability, error) {
+	atomic.AddInt32(&r.batch, 1)
+	return r.ProductRepository.CheckProductsAvailability(ctx, ids)
+}
+
+// graphqlResponse is a decoded GraphQL response
+type graphqlResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestGraphQL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &countingRepository{ProductRepository: database.NewInMemoryRepository()}
+	handler := NewHandler(repo, Limits{MaxComplexity: 500, MaxDepth: 5})
+
+	router := gin.New()
+	router.GET("/graphql", handler.Serve)
+	router.POST("/graphql", handler.Serve)
+
+	post := func(query string, variables map[string]interface{}) (*httptest.ResponseRecorder, graphqlResponse) {
+		body, _ := json.Marshal(Request{Query: query, Variables: variables})
+		req, _ := http.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp graphqlResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	var ids []string
+
+	// Test creating products through a mutation
+	t.Run("CreateProduct", func(t *testing.T) {
+		for _, name := range []string{"Alpha", "Beta", "Gamma"} {
+			w, resp := post(`mutation($input: ProductInput!) { createProduct(input: $input) { id name version } }`, map[string]interface{}{
+				"input": map[string]interface{}{"name": name, "description": "Test Description", "price": 2.5, "inventoryCount": 4},
+			})
+			assert.Equal(t, http.StatusOK, w.Code)
+			require.Empty(t, resp.Errors)
+			created := resp.Data["createProduct"].(map[string]interface{})
+			assert.Equal(t, name, created["name"])
+			assert.Equal(t, float64(1), created["version"])
+			ids = append(ids, created["id"].(string))
+		}
+
+		_, resp := post(`mutation { createProduct(input: {name: "", description: "x", price: 1, inventoryCount: 1}) { id } }`, nil)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, problem.CodeValidationFailed, resp.Errors[0].Extensions["code"])
+	})
+
+	// Test that availability for a page of products is read with one call
+	t.Run("BatchedAvailability", func(t *testing.T) {
+		atomic.StoreInt32(&repo.single, 0)
+		atomic.StoreInt32(&repo.batch, 0)
+
+		_, resp := post(`{ products(limit: 10, sort: "name") { items { name availability { availableToPromise } } nextCursor } }`, nil)
+		require.Empty(t, resp.Errors)
+		page := resp.Data["products"].(map[string]interface{})
+		items := page["items"].([]interface{})
+		require.Len(t, items, 3)
+		assert.Nil(t, page["nextCursor"])
+		for _, item := range items {
+			availability := item.(map[string]interface{})["availability"].(map[string]interface{})
+			assert.Equal(t, float64(4), availability["availableToPromise"])
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&repo.batch))
+		assert.Equal(t, int32(0), atomic.LoadInt32(&repo.single))
+	})
+
+	// Test filtering and cursor pagination
+	t.Run("Pagination", func(t *testing.T) {
+		_, resp := post(`query($cursor: String) { products(limit: 2, cursor: $cursor, filter: {namePrefix: "A"}) { items { name } nextCursor } }`, nil)
+		require.Empty(t, resp.Errors)
+		items := resp.Data["products"].(map[string]interface{})["items"].([]interface{})
+		require.Len(t, items, 1)
+		assert.Equal(t, "Alpha", items[0].(map[string]interface{})["name"])
+
+		_, resp = post(`{ products(limit: 2, sort: "name") { items { name } nextCursor } }`, nil)
+		cursor := resp.Data["products"].(map[string]interface{})["nextCursor"]
+		require.NotNil(t, cursor)
+		_, resp = post(`query($cursor: String) { products(limit: 2, sort: "name", cursor: $cursor) { items { name } nextCursor } }`, map[string]interface{}{"cursor": cursor})
+		require.Empty(t, resp.Errors)
+		items = resp.Data["products"].(map[string]interface{})["items"].([]interface{})
+		require.Len(t, items, 1)
+		assert.Equal(t, "Gamma", items[0].(map[string]interface{})["name"])
+
+		_, resp = post(`{ products(limit: 0) { items { name } } }`, nil)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, problem.CodeValidationFailed, resp.Errors[0].Extensions["code"])
+	})
+
+	// Test partial updates, version checks and deletes
+	t.Run("UpdateAndDelete", func(t *testing.T) {
+		_, resp := post(`mutation($id: ID!) { updateProduct(id: $id, version: 1, input: {price: 9.5}) { name price version } }`, map[string]interface{}{"id": ids[0]})
+		require.Empty(t, resp.Errors)
+		updated := resp.Data["updateProduct"].(map[string]interface{})
+		assert.Equal(t, "Alpha", updated["name"])
+		assert.Equal(t, 9.5, updated["price"])
+
+		_, resp = post(`mutation($id: ID!) { updateProduct(id: $id, version: 1, input: {price: 1}) { version } }`, map[string]interface{}{"id": ids[0]})
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, problem.CodePreconditionFailed, resp.Errors[0].Extensions["code"])
+
+		_, resp = post(`mutation($id: ID!) { deleteProduct(id: $id) }`, map[string]interface{}{"id": ids[1]})
+		require.Empty(t, resp.Errors)
+		assert.Equal(t, true, resp.Data["deleteProduct"])
+
+		_, resp = post(`query($id: ID!) { product(id: $id) { name } }`, map[string]interface{}{"id": ids[1]})
+		require.Empty(t, resp.Errors)
+		assert.Nil(t, resp.Data["product"])
+
+		_, resp = post(`mutation { deleteProduct(id: "missing") }`, nil)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, problem.CodeNotFound, resp.Errors[0].Extensions["code"])
+	})
+
+	// Test that queries over the limits are rejected before they run
+	t.Run("Limits", func(t *testing.T) {
+		w, resp := post(`{ products(limit: 100) { items { id name description price availability { onHand reserved } } } }`, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, CodeQueryTooComplex, resp.Errors[0].Extensions["code"])
+
+		// The page size can come from a variable
+		w, _ = post(`query($n: Int) { products(limit: $n) { items { id name description price } } }`, map[string]interface{}{"n": 200})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w, _ = post(`query { ...F } fragment F on Query { products(limit: 10) { items { availability { onHand } } } }`, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Introspection is not counted
+		w, resp = post(`{ __schema { types { name fields { name type { name ofType { name ofType { name ofType { name } } } } } } } }`, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, resp.Errors)
+	})
+
+	// Test GET requests and malformed queries
+	t.Run("Requests", func(t *testing.T) {
+		query := url.Values{"query": {`query($id: ID!) { product(id: $id) { name } }`}, "variables": {`{"id":"` + ids[2] + `"}`}}
+		req, _ := http.NewRequest(http.MethodGet, "/graphql?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Gamma")
+
+		query = url.Values{"query": {`mutation { deleteProduct(id: "x") }`}}
+		req, _ = http.NewRequest(http.MethodGet, "/graphql?"+query.Encode(), nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+		w, resp := post(`{ products { items { nope } } }`, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.NotEmpty(t, resp.Errors)
+
+		w, _ = post(`{ products {`, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test that responses with errors name the request they came from
+	t.Run("RequestID", func(t *testing.T) {
+		body, _ := json.Marshal(Request{Query: `mutation { deleteProduct(id: "missing") }`})
+		req, _ := http.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+		req = req.WithContext(requestid.WithID(req.Context(), "req-42"))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Contains(t, w.Body.String(), `"extensions":{"requestId":"req-42"}`)
+
+		_, resp := post(`{ products { nextCursor } }`, nil)
+		assert.Empty(t, resp.Errors)
+	})
+}
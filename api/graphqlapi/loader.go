@@ -0,0 +1,82 @@
+package graphqlapi
+
+import (
+	"context"
+	"sync"
+
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// availabilityLoader batches the availability lookups of one request. The
+// executor resolves every availability field of a list before it calls any
+// of the returned thunks, so the first thunk called reads the availability of
+// all products queued so far with one repository call.
+type availabilityLoader struct {
+	repo database.ProductRepository
+
+	mu      sync.Mutex
+	pending []string
+	loaded  map[string]models.ProductAvailability
+	errs    map[string]error
+}
+
+type loaderKey struct{}
+
+// withLoader returns a copy of ctx carrying a new loader over repo
+func withLoader(ctx context.Context, repo database.ProductRepository) context.Context {
+	loader := &availabilityLoader{
+		repo:   repo,
+		loaded: make(map[string]models.ProductAvailability),
+		errs:   make(map[string]error),
+	}
+	return context.WithValue(ctx, loaderKey{}, loader)
+}
+
+func loaderFrom(ctx context.Context) *availabilityLoader {
+	return ctx.Value(loaderKey{}).(*availabilityLoader)
+}
+
+// load queues id and returns a thunk resolving to its availability, or to
+// null if the product no longer exists
+func (l *availabilityLoader) load(ctx context.Context, id string) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, done := l.loaded[id]; !done {
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if len(l.pending) > 0 {
+			l.flush(ctx)
+		}
+		if err := l.errs[id]; err != nil {
+			return nil, resolveError(ctx, err)
+		}
+		availability, ok := l.loaded[id]
+		if !ok {
+			return nil, nil
+		}
+		return availability, nil
+	}
+}
+
+// flush reads the pending products. The caller must hold the lock.
+func (l *availabilityLoader) flush(ctx context.Context) {
+	ids := l.pending
+	l.pending = nil
+
+	availability, err := l.repo.CheckProductsAvailability(ctx, ids)
+	for _, id := range ids {
+		if err != nil {
+			l.errs[id] = err
+			continue
+		}
+		if a, ok := availability[id]; ok {
+			l.loaded[id] = a
+		}
+	}
+}
@@ -0,0 +1,341 @@
+// Package graphqlapi serves products over GraphQL at /graphql, next to the
+// REST API and over the same repository.
+package graphqlapi
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/graphql-go/graphql"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+var availabilityType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "ProductAvailability",
+	Description: "Stock position of a product",
+	Fields: graphql.Fields{
+		"productId":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"isAvailable":        &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"inventoryCount":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"onHand":             &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"reserved":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"availableToPromise": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var productType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Product",
+	Fields: graphql.Fields{
+		"id":             &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"name":           &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"description":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"price":          &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"inventoryCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"createdAt":      &graphql.Field{Type: graphql.DateTime},
+		"updatedAt":      &graphql.Field{Type: graphql.DateTime},
+		"version":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"deletedAt": &graphql.Field{
+			Type:        graphql.DateTime,
+			Description: "Set while the product is in the trash",
+		},
+		"availability": &graphql.Field{
+			Type:        availabilityType,
+			Description: "Loaded for all products in a response with one repository call",
+			Resolve:     resolveAvailability,
+		},
+	},
+})
+
+var productPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ProductPage",
+	Fields: graphql.Fields{
+		"items": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(productType)))},
+		"nextCursor": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Pass as cursor to fetch the next page; null on the last page",
+		},
+	},
+})
+
+var productFilterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "ProductFilter",
+	Description: "Restricts which products are listed; time windows are half-open",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"minPrice":      &graphql.InputObjectFieldConfig{Type: graphql.Float},
+		"maxPrice":      &graphql.InputObjectFieldConfig{Type: graphql.Float},
+		"inStock":       &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		"namePrefix":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"createdAfter":  &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"createdBefore": &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"updatedAfter":  &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"updatedBefore": &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"deleted": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Boolean,
+			Description: "List the trash instead",
+		},
+	},
+})
+
+var productInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ProductInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"id":             &graphql.InputObjectFieldConfig{Type: graphql.ID},
+		"name":           &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"description":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"price":          &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Float)},
+		"inventoryCount": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var productUpdateType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "ProductUpdate",
+	Description: "Fields to change; omitted fields keep their stored value",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":           &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"description":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"price":          &graphql.InputObjectFieldConfig{Type: graphql.Float},
+		"inventoryCount": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+	},
+})
+
+// versionArg makes a write conditional, like If-Match in the REST API
+var versionArg = &graphql.ArgumentConfig{
+	Type:        graphql.Int,
+	Description: "Only write if the product is at this version",
+}
+
+// NewSchema builds the GraphQL schema over repo
+func NewSchema(repo database.ProductRepository) (graphql.Schema, error) {
+	r := resolver{repo: repo}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"product": &graphql.Field{
+				Type:        productType,
+				Description: "A product, or its state at asOf; null if there is none",
+				Args: graphql.FieldConfigArgument{
+					"id":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"asOf": &graphql.ArgumentConfig{Type: graphql.DateTime},
+				},
+				Resolve: r.product,
+			},
+			"products": &graphql.Field{
+				Type:        graphql.NewNonNull(productPageType),
+				Description: "One page of products",
+				Args: graphql.FieldConfigArgument{
+					"limit": &graphql.ArgumentConfig{
+						Type:        graphql.Int,
+						Description: fmt.Sprintf("Page size, 1-%d, default %d", database.MaxPageSize, database.DefaultPageSize),
+					},
+					"cursor": &graphql.ArgumentConfig{Type: graphql.String},
+					"sort": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: `Comma-separated fields, '-' prefix for descending, e.g. "-price,name"`,
+					},
+					"filter": &graphql.ArgumentConfig{Type: productFilterType},
+				},
+				Resolve: r.products,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createProduct": &graphql.Field{
+				Type: graphql.NewNonNull(productType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(productInputType)},
+				},
+				Resolve: r.createProduct,
+			},
+			"updateProduct": &graphql.Field{
+				Type: graphql.NewNonNull(productType),
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"version": versionArg,
+					"input":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(productUpdateType)},
+				},
+				Resolve: r.updateProduct,
+			},
+			"deleteProduct": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Moves a product to the trash",
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"version": versionArg,
+				},
+				Resolve: r.deleteProduct,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+// resolver resolves the root fields against the repository
+type resolver struct {
+	repo database.ProductRepository
+}
+
+func (r resolver) product(p graphql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(string)
+
+	var product models.Product
+	var err error
+	if asOf, ok := p.Args["asOf"].(time.Time); ok {
+		product, err = r.repo.GetProductAsOf(p.Context, id, asOf)
+	} else {
+		product, err = r.repo.GetProductByID(p.Context, id)
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, resolveError(p.Context, err)
+	}
+
+	return product, nil
+}
+
+func (r resolver) products(p graphql.ResolveParams) (interface{}, error) {
+	query := database.ProductQuery{}
+	if limit, ok := p.Args["limit"].(int); ok {
+		if limit < 1 || limit > database.MaxPageSize {
+			return nil, invalidArgument(fmt.Errorf("limit must be between 1 and %d", database.MaxPageSize))
+		}
+		query.Limit = limit
+	}
+	query.Cursor, _ = p.Args["cursor"].(string)
+
+	sort, _ := p.Args["sort"].(string)
+	sortFields, err := database.ParseSort(sort)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	query.Sort = sortFields
+
+	if filter, ok := p.Args["filter"].(map[string]interface{}); ok {
+		query.Filter = productFilter(filter)
+	}
+
+	page, err := r.repo.QueryProducts(p.Context, query)
+	if err != nil {
+		return nil, resolveError(p.Context, err)
+	}
+
+	result := map[string]interface{}{"items": page.Products, "nextCursor": nil}
+	if page.NextCursor != "" {
+		result["nextCursor"] = page.NextCursor
+	}
+	return result, nil
+}
+
+func (r resolver) createProduct(p graphql.ResolveParams) (interface{}, error) {
+	input, _ := p.Args["input"].(map[string]interface{})
+	product := models.Product{}
+	product.ID, _ = input["id"].(string)
+	applyUpdate(&product, input)
+	if err := binding.Validator.ValidateStruct(product); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	created, err := r.repo.CreateProduct(p.Context, product)
+	if err != nil {
+		return nil, resolveError(p.Context, err)
+	}
+
+	return created, nil
+}
+
+func (r resolver) updateProduct(p graphql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(string)
+	version, _ := p.Args["version"].(int)
+	input, _ := p.Args["input"].(map[string]interface{})
+
+	// Apply the given fields to whatever is stored at write time, atomically
+	updated, err := r.repo.PatchProduct(p.Context, id, int64(version), func(current models.Product) (models.Product, error) {
+		applyUpdate(&current, input)
+		if err := binding.Validator.ValidateStruct(current); err != nil {
+			return current, invalidArgument(err)
+		}
+		return current, nil
+	})
+	if err != nil {
+		return nil, resolveError(p.Context, err)
+	}
+
+	return updated, nil
+}
+
+func (r resolver) deleteProduct(p graphql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(string)
+	version, _ := p.Args["version"].(int)
+
+	if err := r.repo.DeleteProduct(p.Context, id, int64(version)); err != nil {
+		return nil, resolveError(p.Context, err)
+	}
+
+	return true, nil
+}
+
+// resolveAvailability defers to the request's loader, so the availability of
+// every product in a response is read together
+func resolveAvailability(p graphql.ResolveParams) (interface{}, error) {
+	product, ok := p.Source.(models.Product)
+	if !ok || product.DeletedAt != nil {
+		return nil, nil
+	}
+
+	return loaderFrom(p.Context).load(p.Context, product.ID), nil
+}
+
+// applyUpdate copies the product fields present in input onto product
+func applyUpdate(product *models.Product, input map[string]interface{}) {
+	if name, ok := input["name"].(string); ok {
+		product.Name = name
+	}
+	if description, ok := input["description"].(string); ok {
+		product.Description = description
+	}
+	if price, ok := input["price"].(float64); ok {
+		product.Price = price
+	}
+	if count, ok := input["inventoryCount"].(int); ok {
+		product.InventoryCount = count
+	}
+}
+
+// productFilter converts a ProductFilter input into a repository filter
+func productFilter(input map[string]interface{}) database.ProductFilter {
+	filter := database.ProductFilter{}
+	filter.Deleted, _ = input["deleted"].(bool)
+	filter.NamePrefix, _ = input["namePrefix"].(string)
+	if minPrice, ok := input["minPrice"].(float64); ok {
+		filter.MinPrice = &minPrice
+	}
+	if maxPrice, ok := input["maxPrice"].(float64); ok {
+		filter.MaxPrice = &maxPrice
+	}
+	if inStock, ok := input["inStock"].(bool); ok {
+		filter.InStock = &inStock
+	}
+
+	times := map[string]**time.Time{
+		"createdAfter":  &filter.CreatedAfter,
+		"createdBefore": &filter.CreatedBefore,
+		"updatedAfter":  &filter.UpdatedAfter,
+		"updatedBefore": &filter.UpdatedBefore,
+	}
+	for name, target := range times {
+		if t, ok := input[name].(time.Time); ok {
+			*target = &t
+		}
+	}
+
+	return filter
+}
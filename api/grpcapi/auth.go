@@ -0,0 +1,111 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/yourusername/product-service/api/grpcapi/productpb"
+	"github.com/yourusername/product-service/api/middleware"
+	"github.com/yourusername/product-service/internal/apikeys"
+	"github.com/yourusername/product-service/internal/database"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// APIKeyMetadataKey carries an API key, like the REST X-API-Key header
+	APIKeyMetadataKey = "x-api-key"
+
+	// authorizationMetadataKey carries "Bearer <token>", as in REST
+	authorizationMetadataKey = "authorization"
+)
+
+// methodRoles are the roles each product service method requires, matching
+// the REST routes that do the same. Methods missing here are refused.
+var methodRoles = map[string]string{
+	"ListProducts":             middleware.RoleCatalogRead,
+	"GetProduct":               middleware.RoleCatalogRead,
+	"GetProductHistory":        middleware.RoleCatalogRead,
+	"CheckProductAvailability": middleware.RoleCatalogRead,
+	"GetReservation":           middleware.RoleCatalogRead,
+	"WatchProducts":            middleware.RoleCatalogRead,
+	"CreateProduct":            middleware.RoleCatalogWrite,
+	"UpdateProduct":            middleware.RoleCatalogWrite,
+	"DeleteProduct":            middleware.RoleCatalogWrite,
+	"UndeleteProduct":          middleware.RoleCatalogWrite,
+	"RestoreProduct":           middleware.RoleCatalogWrite,
+	"AdjustInventory":          middleware.RoleInventoryWrite,
+	"ReserveInventory":         middleware.RoleInventoryWrite,
+	"ConfirmReservation":       middleware.RoleInventoryWrite,
+	"ReleaseReservation":       middleware.RoleInventoryWrite,
+}
+
+type principalKey struct{}
+
+// principalFromContext returns the caller verified by authenticate, if any
+func principalFromContext(ctx context.Context) (middleware.Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(middleware.Principal)
+	return principal, ok
+}
+
+// productMethod returns the name of a product service method, or "" for the
+// methods of other services such as health and reflection
+func productMethod(fullMethod string) string {
+	method, found := strings.CutPrefix(fullMethod, "/"+productpb.ProductService_ServiceDesc.ServiceName+"/")
+	if !found {
+		return ""
+	}
+	return method
+}
+
+// authenticate verifies the credentials of a product service call and checks
+// the role its method requires, with the same credentials and roles as REST.
+// The caller's subject replaces any x-actor metadata. Without an
+// authenticator calls are anonymous; other services are always public.
+func authenticate(ctx context.Context, fullMethod string, tokens *middleware.Authenticator, keys *apikeys.Service) (context.Context, error) {
+	method := productMethod(fullMethod)
+	if tokens == nil || method == "" {
+		return ctx, nil
+	}
+
+	first := func(key string) string {
+		if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	principal, err := middleware.Identify(ctx, tokens, keys, first(APIKeyMetadataKey), first(authorizationMetadataKey))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	role, known := methodRoles[method]
+	if !known || !principal.HasRole(role) {
+		return nil, status.Errorf(codes.PermissionDenied, "The %s role is required", role)
+	}
+
+	ctx = context.WithValue(ctx, principalKey{}, principal)
+	return database.WithActor(ctx, principal.Subject), nil
+}
+
+func authUnaryInterceptor(tokens *middleware.Authenticator, keys *apikeys.Service) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, info.FullMethod, tokens, keys)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func authStreamInterceptor(tokens *middleware.Authenticator, keys *apikeys.Service) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), info.FullMethod, tokens, keys)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
@@ -0,0 +1,118 @@
+package grpcapi
+
+import (
+	"time"
+
+	"github.com/yourusername/product-service/api/grpcapi/productpb"
+	"github.com/yourusername/product-service/internal/models"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// timestamp converts a time, leaving the zero time unset
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// timeOf converts an optional timestamp to an optional time
+func timeOf(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func productToProto(product models.Product) *productpb.Product {
+	pb := &productpb.Product{
+		Id:             product.ID,
+		Name:           product.Name,
+		Description:    product.Description,
+		Price:          product.Price,
+		InventoryCount: int32(product.InventoryCount),
+		CreatedAt:      timestamp(product.CreatedAt),
+		UpdatedAt:      timestamp(product.UpdatedAt),
+		Version:        product.Version,
+	}
+	if product.DeletedAt != nil {
+		pb.DeletedAt = timestamppb.New(*product.DeletedAt)
+	}
+	return pb
+}
+
+// productFromProto converts the client-settable fields of a product
+func productFromProto(pb *productpb.Product) models.Product {
+	return models.Product{
+		ID:             pb.GetId(),
+		Name:           pb.GetName(),
+		Description:    pb.GetDescription(),
+		Price:          pb.GetPrice(),
+		InventoryCount: int(pb.GetInventoryCount()),
+		Version:        pb.GetVersion(),
+	}
+}
+
+func productsToProto(products []models.Product) []*productpb.Product {
+	pbs := make([]*productpb.Product, len(products))
+	for i, product := range products {
+		pbs[i] = productToProto(product)
+	}
+	return pbs
+}
+
+func availabilityToProto(availability models.ProductAvailability) *productpb.ProductAvailability {
+	return &productpb.ProductAvailability{
+		ProductId:          availability.ProductID,
+		IsAvailable:        availability.IsAvailable,
+		InventoryCount:     int32(availability.InventoryCount),
+		OnHand:             int32(availability.OnHand),
+		Reserved:           int32(availability.Reserved),
+		AvailableToPromise: int32(availability.AvailableToPromise),
+	}
+}
+
+func reservationToProto(reservation models.Reservation) *productpb.Reservation {
+	return &productpb.Reservation{
+		Id:        reservation.ID,
+		ProductId: reservation.ProductID,
+		Quantity:  int32(reservation.Quantity),
+		Status:    reservation.Status,
+		ExpiresAt: timestamp(reservation.ExpiresAt),
+		CreatedAt: timestamp(reservation.CreatedAt),
+	}
+}
+
+func revisionToProto(revision models.Revision) *productpb.Revision {
+	pb := &productpb.Revision{
+		Seq:          revision.Seq,
+		ProductId:    revision.ProductID,
+		Kind:         revision.Kind,
+		Actor:        revision.Actor,
+		Timestamp:    timestamp(revision.Timestamp),
+		Changes:      revision.Changes,
+		RestoredFrom: revision.RestoredFrom,
+	}
+	if revision.Product != nil {
+		pb.Product = productToProto(*revision.Product)
+	}
+	return pb
+}
+
+func eventToProto(event models.Event) *productpb.Event {
+	pb := &productpb.Event{
+		Id:         event.ID,
+		Type:       event.Type,
+		ProductId:  event.ProductID,
+		Version:    event.Version,
+		Revision:   event.Revision,
+		Actor:      event.Actor,
+		OccurredAt: timestamp(event.OccurredAt),
+		Product:    productToProto(event.Product),
+	}
+	if event.Previous != nil {
+		pb.Previous = productToProto(*event.Previous)
+	}
+	return pb
+}
@@ -0,0 +1,62 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/requestid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusError translates a repository error into a gRPC status, following
+// the same classification as the REST API's problem responses. Errors the
+// repository does not classify are logged and reported as Internal.
+func statusError(ctx context.Context, err error) error {
+	code, message := classify(err)
+	if code == codes.Internal {
+		requestid.Printf(ctx, "Internal error: %v", err)
+	}
+	return status.Error(code, message)
+}
+
+func classify(err error) (codes.Code, string) {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return codes.NotFound, "Product not found"
+	case errors.Is(err, database.ErrReservationNotFound):
+		return codes.NotFound, "Reservation not found"
+	case errors.Is(err, database.ErrRevisionNotFound):
+		return codes.NotFound, "Revision not found"
+	case errors.Is(err, database.ErrConflict):
+		return codes.AlreadyExists, "A product with this ID already exists"
+	case errors.Is(err, database.ErrPreconditionFailed):
+		return codes.Aborted, "Product was modified concurrently"
+	case errors.Is(err, database.ErrInsufficientInventory):
+		return codes.FailedPrecondition, err.Error()
+	case errors.Is(err, database.ErrReservationExpired):
+		return codes.FailedPrecondition, "Reservation has expired"
+	case errors.Is(err, database.ErrUnknownTenant):
+		return codes.InvalidArgument, err.Error()
+	case errors.Is(err, database.ErrQuotaExceeded):
+		return codes.ResourceExhausted, err.Error()
+	case errors.Is(err, database.ErrNotSupported):
+		return codes.Unimplemented, err.Error()
+	case errors.Is(err, database.ErrInvalidCursor):
+		return codes.InvalidArgument, err.Error()
+	case errors.Is(err, database.ErrUnavailable):
+		return codes.Unavailable, "Storage is temporarily unavailable"
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded, "Request timed out"
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled, "Request was cancelled"
+	default:
+		return codes.Internal, "Internal server error"
+	}
+}
+
+// invalidArgument reports a request that failed validation
+func invalidArgument(err error) error {
+	return status.Error(codes.InvalidArgument, err.Error())
+}
@@ -0,0 +1,5 @@
+// Package productpb holds the protobuf messages and gRPC service definition
+// of the product API, generated from product.proto.
+package productpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative product.proto
@@ -0,0 +1,523 @@
+// Package grpcapi serves the product API over gRPC, next to the REST API and
+// over the same repository.
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/yourusername/product-service/api/grpcapi/productpb"
+	"github.com/yourusername/product-service/api/middleware"
+	"github.com/yourusername/product-service/internal/apikeys"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/events"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/requestid"
+	"github.com/yourusername/product-service/internal/tenancy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	// ActorMetadataKey carries the caller's identity, like the REST X-Actor header
+	ActorMetadataKey = "x-actor"
+
+	// RequestIDMetadataKey carries the identifier of a call, in both
+	// directions, like the REST X-Request-ID header
+	RequestIDMetadataKey = "x-request-id"
+
+	// traceparentMetadataKey is the W3C Trace Context traceparent, whose
+	// trace ID identifies a call without RequestIDMetadataKey
+	traceparentMetadataKey = "traceparent"
+
+	// TenantMetadataKey names the tenant of the call, like the REST X-Tenant-ID
+	// header
+	TenantMetadataKey = "x-tenant-id"
+
+	// maxActorLength matches the limit the REST API applies to X-Actor
+	maxActorLength = 128
+
+	// defaultReservationTTL is how long stock is held when the request does not say
+	defaultReservationTTL = 15 * time.Minute
+)
+
+// Server implements productpb.ProductServiceServer over a ProductRepository
+type Server struct {
+	productpb.UnimplementedProductServiceServer
+
+	repo database.ProductRepository
+	feed *events.Feed
+}
+
+// NewServer returns a Server reading and writing repo and watching feed
+func NewServer(repo database.ProductRepository, feed *events.Feed) *Server {
+	return &Server{repo: repo, feed: feed}
+}
+
+// Options configures the product service's authentication and tenancy
+type Options struct {
+	// Authenticator, when set, requires every product service call to carry
+	// a bearer token or, with Keys, an API key granting the method's role
+	Authenticator *middleware.Authenticator
+	Keys          *apikeys.Service
+
+	// Tenants, when set, requires every product service call to name one of
+	// them in its metadata
+	Tenants []string
+}
+
+// NewGRPCServer returns a grpc.Server serving the product service together
+// with the standard health and reflection services
+func NewGRPCServer(repo database.ProductRepository, feed *events.Feed, options Options, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(
+			requestIDUnaryInterceptor,
+			actorUnaryInterceptor,
+			authUnaryInterceptor(options.Authenticator, options.Keys),
+			tenantUnaryInterceptor(options.Tenants),
+		),
+		grpc.ChainStreamInterceptor(
+			requestIDStreamInterceptor,
+			actorStreamInterceptor,
+			authStreamInterceptor(options.Authenticator, options.Keys),
+			tenantStreamInterceptor(options.Tenants),
+		),
+	)
+	server := grpc.NewServer(opts...)
+	productpb.RegisterProductServiceServer(server, NewServer(repo, feed))
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(productpb.ProductService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
+	return server
+}
+
+// ListProducts returns one page of products, or of the trash
+func (s *Server) ListProducts(ctx context.Context, req *productpb.ListProductsRequest) (*productpb.ListProductsResponse, error) {
+	if req.GetPageSize() < 0 || req.GetPageSize() > database.MaxPageSize {
+		return nil, invalidArgument(fmt.Errorf("page_size must be between 1 and %d", database.MaxPageSize))
+	}
+	sortFields, err := database.ParseSort(req.GetSort())
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	query := database.ProductQuery{
+		Limit:  int(req.GetPageSize()),
+		Cursor: req.GetPageToken(),
+		Sort:   sortFields,
+		Filter: database.ProductFilter{
+			Deleted:       req.GetDeleted(),
+			MinPrice:      req.MinPrice,
+			MaxPrice:      req.MaxPrice,
+			InStock:       req.InStock,
+			NamePrefix:    req.GetNamePrefix(),
+			CreatedAfter:  timeOf(req.GetCreatedAfter()),
+			CreatedBefore: timeOf(req.GetCreatedBefore()),
+			UpdatedAfter:  timeOf(req.GetUpdatedAfter()),
+			UpdatedBefore: timeOf(req.GetUpdatedBefore()),
+		},
+	}
+
+	page, err := s.repo.QueryProducts(ctx, query)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return &productpb.ListProductsResponse{Products: productsToProto(page.Products), NextPageToken: page.NextCursor}, nil
+}
+
+// GetProduct returns a product, or its state at as_of
+func (s *Server) GetProduct(ctx context.Context, req *productpb.GetProductRequest) (*productpb.Product, error) {
+	var product models.Product
+	var err error
+	if req.GetAsOf() != nil {
+		product, err = s.repo.GetProductAsOf(ctx, req.GetId(), req.GetAsOf().AsTime())
+	} else {
+		product, err = s.repo.GetProductByID(ctx, req.GetId())
+	}
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return productToProto(product), nil
+}
+
+// CreateProduct creates a product, under the given ID if there is one
+func (s *Server) CreateProduct(ctx context.Context, req *productpb.CreateProductRequest) (*productpb.Product, error) {
+	product := productFromProto(req.GetProduct())
+	if err := binding.Validator.ValidateStruct(product); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	created, err := s.repo.CreateProduct(ctx, product)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return productToProto(created), nil
+}
+
+// UpdateProduct replaces a product, or only the fields named by update_mask
+func (s *Server) UpdateProduct(ctx context.Context, req *productpb.UpdateProductRequest) (*productpb.Product, error) {
+	product := productFromProto(req.GetProduct())
+	paths := req.GetUpdateMask().GetPaths()
+
+	if len(paths) == 0 {
+		if err := binding.Validator.ValidateStruct(product); err != nil {
+			return nil, invalidArgument(err)
+		}
+		updated, err := s.repo.UpdateProduct(ctx, product)
+		if err != nil {
+			return nil, statusError(ctx, err)
+		}
+		return productToProto(updated), nil
+	}
+
+	for _, path := range paths {
+		switch path {
+		case "name", "description", "price", "inventory_count":
+		default:
+			return nil, invalidArgument(fmt.Errorf("update_mask path %q is not an updatable field", path))
+		}
+	}
+
+	// Apply the masked fields to whatever is stored at write time, atomically
+	updated, err := s.repo.PatchProduct(ctx, product.ID, product.Version, func(current models.Product) (models.Product, error) {
+		for _, path := range paths {
+			switch path {
+			case "name":
+				current.Name = product.Name
+			case "description":
+				current.Description = product.Description
+			case "price":
+				current.Price = product.Price
+			case "inventory_count":
+				current.InventoryCount = product.InventoryCount
+			}
+		}
+		if err := binding.Validator.ValidateStruct(current); err != nil {
+			return current, invalidArgument(err)
+		}
+		return current, nil
+	})
+	if status.Code(err) == codes.InvalidArgument {
+		return nil, err
+	}
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return productToProto(updated), nil
+}
+
+// DeleteProduct moves a product to the trash
+func (s *Server) DeleteProduct(ctx context.Context, req *productpb.DeleteProductRequest) (*emptypb.Empty, error) {
+	if err := s.repo.DeleteProduct(ctx, req.GetId(), req.GetVersion()); err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// UndeleteProduct takes a product back out of the trash
+func (s *Server) UndeleteProduct(ctx context.Context, req *productpb.UndeleteProductRequest) (*productpb.Product, error) {
+	product, err := s.repo.UndeleteProduct(ctx, req.GetId(), req.GetVersion())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return productToProto(product), nil
+}
+
+// GetProductHistory returns every revision of a product, oldest first
+func (s *Server) GetProductHistory(ctx context.Context, req *productpb.GetProductHistoryRequest) (*productpb.GetProductHistoryResponse, error) {
+	history, err := s.repo.GetProductHistory(ctx, req.GetId())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	revisions := make([]*productpb.Revision, len(history))
+	for i, revision := range history {
+		revisions[i] = revisionToProto(revision)
+	}
+	return &productpb.GetProductHistoryResponse{Revisions: revisions}, nil
+}
+
+// RestoreProduct writes the state of an earlier revision back as a new one
+func (s *Server) RestoreProduct(ctx context.Context, req *productpb.RestoreProductRequest) (*productpb.Product, error) {
+	product, err := s.repo.RestoreProduct(ctx, req.GetId(), req.GetSeq(), req.GetVersion())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return productToProto(product), nil
+}
+
+// CheckProductAvailability reports a product's stock position
+func (s *Server) CheckProductAvailability(ctx context.Context, req *productpb.CheckProductAvailabilityRequest) (*productpb.ProductAvailability, error) {
+	availability, err := s.repo.CheckProductAvailability(ctx, req.GetId())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return availabilityToProto(availability), nil
+}
+
+// AdjustInventory applies a signed change to a product's inventory count
+func (s *Server) AdjustInventory(ctx context.Context, req *productpb.AdjustInventoryRequest) (*productpb.ProductAvailability, error) {
+	adjustment := models.InventoryAdjustment{Delta: int(req.GetDelta()), Reason: req.GetReason()}
+	if err := binding.Validator.ValidateStruct(adjustment); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	availability, err := s.repo.AdjustInventory(ctx, req.GetId(), adjustment)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return availabilityToProto(availability), nil
+}
+
+// ReserveInventory holds stock until the reservation is confirmed, released or expires
+func (s *Server) ReserveInventory(ctx context.Context, req *productpb.ReserveInventoryRequest) (*productpb.Reservation, error) {
+	request := models.ReservationRequest{Quantity: int(req.GetQuantity()), TTLSeconds: int(req.GetTtlSeconds())}
+	if err := binding.Validator.ValidateStruct(request); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	ttl := defaultReservationTTL
+	if request.TTLSeconds > 0 {
+		ttl = time.Duration(request.TTLSeconds) * time.Second
+	}
+	reservation, err := s.repo.ReserveInventory(ctx, req.GetProductId(), request.Quantity, ttl)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return reservationToProto(reservation), nil
+}
+
+// GetReservation returns a held reservation
+func (s *Server) GetReservation(ctx context.Context, req *productpb.GetReservationRequest) (*productpb.Reservation, error) {
+	reservation, err := s.repo.GetReservation(ctx, req.GetProductId(), req.GetReservationId())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return reservationToProto(reservation), nil
+}
+
+// ConfirmReservation turns a hold into a sale
+func (s *Server) ConfirmReservation(ctx context.Context, req *productpb.GetReservationRequest) (*productpb.Reservation, error) {
+	reservation, err := s.repo.ConfirmReservation(ctx, req.GetProductId(), req.GetReservationId())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return reservationToProto(reservation), nil
+}
+
+// ReleaseReservation gives held stock back
+func (s *Server) ReleaseReservation(ctx context.Context, req *productpb.GetReservationRequest) (*productpb.Reservation, error) {
+	reservation, err := s.repo.ReleaseReservation(ctx, req.GetProductId(), req.GetReservationId())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return reservationToProto(reservation), nil
+}
+
+// WatchProducts streams product changes from the change feed until the
+// client goes away. A client that falls too far behind is disconnected with
+// ResourceExhausted and may resume with after_seq.
+func (s *Server) WatchProducts(req *productpb.WatchProductsRequest, stream productpb.ProductService_WatchProductsServer) error {
+	filter := events.Filter{ProductID: req.GetProductId(), Tenant: tenancy.FromContext(stream.Context())}
+	if len(req.GetTypes()) > 0 {
+		filter.Types = make(map[string]bool)
+		for _, eventType := range req.GetTypes() {
+			if !isEventType(eventType) {
+				return invalidArgument(fmt.Errorf("unknown event type %q", eventType))
+			}
+			filter.Types[eventType] = true
+		}
+	}
+
+	after := req.GetAfterSeq()
+	if after == 0 {
+		after = s.feed.Seq()
+	}
+	backlog, sub, complete := s.feed.Subscribe(after, filter)
+	defer sub.Close()
+
+	// Send headers now so clients can tell when they are subscribed
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	if !complete {
+		if err := stream.Send(&productpb.ProductChange{Reset_: true}); err != nil {
+			return err
+		}
+	}
+	for _, change := range backlog {
+		if err := stream.Send(changeToProto(change)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case change, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher fell behind; resume with after_seq")
+			}
+			if err := stream.Send(changeToProto(change)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func changeToProto(change events.Change) *productpb.ProductChange {
+	return &productpb.ProductChange{Seq: change.Seq, Event: eventToProto(change.Event)}
+}
+
+// isEventType reports whether eventType names a domain event
+func isEventType(eventType string) bool {
+	for _, known := range models.EventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// requestIDFromMetadata identifies the call by its metadata, as the REST
+// API identifies requests by their headers
+func requestIDFromMetadata(ctx context.Context) (context.Context, string) {
+	first := func(key string) string {
+		if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	id := requestid.Resolve(first(RequestIDMetadataKey), first(traceparentMetadataKey))
+	return requestid.WithID(ctx, id), id
+}
+
+func requestIDUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, id := requestIDFromMetadata(ctx)
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, id))
+	return handler(ctx, req)
+}
+
+func requestIDStreamInterceptor(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, id := requestIDFromMetadata(stream.Context())
+	stream.SetHeader(metadata.Pairs(RequestIDMetadataKey, id))
+	return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+}
+
+// actorFromMetadata attributes the call to the actor named in its metadata
+func actorFromMetadata(ctx context.Context) context.Context {
+	values := metadata.ValueFromIncomingContext(ctx, ActorMetadataKey)
+	if len(values) == 0 {
+		return ctx
+	}
+	actor := strings.TrimSpace(values[0])
+	if len(actor) > maxActorLength {
+		actor = actor[:maxActorLength]
+	}
+	if actor == "" {
+		return ctx
+	}
+	return database.WithActor(ctx, actor)
+}
+
+func actorUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(actorFromMetadata(ctx), req)
+}
+
+func actorStreamInterceptor(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{ServerStream: stream, ctx: actorFromMetadata(stream.Context())})
+}
+
+// tenantFromMetadata scopes a product service call to its tenant, bound to
+// the caller's credentials as on REST: a caller bound to a tenant always
+// acts for it and is refused if the metadata names another, and only admins
+// may name a tenant otherwise. Anonymous calls, when authentication is off,
+// name it with TenantMetadataKey. Other services, like health and
+// reflection, are not scoped.
+func tenantFromMetadata(ctx context.Context, method string, tenants []string) (context.Context, error) {
+	if len(tenants) == 0 || productMethod(method) == "" {
+		return ctx, nil
+	}
+
+	var tenant string
+	if values := metadata.ValueFromIncomingContext(ctx, TenantMetadataKey); len(values) > 0 {
+		tenant = strings.ToLower(strings.TrimSpace(values[0]))
+	}
+	if principal, authenticated := principalFromContext(ctx); authenticated {
+		switch {
+		case principal.Tenant != "":
+			if tenant != "" && tenant != principal.Tenant {
+				return nil, status.Errorf(codes.PermissionDenied, "Credentials are for tenant %q", principal.Tenant)
+			}
+			tenant = principal.Tenant
+		case !principal.HasRole(middleware.RoleAdmin):
+			return nil, status.Error(codes.PermissionDenied, "Credentials are not bound to a tenant")
+		}
+	}
+
+	for _, known := range tenants {
+		if tenant == known {
+			return tenancy.WithTenant(ctx, tenant), nil
+		}
+	}
+	return nil, status.Errorf(codes.InvalidArgument, "%s metadata must name a known tenant", TenantMetadataKey)
+}
+
+func tenantUnaryInterceptor(tenants []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := tenantFromMetadata(ctx, info.FullMethod, tenants)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func tenantStreamInterceptor(tenants []string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := tenantFromMetadata(stream.Context(), info.FullMethod, tenants)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// contextStream is a ServerStream carrying a derived context, such as one
+// with the actor or tenant of the call
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,374 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/api/grpcapi/productpb"
+	"github.com/yourusername/product-service/api/middleware"
+	"github.com/yourusername/product-service/internal/apikeys"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/events"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/tenancy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// dial serves repo and feed with options over an in-memory listener and
+// returns a client connection to it
+func dial(t *testing.T, repo database.ProductRepository, feed *events.Feed, options Options) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(repo, feed, options)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	repo := database.NewInMemoryRepository()
+	feed := events.NewFeed(100)
+	conn := dial(t, repo, feed, Options{})
+	client := productpb.NewProductServiceClient(conn)
+
+	// Test CRUD and attribution through the x-actor metadata
+	t.Run("CRUD", func(t *testing.T) {
+		actorCtx := metadata.AppendToOutgoingContext(ctx, ActorMetadataKey, "grpc-tester")
+
+		created, err := client.CreateProduct(actorCtx, &productpb.CreateProductRequest{Product: &productpb.Product{
+			Name: "Gadget", Description: "Test Description", Price: 10.0, InventoryCount: 3,
+		}})
+		require.NoError(t, err)
+		assert.NotEmpty(t, created.Id)
+		assert.Equal(t, int64(1), created.Version)
+		assert.NotNil(t, created.CreatedAt)
+
+		got, err := client.GetProduct(ctx, &productpb.GetProductRequest{Id: created.Id})
+		require.NoError(t, err)
+		assert.Equal(t, "Gadget", got.Name)
+
+		created.Name = "Gadget Pro"
+		updated, err := client.UpdateProduct(actorCtx, &productpb.UpdateProductRequest{Product: created})
+		require.NoError(t, err)
+		assert.Equal(t, "Gadget Pro", updated.Name)
+		assert.Equal(t, int64(2), updated.Version)
+
+		// A stale version is rejected
+		_, err = client.UpdateProduct(ctx, &productpb.UpdateProductRequest{Product: created})
+		assert.Equal(t, codes.Aborted, status.Code(err))
+
+		history, err := client.GetProductHistory(ctx, &productpb.GetProductHistoryRequest{Id: created.Id})
+		require.NoError(t, err)
+		require.Len(t, history.Revisions, 2)
+		assert.Equal(t, "grpc-tester", history.Revisions[1].Actor)
+
+		list, err := client.ListProducts(ctx, &productpb.ListProductsRequest{PageSize: 10})
+		require.NoError(t, err)
+		assert.Len(t, list.Products, 1)
+
+		_, err = client.DeleteProduct(ctx, &productpb.DeleteProductRequest{Id: created.Id, Version: updated.Version})
+		require.NoError(t, err)
+		_, err = client.GetProduct(ctx, &productpb.GetProductRequest{Id: created.Id})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+
+		trash, err := client.ListProducts(ctx, &productpb.ListProductsRequest{Deleted: true})
+		require.NoError(t, err)
+		require.Len(t, trash.Products, 1)
+		assert.NotNil(t, trash.Products[0].DeletedAt)
+
+		restored, err := client.UndeleteProduct(ctx, &productpb.UndeleteProductRequest{Id: created.Id})
+		require.NoError(t, err)
+		assert.Nil(t, restored.DeletedAt)
+	})
+
+	// Test validation and partial updates through update_mask
+	t.Run("UpdateMask", func(t *testing.T) {
+		_, err := client.CreateProduct(ctx, &productpb.CreateProductRequest{Product: &productpb.Product{Name: "", Price: 1.0}})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		created, err := client.CreateProduct(ctx, &productpb.CreateProductRequest{Product: &productpb.Product{
+			Name: "Masked", Description: "Test Description", Price: 2.0, InventoryCount: 1,
+		}})
+		require.NoError(t, err)
+
+		updated, err := client.UpdateProduct(ctx, &productpb.UpdateProductRequest{
+			Product:    &productpb.Product{Id: created.Id, Price: 3.5},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"price"}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3.5, updated.Price)
+		assert.Equal(t, "Masked", updated.Name)
+
+		_, err = client.UpdateProduct(ctx, &productpb.UpdateProductRequest{
+			Product:    &productpb.Product{Id: created.Id},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"created_at"}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = client.UpdateProduct(ctx, &productpb.UpdateProductRequest{
+			Product:    &productpb.Product{Id: created.Id, Price: -1},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"price"}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	// Test availability, inventory adjustments and reservations
+	t.Run("Inventory", func(t *testing.T) {
+		created, err := client.CreateProduct(ctx, &productpb.CreateProductRequest{Product: &productpb.Product{
+			Name: "Stocked", Description: "Test Description", Price: 4.0, InventoryCount: 5,
+		}})
+		require.NoError(t, err)
+
+		availability, err := client.AdjustInventory(ctx, &productpb.AdjustInventoryRequest{Id: created.Id, Delta: 2, Reason: "restock"})
+		require.NoError(t, err)
+		assert.Equal(t, int32(7), availability.OnHand)
+
+		reservation, err := client.ReserveInventory(ctx, &productpb.ReserveInventoryRequest{ProductId: created.Id, Quantity: 3})
+		require.NoError(t, err)
+		assert.Equal(t, models.ReservationHeld, reservation.Status)
+
+		availability, err = client.CheckProductAvailability(ctx, &productpb.CheckProductAvailabilityRequest{Id: created.Id})
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), availability.Reserved)
+		assert.Equal(t, int32(4), availability.AvailableToPromise)
+
+		_, err = client.ReserveInventory(ctx, &productpb.ReserveInventoryRequest{ProductId: created.Id, Quantity: 10})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+		confirmed, err := client.ConfirmReservation(ctx, &productpb.GetReservationRequest{ProductId: created.Id, ReservationId: reservation.Id})
+		require.NoError(t, err)
+		assert.Equal(t, models.ReservationConfirmed, confirmed.Status)
+
+		_, err = client.GetReservation(ctx, &productpb.GetReservationRequest{ProductId: created.Id, ReservationId: "missing"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	// Test streaming changes as the dispatcher publishes them
+	t.Run("WatchProducts", func(t *testing.T) {
+		dispatcher := events.NewDispatcher(repo, feed, 100, time.Second)
+		_, err := dispatcher.DispatchOnce(ctx)
+		require.NoError(t, err)
+
+		watchCtx, stop := context.WithCancel(ctx)
+		defer stop()
+		stream, err := client.WatchProducts(watchCtx, &productpb.WatchProductsRequest{Types: []string{models.EventProductCreated}})
+		require.NoError(t, err)
+
+		// Headers arrive once the subscription is registered
+		_, err = stream.Header()
+		require.NoError(t, err)
+
+		created, err := client.CreateProduct(ctx, &productpb.CreateProductRequest{Product: &productpb.Product{
+			Name: "Watched", Description: "Test Description", Price: 1.0, InventoryCount: 1,
+		}})
+		require.NoError(t, err)
+		_, err = dispatcher.DispatchOnce(ctx)
+		require.NoError(t, err)
+
+		change, err := stream.Recv()
+		require.NoError(t, err)
+		assert.NotZero(t, change.Seq)
+		assert.Equal(t, models.EventProductCreated, change.Event.Type)
+		assert.Equal(t, created.Id, change.Event.ProductId)
+
+		// Resuming after an earlier sequence number replays the change
+		resumed, err := client.WatchProducts(watchCtx, &productpb.WatchProductsRequest{AfterSeq: change.Seq - 1})
+		require.NoError(t, err)
+		replayed, err := resumed.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, change.Seq, replayed.Seq)
+
+		bad, err := client.WatchProducts(watchCtx, &productpb.WatchProductsRequest{Types: []string{"Nope"}})
+		require.NoError(t, err)
+		_, err = bad.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	// Test the health and reflection services
+	t.Run("HealthAndReflection", func(t *testing.T) {
+		health, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: productpb.ProductService_ServiceDesc.ServiceName})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, health.Status)
+
+		stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+		}))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		var services []string
+		for _, service := range resp.GetListServicesResponse().GetService() {
+			services = append(services, service.Name)
+		}
+		assert.Contains(t, services, productpb.ProductService_ServiceDesc.ServiceName)
+	})
+
+	// Test that calls are scoped to the tenant named in their metadata
+	t.Run("Tenants", func(t *testing.T) {
+		tenantRepo := database.NewTenantRepository(map[string]database.ProductRepository{
+			"brand-a": database.NewInMemoryRepository(),
+			"brand-b": database.NewInMemoryRepository(),
+		}, nil)
+		tenantClient := productpb.NewProductServiceClient(dial(t, tenantRepo, events.NewFeed(100), Options{Tenants: []string{"brand-a", "brand-b"}}))
+		brandA := metadata.AppendToOutgoingContext(ctx, TenantMetadataKey, "brand-a")
+		brandB := metadata.AppendToOutgoingContext(ctx, TenantMetadataKey, "brand-b")
+
+		created, err := tenantClient.CreateProduct(brandA, &productpb.CreateProductRequest{Product: &productpb.Product{
+			Name: "Brand A", Description: "Test Description", Price: 1.0, InventoryCount: 1,
+		}})
+		require.NoError(t, err)
+
+		_, err = tenantClient.GetProduct(brandB, &productpb.GetProductRequest{Id: created.Id})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		_, err = tenantClient.GetProduct(brandA, &productpb.GetProductRequest{Id: created.Id})
+		assert.NoError(t, err)
+
+		_, err = tenantClient.GetProduct(ctx, &productpb.GetProductRequest{Id: created.Id})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		unknown := metadata.AppendToOutgoingContext(ctx, TenantMetadataKey, "brand-c")
+		stream, err := tenantClient.WatchProducts(unknown, &productpb.WatchProductsRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	// Test that calls are identified by their x-request-id, echoed back
+	t.Run("RequestID", func(t *testing.T) {
+		var header metadata.MD
+		idCtx := metadata.AppendToOutgoingContext(ctx, RequestIDMetadataKey, "req-42")
+		_, err := client.GetProduct(idCtx, &productpb.GetProductRequest{Id: "missing"}, grpc.Header(&header))
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		assert.Equal(t, []string{"req-42"}, header.Get(RequestIDMetadataKey))
+
+		_, err = client.ListProducts(ctx, &productpb.ListProductsRequest{}, grpc.Header(&header))
+		require.NoError(t, err)
+		require.Len(t, header.Get(RequestIDMetadataKey), 1)
+		assert.NotEmpty(t, header.Get(RequestIDMetadataKey)[0])
+	})
+
+	// Test that with an authenticator calls need credentials granting the
+	// method's role, as on REST
+	t.Run("Auth", func(t *testing.T) {
+		const secret = "0123456789abcdef0123456789abcdef"
+		authenticator, err := middleware.NewAuthenticator(middleware.AuthOptions{HMACSecret: secret})
+		require.NoError(t, err)
+		keys := apikeys.NewService(apikeys.NewMemoryStore())
+		authRepo := database.NewInMemoryRepository()
+		authConn := dial(t, authRepo, events.NewFeed(100), Options{Authenticator: authenticator, Keys: keys})
+		authClient := productpb.NewProductServiceClient(authConn)
+		withToken := func(roles ...string) context.Context {
+			claims := middleware.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{Subject: "alice", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+				Roles:            roles,
+			}
+			signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+			require.NoError(t, err)
+			return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+signed, ActorMetadataKey, "mallory")
+		}
+		product := &productpb.Product{Name: "Guarded", Description: "Test Description", Price: 1.0, InventoryCount: 1}
+
+		_, err = authClient.CreateProduct(ctx, &productpb.CreateProductRequest{Product: product})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		_, err = authClient.CreateProduct(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer nope"), &productpb.CreateProductRequest{Product: product})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		_, err = authClient.CreateProduct(withToken(middleware.RoleCatalogRead), &productpb.CreateProductRequest{Product: product})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		// The token's subject is recorded, not the x-actor metadata
+		created, err := authClient.CreateProduct(withToken(middleware.RoleCatalogWrite), &productpb.CreateProductRequest{Product: product})
+		require.NoError(t, err)
+		history, err := authRepo.GetProductHistory(context.Background(), created.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", history[0].Actor)
+
+		key, err := keys.Mint(context.Background(), models.APIKeyRequest{Name: "orders", Scope: models.APIKeyScopeReadOnly})
+		require.NoError(t, err)
+		keyCtx := metadata.AppendToOutgoingContext(ctx, APIKeyMetadataKey, key.Key)
+		_, err = authClient.GetProduct(keyCtx, &productpb.GetProductRequest{Id: created.Id})
+		assert.NoError(t, err)
+		_, err = authClient.DeleteProduct(keyCtx, &productpb.DeleteProductRequest{Id: created.Id})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		stream, err := authClient.WatchProducts(ctx, &productpb.WatchProductsRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		// Health and reflection stay public
+		_, err = healthpb.NewHealthClient(authConn).Check(ctx, &healthpb.HealthCheckRequest{})
+		assert.NoError(t, err)
+	})
+
+	// Test that authenticated calls act for the tenant of their credentials
+	t.Run("BoundTenants", func(t *testing.T) {
+		const secret = "0123456789abcdef0123456789abcdef"
+		authenticator, err := middleware.NewAuthenticator(middleware.AuthOptions{HMACSecret: secret})
+		require.NoError(t, err)
+		tenantRepo := database.NewTenantRepository(map[string]database.ProductRepository{
+			"brand-a": database.NewInMemoryRepository(),
+			"brand-b": database.NewInMemoryRepository(),
+		}, nil)
+		tenantClient := productpb.NewProductServiceClient(dial(t, tenantRepo, events.NewFeed(100), Options{
+			Authenticator: authenticator,
+			Tenants:       []string{"brand-a", "brand-b"},
+		}))
+		withToken := func(tenant, requested string, roles ...string) context.Context {
+			claims := middleware.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{Subject: "alice", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+				Roles:            roles,
+				Tenant:           tenant,
+			}
+			signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+			require.NoError(t, err)
+			md := []string{"authorization", "Bearer " + signed}
+			if requested != "" {
+				md = append(md, TenantMetadataKey, requested)
+			}
+			return metadata.AppendToOutgoingContext(ctx, md...)
+		}
+
+		created, err := tenantClient.CreateProduct(withToken("brand-a", "", middleware.RoleCatalogWrite), &productpb.CreateProductRequest{Product: &productpb.Product{
+			Name: "Brand A", Description: "Test Description", Price: 1.0, InventoryCount: 1,
+		}})
+		require.NoError(t, err)
+		_, err = tenantRepo.GetProductByID(tenancy.WithTenant(context.Background(), "brand-a"), created.Id)
+		assert.NoError(t, err)
+
+		// Naming another tenant is refused, and so are unbound non-admins
+		_, err = tenantClient.GetProduct(withToken("brand-a", "brand-b", middleware.RoleCatalogRead), &productpb.GetProductRequest{Id: created.Id})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		_, err = tenantClient.GetProduct(withToken("brand-b", "", middleware.RoleCatalogRead), &productpb.GetProductRequest{Id: created.Id})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		_, err = tenantClient.GetProduct(withToken("", "brand-a", middleware.RoleCatalogRead), &productpb.GetProductRequest{Id: created.Id})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		// Unbound admins choose the tenant
+		_, err = tenantClient.GetProduct(withToken("", "brand-a", middleware.RoleAdmin), &productpb.GetProductRequest{Id: created.Id})
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/internal/apikeys"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// APIKeyHandler handles API key administration requests
+type APIKeyHandler struct {
+	service *apikeys.Service
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(service *apikeys.Service) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+// GetAPIKeys godoc
+// @Summary List API keys
+// @Description Get every API key, oldest first, including revoked ones. Keys themselves are not returned.
+// @Tags api-keys
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/keys [get]
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	keys, err := h.service.List(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// GetAPIKey godoc
+// @Summary Get an API key
+// @Description Get an API key by ID, with when it was last used. The key itself is not returned.
+// @Tags api-keys
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 200 {object} models.APIKey
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/keys/{id} [get]
+func (h *APIKeyHandler) GetAPIKey(c *gin.Context) {
+	key, err := h.service.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+// CreateAPIKey godoc
+// @Summary Mint an API key
+// @Description Mint an API key for a service to send in the X-API-Key header. The read-only scope grants
+// @Description catalog:read, inventory-only adds inventory:write and full adds catalog:write; keys are never
+// @Description admin. The key is only returned here, so store it safely.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param key body models.APIKeyRequest true "API key request"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 201 {object} models.APIKey
+// @Failure 400 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req models.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if err := validateExpiry(req.ExpiresAt); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	key, err := h.service.Mint(c.Request.Context(), req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Header("Location", c.Request.URL.Path+"/"+key.ID)
+	c.JSON(http.StatusCreated, key)
+}
+
+// RotateAPIKey godoc
+// @Summary Rotate an API key
+// @Description Replace an API key's secret, keeping its ID and scope. The old key stops working at once.
+// @Description The expiry moves when a new one is given. The new key is only returned here.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param id path string true "API key ID"
+// @Param rotation body models.APIKeyRotation false "New expiry"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} models.APIKey
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/keys/{id}/rotate [post]
+func (h *APIKeyHandler) RotateAPIKey(c *gin.Context) {
+	var rotation models.APIKeyRotation
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&rotation); err != nil {
+			badRequest(c, err)
+			return
+		}
+	}
+	if err := validateExpiry(rotation.ExpiresAt); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	key, err := h.service.Rotate(c.Request.Context(), c.Param("id"), rotation)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revoke an API key for good. It stays listed with its revocation time.
+// @Tags api-keys
+// @Param id path string true "API key ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	if _, err := h.service.Revoke(c.Request.Context(), c.Param("id")); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// validateExpiry rejects an expiry that has already passed
+func validateExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return errors.New("expiresAt must be in the future")
+	}
+	return nil
+}
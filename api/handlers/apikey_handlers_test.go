@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/apikeys"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+func setupAPIKeyRouter(service *apikeys.Service) *gin.Engine {
+	apiKeyHandler := NewAPIKeyHandler(service)
+
+	router := gin.Default()
+	routes := router.Group("/api/keys")
+	{
+		routes.GET("", apiKeyHandler.GetAPIKeys)
+		routes.POST("", apiKeyHandler.CreateAPIKey)
+		routes.GET("/:id", apiKeyHandler.GetAPIKey)
+		routes.POST("/:id/rotate", apiKeyHandler.RotateAPIKey)
+		routes.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+	}
+	return router
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	service := apikeys.NewService(apikeys.NewMemoryStore())
+	router := setupAPIKeyRouter(service)
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != nil {
+			data, _ := json.Marshal(body)
+			reader = bytes.NewReader(data)
+		}
+		req, _ := http.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var key models.APIKey
+
+	// Test minting a key; the key itself is only returned here
+	t.Run("CreateAPIKey", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/keys", models.APIKeyRequest{Name: "orders", Scope: models.APIKeyScopeInventory})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &key))
+		assert.NotEmpty(t, key.Key)
+		assert.Equal(t, models.APIKeyScopeInventory, key.Scope)
+		assert.Equal(t, "/api/keys/"+key.ID, w.Header().Get("Location"))
+		assert.NotContains(t, w.Body.String(), "hash")
+
+		w = do(http.MethodGet, "/api/keys/"+key.ID, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), key.Key)
+		w = do(http.MethodGet, "/api/keys", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), key.Key)
+	})
+
+	// Test that invalid requests are rejected
+	t.Run("Validation", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		for _, invalid := range []models.APIKeyRequest{
+			{Scope: models.APIKeyScopeFull},
+			{Name: "orders", Scope: "admin"},
+			{Name: "orders", Scope: models.APIKeyScopeFull, ExpiresAt: &past},
+		} {
+			w := do(http.MethodPost, "/api/keys", invalid)
+			assert.Equal(t, http.StatusBadRequest, w.Code, invalid)
+		}
+	})
+
+	// Test that rotation returns a new key and records last use of it
+	t.Run("RotateAPIKey", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/keys/"+key.ID+"/rotate", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var rotated models.APIKey
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rotated))
+		assert.Equal(t, key.ID, rotated.ID)
+		assert.NotEqual(t, key.Key, rotated.Key)
+		assert.NotNil(t, rotated.RotatedAt)
+
+		_, err := service.Verify(ctx, rotated.Key)
+		require.NoError(t, err)
+		w = do(http.MethodGet, "/api/keys/"+key.ID, nil)
+		var fetched models.APIKey
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fetched))
+		assert.NotNil(t, fetched.LastUsedAt)
+
+		w = do(http.MethodPost, "/api/keys/missing/rotate", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	// Test revoking a key
+	t.Run("RevokeAPIKey", func(t *testing.T) {
+		w := do(http.MethodDelete, "/api/keys/"+key.ID, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = do(http.MethodGet, "/api/keys/"+key.ID, nil)
+		var revoked models.APIKey
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &revoked))
+		assert.NotNil(t, revoked.RevokedAt)
+
+		w = do(http.MethodPost, "/api/keys/"+key.ID+"/rotate", nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = do(http.MethodDelete, "/api/keys/missing", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// BatchResponse reports the outcome of every operation in a batch, in order
+// @Description Per-operation batch results
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// BatchResult is the outcome of one batch operation: the HTTP status the
+// equivalent single request would have returned, and its body
+// @Description Batch operation result
+type BatchResult struct {
+	Index   int              `json:"index"`
+	Status  int              `json:"status"`
+	Product *models.Product  `json:"product,omitempty"`
+	Problem *problem.Problem `json:"problem,omitempty"`
+}
+
+// CustomMethods serves "collection:method" routes such as POST /api/products:batch.
+// gin reads the colon as the start of a path parameter, so the route is
+// registered as "/products:method" and the method name (which keeps its
+// colon) is looked up here.
+func CustomMethods(methods map[string]gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handler, ok := methods[c.Param("method")]
+		if !ok {
+			problem.Write(c, http.StatusNotFound, problem.CodeNotFound, "Not found")
+			return
+		}
+		handler(c)
+	}
+}
+
+// BulkWrite godoc
+// @Summary Create, update and delete products in bulk
+// @Description Apply up to 1000 create, update and delete operations in order. Each operation is validated
+// @Description like its single-product request and reports its own status. With "atomic": true either all
+// @Description operations are applied or none are, and the ones that did not fail report 424.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param batch body models.BatchRequest true "Operations to apply"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 207 {object} BatchResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 501 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products:batch [post]
+func (h *ProductHandler) BulkWrite(c *gin.Context) {
+	var request models.BatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	// Validate every operation up front; invalid ones are not sent to the repository
+	results := make([]BatchResult, len(request.Operations))
+	ops := make([]database.BulkOperation, 0, len(request.Operations))
+	indexes := make([]int, 0, len(request.Operations))
+	invalid := false
+	for i, op := range request.Operations {
+		bulkOp, err := bulkOperation(op)
+		if err != nil {
+			results[i] = failedResult(i, &validationError{err})
+			invalid = true
+			continue
+		}
+		ops = append(ops, bulkOp)
+		indexes = append(indexes, i)
+	}
+
+	if request.Atomic && invalid {
+		for _, i := range indexes {
+			results[i] = failedResult(i, database.ErrBatchAborted)
+		}
+		c.JSON(http.StatusMultiStatus, BatchResponse{Results: results})
+		return
+	}
+
+	if len(ops) > 0 {
+		bulkResults, err := h.repo.BulkWrite(c.Request.Context(), ops, request.Atomic)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		for j, result := range bulkResults {
+			results[indexes[j]] = batchResult(indexes[j], ops[j].Op, result)
+		}
+	}
+
+	c.JSON(http.StatusMultiStatus, BatchResponse{Results: results})
+}
+
+// bulkOperation checks a batch operation and converts it for the repository
+func bulkOperation(op models.BatchOperation) (database.BulkOperation, error) {
+	switch op.Op {
+	case database.BulkCreate, database.BulkUpdate:
+		if op.Product == nil {
+			return database.BulkOperation{}, fmt.Errorf("%s requires a product", op.Op)
+		}
+		if err := binding.Validator.ValidateStruct(op.Product); err != nil {
+			return database.BulkOperation{}, err
+		}
+		product := *op.Product
+		if op.Op == database.BulkUpdate {
+			if op.ID == "" {
+				return database.BulkOperation{}, errors.New("update requires an id")
+			}
+			product.ID = op.ID
+			product.Version = op.Version
+		}
+		return database.BulkOperation{Op: op.Op, Product: product}, nil
+	case database.BulkDelete:
+		if op.ID == "" {
+			return database.BulkOperation{}, errors.New("delete requires an id")
+		}
+		return database.BulkOperation{Op: op.Op, Product: models.Product{ID: op.ID, Version: op.Version}}, nil
+	default:
+		return database.BulkOperation{}, fmt.Errorf("unknown op %q, expected create, update or delete", op.Op)
+	}
+}
+
+// batchResult reports a repository result with the status of the equivalent single request
+func batchResult(index int, op string, result database.BulkResult) BatchResult {
+	if result.Err != nil {
+		return failedResult(index, result.Err)
+	}
+
+	switch op {
+	case database.BulkCreate:
+		return BatchResult{Index: index, Status: http.StatusCreated, Product: &result.Product}
+	case database.BulkUpdate:
+		return BatchResult{Index: index, Status: http.StatusOK, Product: &result.Product}
+	default:
+		return BatchResult{Index: index, Status: http.StatusNoContent}
+	}
+}
+
+// failedResult reports a failed operation with the problem a single request would get
+func failedResult(index int, err error) BatchResult {
+	p := problemFor(err)
+	return BatchResult{Index: index, Status: p.Status, Problem: &p}
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/catalogue"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/requestid"
+)
+
+const (
+	// importChunkSize is how many valid rows are written per BulkWrite
+	importChunkSize = 500
+
+	// maxImportErrors caps the row errors listed in an import report
+	maxImportErrors = 100
+)
+
+// ImportReport summarises an import
+// @Description Catalogue import summary
+type ImportReport struct {
+	DryRun  bool `json:"dryRun"`
+	Rows    int  `json:"rows"`
+	Valid   int  `json:"valid"`
+	Created int  `json:"created"`
+	Updated int  `json:"updated"`
+	Failed  int  `json:"failed"`
+	// Errors lists the first 100 failed rows; ErrorsTruncated is set when there were more
+	Errors          []ImportError `json:"errors"`
+	ErrorsTruncated bool          `json:"errorsTruncated,omitempty"`
+	// Aborted is the line the import stopped at when it could not carry on.
+	// Rows before it were imported as counted; it and later rows were not.
+	Aborted *ImportError `json:"aborted,omitempty"`
+}
+
+// ImportError describes why one row was not imported
+// @Description Catalogue import row error
+type ImportError struct {
+	Line    int    `json:"line"`
+	ID      string `json:"id,omitempty"`
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// fail records a failed row
+func (r *ImportReport) fail(line int, id string, status int, message string) {
+	r.Failed++
+	if len(r.Errors) == maxImportErrors {
+		r.ErrorsTruncated = true
+		return
+	}
+	r.Errors = append(r.Errors, ImportError{Line: line, ID: id, Status: status, Message: message})
+}
+
+// abortImport answers an import that cannot carry on. Earlier chunks are
+// already written, so instead of a bare problem the client gets the report so
+// far with the problem's status, and the line the import stopped at.
+func abortImport(c *gin.Context, report *ImportReport, line int, p problem.Problem) {
+	report.Aborted = &ImportError{Line: line, Status: p.Status, Message: p.Detail}
+	c.JSON(p.Status, report)
+}
+
+// ExportProducts godoc
+// @Summary Export the catalogue
+// @Description Stream every product matching the filters as CSV or newline-delimited JSON.
+// @Description Products are read from the repository page by page, never all at once. The cosmos
+// @Description backend sorts each page in process and so re-reads every remaining match per page;
+// @Description it refuses exports matching more than 10,000 products with 501, so narrow those
+// @Description with filters.
+// @Tags catalogue
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string true "csv or ndjson"
+// @Param sort query string false "Comma-separated sort fields, '-' prefix for descending (e.g. price,-createdAt)"
+// @Param minPrice query number false "Minimum price (inclusive)"
+// @Param maxPrice query number false "Maximum price (inclusive)"
+// @Param inStock query bool false "Only products with (true) or without (false) inventory"
+// @Param namePrefix query string false "Name prefix"
+// @Success 200 {string} string "Products in the requested format"
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 501 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/export [get]
+func (h *ProductHandler) ExportProducts(c *gin.Context) {
+	format, err := catalogue.ParseFormat(c.Query("format"))
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	query, err := parseProductQuery(c)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	query.Limit = database.MaxPageSize
+	query.Cursor = ""
+
+	// Read the first page before committing to a 200 so early failures
+	// still get a problem response
+	ctx := c.Request.Context()
+	page, err := h.repo.QueryProducts(ctx, query)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", catalogue.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+	c.Status(http.StatusOK)
+
+	encoder := catalogue.NewEncoder(c.Writer, format)
+	for {
+		for _, product := range page.Products {
+			if err := encoder.Encode(product); err != nil {
+				// The client went away
+				return
+			}
+		}
+		if err := encoder.Flush(); err != nil {
+			return
+		}
+		c.Writer.Flush()
+
+		if page.NextCursor == "" {
+			return
+		}
+		query.Cursor = page.NextCursor
+		if page, err = h.repo.QueryProducts(ctx, query); err != nil {
+			// The status line has already been sent, so all that is left is to stop
+			requestid.Printf(ctx, "Export aborted after a partial response: %v", err)
+			return
+		}
+	}
+}
+
+// ImportProducts godoc
+// @Summary Import the catalogue
+// @Description Create or update products from CSV (with a header row) or newline-delimited JSON.
+// @Description Rows with an id that exists update that product; other rows create one. Every row
+// @Description is validated like a single create and failures are reported per line without
+// @Description stopping the import. With dryRun=true rows are only validated. An import that cannot
+// @Description carry on, because the input breaks off or a write fails, answers with that error's status
+// @Description and the report so far, whose aborted entry names the line it stopped at.
+// @Tags catalogue
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param format query string false "csv or ndjson (defaults to the Content-Type)"
+// @Param dryRun query bool false "Validate without writing"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} ImportReport
+// @Failure 400 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/import [post]
+func (h *ProductHandler) ImportProducts(c *gin.Context) {
+	formatParam := c.Query("format")
+	if formatParam == "" {
+		formatParam = c.ContentType()
+	}
+	format, err := catalogue.ParseFormat(formatParam)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	report := ImportReport{Errors: []ImportError{}}
+	if value := c.Query("dryRun"); value != "" {
+		if report.DryRun, err = strconv.ParseBool(value); err != nil {
+			badRequest(c, errors.New("dryRun must be true or false"))
+			return
+		}
+	}
+
+	decoder, err := catalogue.NewDecoder(c.Request.Body, format)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	chunk := make([]database.BulkOperation, 0, importChunkSize)
+	lines := make([]int, 0, importChunkSize)
+	flush := func() bool {
+		if len(chunk) == 0 || report.DryRun {
+			chunk, lines = chunk[:0], lines[:0]
+			return true
+		}
+		results, err := h.repo.BulkWrite(ctx, chunk, false)
+		if err != nil {
+			abortImport(c, &report, lines[0], reportError(c, err))
+			return false
+		}
+		for i, result := range results {
+			switch {
+			case result.Err != nil:
+				p := problemFor(result.Err)
+				report.fail(lines[i], chunk[i].Product.ID, p.Status, p.Detail)
+			case result.Product.Version == 1:
+				report.Created++
+			default:
+				report.Updated++
+			}
+		}
+		chunk, lines = chunk[:0], lines[:0]
+		return true
+	}
+
+	for {
+		row, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if !flush() {
+				return
+			}
+			detail := fmt.Sprintf("reading line %d: %v", row.Line, err)
+			abortImport(c, &report, row.Line, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, detail))
+			return
+		}
+
+		report.Rows++
+		if row.Err == nil {
+			row.Err = binding.Validator.ValidateStruct(&row.Product)
+		}
+		if row.Err != nil {
+			report.fail(row.Line, row.Product.ID, http.StatusBadRequest, row.Err.Error())
+			continue
+		}
+		report.Valid++
+
+		chunk = append(chunk, database.BulkOperation{Op: database.BulkUpsert, Product: row.Product})
+		lines = append(lines, row.Line)
+		if len(chunk) == importChunkSize && !flush() {
+			return
+		}
+	}
+	if !flush() {
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/internal/events"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/tenancy"
+)
+
+// defaultHeartbeat is how often an idle change stream sends a keep-alive
+// comment so proxies do not close it
+const defaultHeartbeat = 15 * time.Second
+
+// ChangeFeedHandler streams product changes as Server-Sent Events
+type ChangeFeedHandler struct {
+	feed      *events.Feed
+	heartbeat time.Duration
+}
+
+// NewChangeFeedHandler creates a new change feed handler
+func NewChangeFeedHandler(feed *events.Feed) *ChangeFeedHandler {
+	return &ChangeFeedHandler{feed: feed, heartbeat: defaultHeartbeat}
+}
+
+// StreamChanges godoc
+// @Summary Stream product changes
+// @Description Stream product domain events as Server-Sent Events. Each message has the event type as its
+// @Description "event", a sequence number as its "id" and the event as JSON "data". A reconnecting client sends
+// @Description Last-Event-ID (or ?lastEventId=) to resume after that sequence number from a bounded buffer; if
+// @Description changes were missed in between, a "reset" message is sent first and the client should reload.
+// @Tags products
+// @Produce text/event-stream
+// @Param productId query string false "Only changes to this product"
+// @Param type query string false "Comma-separated event types, e.g. InventoryChanged,StockDepleted"
+// @Param lastEventId query string false "Resume after this sequence number"
+// @Param Last-Event-ID header string false "Resume after this sequence number"
+// @Success 200 {string} string "Event stream"
+// @Failure 400 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/changes [get]
+func (h *ChangeFeedHandler) StreamChanges(c *gin.Context) {
+	filter := events.Filter{ProductID: c.Query("productId"), Tenant: tenancy.FromContext(c.Request.Context())}
+	if types := c.Query("type"); types != "" {
+		filter.Types = make(map[string]bool)
+		for _, eventType := range strings.Split(types, ",") {
+			eventType = strings.TrimSpace(eventType)
+			if !isEventType(eventType) {
+				badRequest(c, fmt.Errorf("unknown event type %q", eventType))
+				return
+			}
+			filter.Types[eventType] = true
+		}
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+	after := h.feed.Seq()
+	if lastEventID != "" {
+		seq, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			badRequest(c, fmt.Errorf("invalid Last-Event-ID %q", lastEventID))
+			return
+		}
+		after = seq
+	}
+
+	backlog, sub, complete := h.feed.Subscribe(after, filter)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if !complete {
+		c.Render(-1, sse.Event{Event: "reset", Data: "changes since the last event are no longer available"})
+	}
+	for _, change := range backlog {
+		renderChange(c, change)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and resumes
+				return
+			}
+			renderChange(c, change)
+		case <-heartbeat.C:
+			c.Writer.WriteString(": keep-alive\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+// renderChange writes one change as a Server-Sent Event
+func renderChange(c *gin.Context, change events.Change) {
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatUint(change.Seq, 10),
+		Event: change.Event.Type,
+		Data:  change.Event,
+	})
+}
+
+// isEventType reports whether eventType names a domain event
+func isEventType(eventType string) bool {
+	for _, known := range models.EventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/events"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// sseMessage is one parsed Server-Sent Event
+type sseMessage struct {
+	id, event, data string
+}
+
+// openStream connects to the change feed and returns a function reading the
+// next message, skipping keep-alive comments
+func openStream(t *testing.T, ctx context.Context, url string, lastEventID string) (*http.Response, func() sseMessage) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	reader := bufio.NewReader(resp.Body)
+	return resp, func() sseMessage {
+		var message sseMessage
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case line == "":
+				if message != (sseMessage{}) {
+					return message
+				}
+			case strings.HasPrefix(line, "id:"):
+				message.id = strings.TrimSpace(line[3:])
+			case strings.HasPrefix(line, "event:"):
+				message.event = strings.TrimSpace(line[6:])
+			case strings.HasPrefix(line, "data:"):
+				message.data = strings.TrimSpace(line[5:])
+			}
+		}
+	}
+}
+
+func TestChangeFeed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	repo := database.NewInMemoryRepository()
+	feed := events.NewFeed(100)
+	dispatcher := events.NewDispatcher(repo, feed, 100, time.Second)
+	handler := NewChangeFeedHandler(feed)
+	handler.heartbeat = 10 * time.Millisecond
+
+	router := gin.New()
+	router.GET("/api/products/changes", handler.StreamChanges)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	product, err := repo.CreateProduct(ctx, models.Product{Name: "Streamed", Description: "Test Description", Price: 5.0, InventoryCount: 1})
+	require.NoError(t, err)
+	_, err = dispatcher.DispatchOnce(ctx)
+	require.NoError(t, err)
+
+	// Test that live changes stream with increasing IDs and respect filters
+	t.Run("Live", func(t *testing.T) {
+		resp, next := openStream(t, ctx, server.URL+"/api/products/changes?productId="+product.ID+"&type=InventoryChanged,StockDepleted", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		_, err := repo.AdjustInventory(ctx, product.ID, models.InventoryAdjustment{Delta: -1, Reason: "sale"})
+		require.NoError(t, err)
+		_, err = dispatcher.DispatchOnce(ctx)
+		require.NoError(t, err)
+
+		first := next()
+		assert.Equal(t, "2", first.id, "ProductCreated was 1 and is not replayed")
+		assert.Equal(t, models.EventInventoryChanged, first.event)
+		var event models.Event
+		require.NoError(t, json.Unmarshal([]byte(first.data), &event))
+		assert.Equal(t, product.ID, event.ProductID)
+		assert.Equal(t, 0, event.Product.InventoryCount)
+
+		second := next()
+		assert.Equal(t, "3", second.id)
+		assert.Equal(t, models.EventStockDepleted, second.event)
+	})
+
+	// Test resuming after the last event seen
+	t.Run("Resume", func(t *testing.T) {
+		_, next := openStream(t, ctx, server.URL+"/api/products/changes", "1")
+		assert.Equal(t, "2", next().id)
+		assert.Equal(t, "3", next().id)
+	})
+
+	// Test that a resume point from before a restart asks the client to reload
+	t.Run("Reset", func(t *testing.T) {
+		_, next := openStream(t, ctx, server.URL+"/api/products/changes", "42")
+		assert.Equal(t, "reset", next().event)
+	})
+
+	// Test that invalid parameters are rejected before streaming
+	t.Run("BadRequest", func(t *testing.T) {
+		for _, query := range []string{"?type=SomethingHappened", "?lastEventId=abc"} {
+			resp, err := http.Get(server.URL + "/api/products/changes" + query)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/apikeys"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/patch"
+	"github.com/yourusername/product-service/internal/requestid"
+	"github.com/yourusername/product-service/internal/webhooks"
+)
+
+// validationError marks an error raised while validating a document the
+// handler built itself (such as a patched product) so it is reported as a 400
+type validationError struct {
+	err error
+}
+
+func (e *validationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *validationError) Unwrap() error {
+	return e.err
+}
+
+// writeError translates a repository error into a problem response. Errors
+// the repository does not classify are logged and reported as 500 without
+// leaking their text to the client.
+func writeError(c *gin.Context, err error) {
+	p := reportError(c, err)
+	problem.Write(c, p.Status, p.Code, p.Detail)
+}
+
+// reportError is writeError for handlers that answer with a body of their
+// own: it logs the error and sets any headers, and returns the problem
+func reportError(c *gin.Context, err error) problem.Problem {
+	p := problemFor(err)
+	switch {
+	case errors.Is(err, database.ErrUnavailable):
+		requestid.Printf(c.Request.Context(), "Storage unavailable: %v", err)
+		c.Header("Retry-After", "1")
+	case p.Status == http.StatusInternalServerError:
+		requestid.Printf(c.Request.Context(), "Internal error: %v", err)
+	}
+	return p
+}
+
+// problemFor classifies an error as the problem reported to the client
+func problemFor(err error) problem.Problem {
+	var invalid *validationError
+	switch {
+	case errors.As(err, &invalid):
+		return problem.New(http.StatusBadRequest, problem.CodeValidationFailed, invalid.err.Error())
+	case errors.Is(err, patch.ErrTestFailed):
+		return problem.New(http.StatusConflict, problem.CodePatchTestFailed, err.Error())
+	case errors.Is(err, patch.ErrInvalidPatch):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidPatch, err.Error())
+	case errors.Is(err, database.ErrNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "Product not found")
+	case errors.Is(err, database.ErrReservationNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "Reservation not found")
+	case errors.Is(err, database.ErrRevisionNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "Revision not found")
+	case errors.Is(err, webhooks.ErrNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "Webhook not found")
+	case errors.Is(err, webhooks.ErrDeliveryNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "Webhook delivery not found")
+	case errors.Is(err, webhooks.ErrNotDead):
+		return problem.New(http.StatusConflict, problem.CodeConflict, "Webhook delivery is not dead-lettered")
+	case errors.Is(err, apikeys.ErrNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "API key not found")
+	case errors.Is(err, apikeys.ErrRevoked):
+		return problem.New(http.StatusConflict, problem.CodeConflict, "API key has been revoked")
+	case errors.Is(err, database.ErrConflict):
+		return problem.New(http.StatusConflict, problem.CodeConflict, "A product with this ID already exists")
+	case errors.Is(err, database.ErrPreconditionFailed):
+		return problem.New(http.StatusPreconditionFailed, problem.CodePreconditionFailed, "Product was modified concurrently")
+	case errors.Is(err, database.ErrInsufficientInventory):
+		return problem.New(http.StatusConflict, problem.CodeInsufficientInventory, err.Error())
+	case errors.Is(err, database.ErrReservationExpired):
+		return problem.New(http.StatusConflict, problem.CodeReservationExpired, "Reservation has expired")
+	case errors.Is(err, database.ErrBatchAborted):
+		return problem.New(http.StatusFailedDependency, problem.CodeBatchAborted, "Not applied because another operation in the batch failed")
+	case errors.Is(err, database.ErrUnknownTenant):
+		return problem.New(http.StatusBadRequest, problem.CodeUnknownTenant, err.Error())
+	case errors.Is(err, database.ErrQuotaExceeded):
+		return problem.New(http.StatusForbidden, problem.CodeQuotaExceeded, err.Error())
+	case errors.Is(err, database.ErrNotSupported):
+		return problem.New(http.StatusNotImplemented, problem.CodeNotSupported, err.Error())
+	case errors.Is(err, database.ErrInvalidCursor):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidCursor, err.Error())
+	case errors.Is(err, database.ErrUnavailable):
+		return problem.New(http.StatusServiceUnavailable, problem.CodeUnavailable, "Storage is temporarily unavailable")
+	case errors.Is(err, context.DeadlineExceeded):
+		return problem.New(http.StatusGatewayTimeout, problem.CodeTimeout, "Request timed out")
+	case errors.Is(err, context.Canceled):
+		// The client has gone away; the status is only seen in logs
+		return problem.New(http.StatusServiceUnavailable, problem.CodeUnavailable, "Request was cancelled")
+	default:
+		return problem.New(http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+	}
+}
+
+// badRequest reports a request that failed binding or validation
+func badRequest(c *gin.Context, err error) {
+	problem.Write(c, http.StatusBadRequest, problem.CodeValidationFailed, err.Error())
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// etag returns the strong entity tag of a product, derived from its version
+func etag(product models.Product) string {
+	return `"` + strconv.FormatInt(product.Version, 10) + `"`
+}
+
+// setETag adds the ETag header for a product to the response
+func setETag(c *gin.Context, product models.Product) {
+	c.Header("ETag", etag(product))
+}
+
+// etagMatches reports whether an If-Match/If-None-Match header value lists the
+// product's entity tag. If-Match uses strong comparison, so weak tags only match
+// when weak is set (If-None-Match); every tag we issue is strong.
+func etagMatches(header string, product models.Product, weak bool) bool {
+	current := etag(product)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPreconditions evaluates If-Match and If-None-Match against the current
+// product. It returns the version a conditional write must be made against
+// (0 when the request is unconditional) and false when the request must be
+// rejected with 412 Precondition Failed.
+func checkPreconditions(c *gin.Context, current models.Product) (int64, bool) {
+	ifMatch := c.GetHeader("If-Match")
+	ifNoneMatch := c.GetHeader("If-None-Match")
+
+	if ifMatch != "" && !etagMatches(ifMatch, current, false) {
+		return 0, false
+	}
+	if ifNoneMatch != "" && etagMatches(ifNoneMatch, current, true) {
+		return 0, false
+	}
+	if ifMatch != "" || ifNoneMatch != "" {
+		// Pin the write to the version the preconditions were evaluated against
+		return current.Version, true
+	}
+
+	return 0, true
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/database"
+)
+
+// GetProductHistory godoc
+// @Summary Get product history
+// @Description List every revision of a product, oldest first: when it changed, who changed it (the X-Actor header), which fields changed and the resulting state. History is kept after a delete.
+// @Tags history
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {array} models.Revision
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/{id}/history [get]
+func (h *ProductHandler) GetProductHistory(c *gin.Context) {
+	history, err := h.repo.GetProductHistory(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// RestoreProduct godoc
+// @Summary Restore a product revision
+// @Description Write the name, description and price recorded by a revision back as a new revision. The inventory count is always left as it is.
+// @Tags history
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param seq path int true "Revision number"
+// @Param If-Match header string false "Only restore if the product still has this ETag"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} models.Product
+// @Header 200 {string} ETag "Product version tag"
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 412 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/{id}/history/{seq}/restore [post]
+func (h *ProductHandler) RestoreProduct(c *gin.Context) {
+	id := c.Param("id")
+	seq, err := strconv.ParseInt(c.Param("seq"), 10, 64)
+	if err != nil || seq <= 0 {
+		badRequest(c, errors.New("revision number must be a positive integer"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	var version int64
+	current, err := h.repo.GetProductByID(ctx, id)
+	switch {
+	case err == nil:
+		var ok bool
+		if version, ok = checkPreconditions(c, current); !ok {
+			preconditionFailed(c, current)
+			return
+		}
+	case errors.Is(err, database.ErrNotFound):
+		// A deleted product has no ETag for If-Match to name
+		if c.GetHeader("If-Match") != "" {
+			problem.Write(c, http.StatusPreconditionFailed, problem.CodePreconditionFailed, "Product does not match the request preconditions")
+			return
+		}
+	default:
+		writeError(c, err)
+		return
+	}
+
+	restored, err := h.repo.RestoreProduct(ctx, id, seq, version)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	setETag(c, restored)
+	c.JSON(http.StatusOK, restored)
+}
@@ -1,10 +1,13 @@
 package handlers
 
 import (
-	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/api/problem"
 	"github.com/yourusername/product-service/internal/database"
 	"github.com/yourusername/product-service/internal/models"
 )
@@ -23,42 +26,117 @@ func NewProductHandler(repo database.ProductRepository) *ProductHandler {
 
 // GetProducts godoc
 // @Summary Get all products
-// @Description Get a list of all products
+// @Description Get a page of products, optionally filtered and sorted. When more results exist the
+// @Description response carries a Link header with rel="next" and the cursor in X-Next-Cursor.
 // @Tags products
 // @Accept json
 // @Produce json
+// @Param limit query int false "Page size (1-500, default 50)"
+// @Param cursor query string false "Opaque cursor from a previous page"
+// @Param sort query string false "Comma-separated sort fields, '-' prefix for descending (e.g. price,-createdAt)"
+// @Param minPrice query number false "Minimum price (inclusive)"
+// @Param maxPrice query number false "Maximum price (inclusive)"
+// @Param inStock query bool false "Only products with (true) or without (false) inventory"
+// @Param namePrefix query string false "Name prefix"
+// @Param createdAfter query string false "Created at or after (RFC 3339)"
+// @Param createdBefore query string false "Created before (RFC 3339)"
+// @Param updatedAfter query string false "Updated at or after (RFC 3339)"
+// @Param updatedBefore query string false "Updated before (RFC 3339)"
 // @Success 200 {array} models.Product
-// @Failure 500 {object} map[string]interface{}
+// @Header 200 {string} Link "Next page link"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page"
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 501 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/products [get]
 func (h *ProductHandler) GetProducts(c *gin.Context) {
-	ctx := context.Background()
-	products, err := h.repo.GetProducts(ctx)
+	h.listProducts(c, false)
+}
+
+// listProducts answers a product listing, of the trash when deleted is set
+func (h *ProductHandler) listProducts(c *gin.Context, deleted bool) {
+	query, err := parseProductQuery(c)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	query.Filter.Deleted = deleted
+	
+	ctx := c.Request.Context()
+	page, err := h.repo.QueryProducts(ctx, query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 	
-	c.JSON(http.StatusOK, products)
+	if page.NextCursor != "" {
+		c.Header("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextPageURL(c, page.NextCursor)))
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	
+	c.JSON(http.StatusOK, page.Products)
 }
 
 // GetProductByID godoc
 // @Summary Get product by ID
-// @Description Get a product by its ID
+// @Description Get a product by its ID, or as it stood at the time given by asOf
 // @Tags products
 // @Accept json
 // @Produce json
 // @Param id path string true "Product ID"
+// @Param asOf query string false "RFC 3339 timestamp to read the product as of"
+// @Param If-None-Match header string false "Return 304 if the product still has this ETag"
 // @Success 200 {object} models.Product
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Success 304 "Not modified"
+// @Header 200 {string} ETag "Product version tag"
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/products/{id} [get]
 func (h *ProductHandler) GetProductByID(c *gin.Context) {
 	id := c.Param("id")
 	
-	ctx := context.Background()
+	ctx := c.Request.Context()
+	if asOf := c.Query("asOf"); asOf != "" {
+		h.getProductAsOf(c, id, asOf)
+		return
+	}
+	
 	product, err := h.repo.GetProductByID(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		writeError(c, err)
+		return
+	}
+	
+	setETag(c, product)
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, product, true) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	
+	c.JSON(http.StatusOK, product)
+}
+
+// getProductAsOf answers a point-in-time read. A past state is not the current
+// representation, so it carries no ETag to make conditional requests against.
+func (h *ProductHandler) getProductAsOf(c *gin.Context, id, asOf string) {
+	at, err := time.Parse(time.RFC3339Nano, asOf)
+	if err != nil {
+		badRequest(c, errors.New("asOf must be an RFC 3339 timestamp"))
+		return
+	}
+	
+	product, err := h.repo.GetProductAsOf(c.Request.Context(), id, at)
+	if err != nil {
+		writeError(c, err)
 		return
 	}
 	
@@ -72,102 +150,247 @@ func (h *ProductHandler) GetProductByID(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param product body models.Product true "Product information"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
 // @Success 201 {object} models.Product
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Header 201 {string} ETag "Product version tag"
+// @Failure 400 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/products [post]
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	var product models.Product
 	if err := c.ShouldBindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 	
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	createdProduct, err := h.repo.CreateProduct(ctx, product)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 	
+	setETag(c, createdProduct)
 	c.JSON(http.StatusCreated, createdProduct)
 }
 
 // UpdateProduct godoc
 // @Summary Update a product
-// @Description Update a product with the provided information
+// @Description Update a product with the provided information. Send If-Match with the ETag from a
+// @Description previous read to reject the update if someone else changed the product in between.
+// @Description If-None-Match: * creates the product under the given ID if it does not exist yet.
+// @Description The inventory count may not be set below the quantity held by reservations.
 // @Tags products
 // @Accept json
 // @Produce json
 // @Param id path string true "Product ID"
+// @Param If-Match header string false "Only update if the product still has this ETag"
+// @Param If-None-Match header string false "Only update if the product does not have this ETag (* = only create)"
 // @Param product body models.Product true "Product information"
 // @Success 200 {object} models.Product
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Success 201 {object} models.Product
+// @Header 200 {string} ETag "Product version tag"
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 412 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/products/{id} [put]
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	id := c.Param("id")
 	
 	var product models.Product
 	if err := c.ShouldBindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 	
 	// Ensure ID in URL matches ID in body
 	product.ID = id
 	
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	
 	// Check if product exists
-	_, err := h.repo.GetProductByID(ctx, id)
+	current, err := h.repo.GetProductByID(ctx, id)
+	if errors.Is(err, database.ErrNotFound) && c.GetHeader("If-None-Match") == "*" {
+		h.createWithID(c, product)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		writeError(c, err)
 		return
 	}
 	
-	// Update product
-	if err := h.repo.UpdateProduct(ctx, product); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Evaluate If-Match / If-None-Match against the current version
+	version, ok := checkPreconditions(c, current)
+	if !ok {
+		preconditionFailed(c, current)
 		return
 	}
 	
-	c.JSON(http.StatusOK, product)
+	// Update product, atomically re-checking the version when conditional
+	product.Version = version
+	updatedProduct, err := h.repo.UpdateProduct(ctx, product)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	
+	setETag(c, updatedProduct)
+	c.JSON(http.StatusOK, updatedProduct)
+}
+
+// PatchProduct godoc
+// @Summary Partially update a product
+// @Description Apply a JSON Merge Patch (RFC 7396, application/merge-patch+json) or a JSON Patch
+// @Description (RFC 6902, application/json-patch+json) to a product. The patched product must pass
+// @Description the same validation as a create, and may not set the inventory count below the
+// @Description quantity held by reservations. Send If-Match to only patch a known version.
+// @Tags products
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param If-Match header string false "Only patch if the product still has this ETag"
+// @Param patch body object true "Merge patch object or JSON Patch operations array"
+// @Success 200 {object} models.Product
+// @Header 200 {string} ETag "Product version tag"
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 412 {object} problem.Problem
+// @Failure 415 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/{id} [patch]
+func (h *ProductHandler) PatchProduct(c *gin.Context) {
+	id := c.Param("id")
+	
+	apply, ok := patchFuncFor(c.ContentType())
+	if !ok {
+		c.Header("Accept-Patch", acceptPatch)
+		problem.Write(c, http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType,
+			"PATCH accepts "+acceptPatch)
+		return
+	}
+	
+	body, err := c.GetRawData()
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	
+	ctx := c.Request.Context()
+	
+	// Evaluate If-Match / If-None-Match against the current version
+	current, err := h.repo.GetProductByID(ctx, id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	version, ok := checkPreconditions(c, current)
+	if !ok {
+		preconditionFailed(c, current)
+		return
+	}
+	
+	// Apply the patch to whatever is stored at write time, atomically
+	patchedProduct, err := h.repo.PatchProduct(ctx, id, version, func(product models.Product) (models.Product, error) {
+		return patchProduct(product, body, apply)
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	
+	setETag(c, patchedProduct)
+	c.JSON(http.StatusOK, patchedProduct)
+}
+
+// createWithID handles PUT with If-None-Match: * for a product that does not exist yet
+func (h *ProductHandler) createWithID(c *gin.Context, product models.Product) {
+	createdProduct, err := h.repo.CreateProduct(c.Request.Context(), product)
+	if errors.Is(err, database.ErrConflict) {
+		// Someone else created it between our read and write
+		problem.Write(c, http.StatusPreconditionFailed, problem.CodePreconditionFailed, "Product already exists")
+		return
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	
+	setETag(c, createdProduct)
+	c.JSON(http.StatusCreated, createdProduct)
 }
 
 // DeleteProduct godoc
 // @Summary Delete a product
-// @Description Delete a product by its ID
+// @Description Move a product to the trash. It can be restored until it is purged after the
+// @Description retention period. Send If-Match with the ETag from a previous read to
+// @Description reject the delete if someone else changed the product in between.
 // @Tags products
 // @Accept json
 // @Produce json
 // @Param id path string true "Product ID"
+// @Param If-Match header string false "Only delete if the product still has this ETag"
+// @Param If-None-Match header string false "Only delete if the product does not have this ETag"
 // @Success 204 {object} nil
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 404 {object} problem.Problem
+// @Failure 412 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/products/{id} [delete]
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	id := c.Param("id")
 	
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	
 	// Check if product exists
-	_, err := h.repo.GetProductByID(ctx, id)
+	current, err := h.repo.GetProductByID(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		writeError(c, err)
 		return
 	}
 	
-	// Delete product
-	if err := h.repo.DeleteProduct(ctx, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Evaluate If-Match / If-None-Match against the current version
+	version, ok := checkPreconditions(c, current)
+	if !ok {
+		preconditionFailed(c, current)
+		return
+	}
+	
+	// Delete product, atomically re-checking the version when conditional
+	if err := h.repo.DeleteProduct(ctx, id, version); err != nil {
+		writeError(c, err)
 		return
 	}
 	
 	c.Status(http.StatusNoContent)
 }
 
+// preconditionFailed answers 412 and tells the client the current ETag
+func preconditionFailed(c *gin.Context, current models.Product) {
+	setETag(c, current)
+	problem.Write(c, http.StatusPreconditionFailed, problem.CodePreconditionFailed, "Product does not match the request preconditions")
+}
+
 // CheckProductAvailability godoc
 // @Summary Check product availability
 // @Description Check if a product is available (has inventory)
@@ -176,16 +399,59 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Product ID"
 // @Success 200 {object} models.ProductAvailability
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/products/{id}/availability [get]
 func (h *ProductHandler) CheckProductAvailability(c *gin.Context) {
 	id := c.Param("id")
 	
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	availability, err := h.repo.CheckProductAvailability(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		writeError(c, err)
+		return
+	}
+	
+	c.JSON(http.StatusOK, availability)
+}
+
+// AdjustInventory godoc
+// @Summary Adjust product inventory
+// @Description Atomically add a signed delta to a product's inventory count. Adjustments that would take the count below zero are refused.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param adjustment body models.InventoryAdjustment true "Signed delta and reason (restock, sale, return, damage or correction)"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} models.ProductAvailability
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/{id}/inventory/adjustments [post]
+func (h *ProductHandler) AdjustInventory(c *gin.Context) {
+	id := c.Param("id")
+	
+	var adjustment models.InventoryAdjustment
+	if err := c.ShouldBindJSON(&adjustment); err != nil {
+		badRequest(c, err)
+		return
+	}
+	
+	ctx := c.Request.Context()
+	availability, err := h.repo.AdjustInventory(ctx, id, adjustment)
+	if err != nil {
+		writeError(c, err)
 		return
 	}
 	
@@ -3,13 +3,23 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/api/middleware"
+	"github.com/yourusername/product-service/api/problem"
 	"github.com/yourusername/product-service/internal/database"
 	"github.com/yourusername/product-service/internal/models"
 )
@@ -20,15 +30,31 @@ func setupRouter() (*gin.Engine, *database.InMemoryRepository) {
 
 	router := gin.Default()
 	api := router.Group("/api")
+	api.Use(middleware.Actor())
 	{
+		api.POST("/products:method", CustomMethods(map[string]gin.HandlerFunc{
+			":batch": productHandler.BulkWrite,
+		}))
 		products := api.Group("/products")
 		{
 			products.GET("", productHandler.GetProducts)
+			products.GET("/trash", productHandler.GetTrash)
+			products.GET("/export", productHandler.ExportProducts)
+			products.POST("/import", productHandler.ImportProducts)
 			products.GET("/:id", productHandler.GetProductByID)
 			products.POST("", productHandler.CreateProduct)
 			products.PUT("/:id", productHandler.UpdateProduct)
+			products.PATCH("/:id", productHandler.PatchProduct)
 			products.DELETE("/:id", productHandler.DeleteProduct)
+			products.POST("/:id/restore", productHandler.UndeleteProduct)
 			products.GET("/:id/availability", productHandler.CheckProductAvailability)
+			products.GET("/:id/history", productHandler.GetProductHistory)
+			products.POST("/:id/history/:seq/restore", productHandler.RestoreProduct)
+			products.POST("/:id/inventory/adjustments", productHandler.AdjustInventory)
+			products.POST("/:id/reservations", productHandler.ReserveInventory)
+			products.GET("/:id/reservations/:reservationId", productHandler.GetReservation)
+			products.POST("/:id/reservations/:reservationId/confirm", productHandler.ConfirmReservation)
+			products.POST("/:id/reservations/:reservationId/release", productHandler.ReleaseReservation)
 		}
 	}
 	return router, repo
@@ -61,7 +87,7 @@ func TestProductHandlers(t *testing.T) {
 
 	// Test GetProductByID
 	t.Run("GetProductByID", func(t *testing.T) {
-		products, _ := repo.GetProducts(nil)
+		products, _ := repo.GetProducts(context.Background())
 		productID := products[0].ID
 
 		req, _ := http.NewRequest(http.MethodGet, "/api/products/"+productID, nil)
@@ -73,7 +99,7 @@ func TestProductHandlers(t *testing.T) {
 
 	// Test UpdateProduct
 	t.Run("UpdateProduct", func(t *testing.T) {
-		products, _ := repo.GetProducts(nil)
+		products, _ := repo.GetProducts(context.Background())
 		product := products[0]
 		product.Name = "Updated Product"
 
@@ -89,7 +115,7 @@ func TestProductHandlers(t *testing.T) {
 
 	// Test DeleteProduct
 	t.Run("DeleteProduct", func(t *testing.T) {
-		products, _ := repo.GetProducts(nil)
+		products, _ := repo.GetProducts(context.Background())
 		productID := products[0].ID
 
 		req, _ := http.NewRequest(http.MethodDelete, "/api/products/"+productID, nil)
@@ -102,7 +128,7 @@ func TestProductHandlers(t *testing.T) {
 	// Test CheckProductAvailability
 	t.Run("CheckProductAvailability", func(t *testing.T) {
 		product := models.Product{Name: "Available Product", Description: "Test Description", Price: 10.0, InventoryCount: 10}
-		createdProduct, _ := repo.CreateProduct(nil, product)
+		createdProduct, _ := repo.CreateProduct(context.Background(), product)
 
 		req, _ := http.NewRequest(http.MethodGet, "/api/products/"+createdProduct.ID+"/availability", nil)
 		w := httptest.NewRecorder()
@@ -112,3 +138,802 @@ func TestProductHandlers(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "true")
 	})
 }
+
+func TestGetProductsQuery(t *testing.T) {
+	router, repo := setupRouter()
+
+	for _, product := range []models.Product{
+		{Name: "Laptop", Description: "Test Description", Price: 1299.99, InventoryCount: 10},
+		{Name: "Smartphone", Description: "Test Description", Price: 899.99, InventoryCount: 0},
+		{Name: "Headphones", Description: "Test Description", Price: 249.99, InventoryCount: 20},
+	} {
+		_, err := repo.CreateProduct(context.Background(), product)
+		assert.NoError(t, err)
+	}
+
+	get := func(url string) (*httptest.ResponseRecorder, []models.Product) {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var products []models.Product
+		json.Unmarshal(w.Body.Bytes(), &products)
+		return w, products
+	}
+
+	// Test sorting with cursor pagination via the Link header
+	t.Run("SortAndPaginate", func(t *testing.T) {
+		w, products := get("/api/products?sort=-price&limit=2")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, products, 2)
+		assert.Equal(t, "Laptop", products[0].Name)
+		assert.Equal(t, "Smartphone", products[1].Name)
+
+		cursor := w.Header().Get("X-Next-Cursor")
+		assert.NotEmpty(t, cursor)
+		link := w.Header().Get("Link")
+		assert.Contains(t, link, `rel="next"`)
+		assert.Contains(t, link, "sort=-price")
+
+		next := link[strings.Index(link, "<")+1 : strings.Index(link, ">")]
+		w, products = get(next)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, products, 1)
+		assert.Equal(t, "Headphones", products[0].Name)
+		assert.Empty(t, w.Header().Get("Link"))
+	})
+
+	// Test filters
+	t.Run("Filter", func(t *testing.T) {
+		_, products := get("/api/products?inStock=true&maxPrice=1000")
+		assert.Len(t, products, 1)
+		assert.Equal(t, "Headphones", products[0].Name)
+
+		_, products = get("/api/products?namePrefix=Smart")
+		assert.Len(t, products, 1)
+
+		_, products = get("/api/products?createdAfter=2000-01-01T00:00:00Z&updatedBefore=2999-01-01T00:00:00Z")
+		assert.Len(t, products, 3)
+	})
+
+	// Test invalid parameters
+	t.Run("InvalidParameters", func(t *testing.T) {
+		for _, url := range []string{
+			"/api/products?limit=0",
+			"/api/products?limit=abc",
+			"/api/products?sort=description",
+			"/api/products?minPrice=cheap",
+			"/api/products?inStock=maybe",
+			"/api/products?createdAfter=yesterday",
+			"/api/products?cursor=garbage",
+		} {
+			w, _ := get(url)
+			assert.Equal(t, http.StatusBadRequest, w.Code, url)
+		}
+	})
+}
+
+func TestProductHandlersETag(t *testing.T) {
+	router, repo := setupRouter()
+	created, _ := repo.CreateProduct(context.Background(), models.Product{Name: "Tagged", Description: "Test Description", Price: 10.0, InventoryCount: 1})
+
+	send := func(method, url string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+		var reader *bytes.Buffer
+		if body != nil {
+			jsonValue, _ := json.Marshal(body)
+			reader = bytes.NewBuffer(jsonValue)
+		} else {
+			reader = bytes.NewBuffer(nil)
+		}
+		req, _ := http.NewRequest(method, url, reader)
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	update := models.Product{Name: "Retagged", Description: "Test Description", Price: 12.0, InventoryCount: 1}
+
+	// Test that GET returns the ETag and honours If-None-Match
+	t.Run("GetETag", func(t *testing.T) {
+		w := send(http.MethodGet, "/api/products/"+created.ID, nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+		w = send(http.MethodGet, "/api/products/"+created.ID, nil, map[string]string{"If-None-Match": `"1"`})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+	})
+
+	// Test that PUT with a stale If-Match is rejected with the current ETag
+	t.Run("PutStaleIfMatch", func(t *testing.T) {
+		w := send(http.MethodPut, "/api/products/"+created.ID, update, map[string]string{"If-Match": `"7"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	})
+
+	// Test that PUT with the current If-Match succeeds and returns the new ETag
+	t.Run("PutIfMatch", func(t *testing.T) {
+		w := send(http.MethodPut, "/api/products/"+created.ID, update, map[string]string{"If-Match": `"1"`})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+		// The same If-Match cannot be used twice
+		w = send(http.MethodPut, "/api/products/"+created.ID, update, map[string]string{"If-Match": `"1"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	// Test that If-None-Match: * only creates
+	t.Run("PutIfNoneMatch", func(t *testing.T) {
+		w := send(http.MethodPut, "/api/products/"+created.ID, update, map[string]string{"If-None-Match": "*"})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = send(http.MethodPut, "/api/products/new-product", update, map[string]string{"If-None-Match": "*"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	})
+
+	// Test that POST returns the ETag
+	t.Run("PostETag", func(t *testing.T) {
+		w := send(http.MethodPost, "/api/products", update, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	})
+
+	// Test conditional DELETE
+	t.Run("DeleteIfMatch", func(t *testing.T) {
+		w := send(http.MethodDelete, "/api/products/"+created.ID, nil, map[string]string{"If-Match": `"1"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = send(http.MethodDelete, "/api/products/"+created.ID, nil, map[string]string{"If-Match": `"2"`})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+}
+
+func TestPatchProduct(t *testing.T) {
+	router, repo := setupRouter()
+	created, _ := repo.CreateProduct(context.Background(), models.Product{Name: "Patchable", Description: "Test Description", Price: 10.0, InventoryCount: 3})
+	url := "/api/products/" + created.ID
+
+	patch := func(contentType, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPatch, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) models.Product {
+		var product models.Product
+		json.Unmarshal(w.Body.Bytes(), &product)
+		return product
+	}
+
+	// Test that a merge patch changes only the fields it names
+	t.Run("MergePatch", func(t *testing.T) {
+		w := patch("application/merge-patch+json", `{"price": 12.5}`, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		product := decode(w)
+		assert.Equal(t, 12.5, product.Price)
+		assert.Equal(t, "Patchable", product.Name)
+		assert.Equal(t, 3, product.InventoryCount)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	})
+
+	// Test a JSON Patch with a test operation guarding the change
+	t.Run("JSONPatch", func(t *testing.T) {
+		w := patch("application/json-patch+json", `[{"op":"test","path":"/price","value":12.5},{"op":"replace","path":"/name","value":"Patched"}]`, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Patched", decode(w).Name)
+
+		w = patch("application/json-patch+json", `[{"op":"test","path":"/price","value":1},{"op":"replace","path":"/name","value":"Lost"}]`, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), problem.CodePatchTestFailed)
+	})
+
+	// Test that the patched product is validated like a create
+	t.Run("Validation", func(t *testing.T) {
+		w := patch("application/merge-patch+json", `{"price": -1}`, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), problem.CodeValidationFailed)
+
+		w = patch("application/merge-patch+json", `{"name": null}`, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = patch("application/merge-patch+json", `{"price": "cheap"}`, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = patch("application/merge-patch+json", `{"id": "other"}`, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = patch("application/json-patch+json", `[{"op":"remove","path":"/missing"}]`, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), problem.CodeInvalidPatch)
+
+		product, _ := repo.GetProductByID(context.Background(), created.ID)
+		assert.Equal(t, "Patched", product.Name)
+	})
+
+	// Test conditional patches and unsupported media types
+	t.Run("PreconditionsAndMediaType", func(t *testing.T) {
+		w := patch("application/merge-patch+json", `{"price": 20}`, map[string]string{"If-Match": `"1"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = patch("application/merge-patch+json", `{"price": 20}`, map[string]string{"If-Match": `"3"`})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = patch("application/json", `{"price": 30}`, nil)
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Contains(t, w.Header().Get("Accept-Patch"), "application/merge-patch+json")
+	})
+}
+
+func TestBulkWrite(t *testing.T) {
+	router, repo := setupRouter()
+	existing, _ := repo.CreateProduct(context.Background(), models.Product{Name: "Existing", Description: "Test Description", Price: 10.0, InventoryCount: 1})
+
+	batch := func(request interface{}) (*httptest.ResponseRecorder, BatchResponse) {
+		jsonValue, _ := json.Marshal(request)
+		req, _ := http.NewRequest(http.MethodPost, "/api/products:batch", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response BatchResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	product := &models.Product{Name: "Batched", Description: "Test Description", Price: 5.0, InventoryCount: 1}
+
+	// Test a mixed batch with per-item statuses
+	t.Run("BestEffort", func(t *testing.T) {
+		w, response := batch(models.BatchRequest{Operations: []models.BatchOperation{
+			{Op: "create", Product: product},
+			{Op: "update", ID: existing.ID, Version: existing.Version, Product: product},
+			{Op: "update", ID: existing.ID, Version: existing.Version, Product: product},
+			{Op: "create", Product: &models.Product{Name: "No Price", Description: "Test Description"}},
+			{Op: "delete", ID: "missing"},
+		}})
+		assert.Equal(t, http.StatusMultiStatus, w.Code)
+		require.Len(t, response.Results, 5)
+
+		statuses := make([]int, len(response.Results))
+		for i, result := range response.Results {
+			assert.Equal(t, i, result.Index)
+			statuses[i] = result.Status
+		}
+		assert.Equal(t, []int{201, 200, 412, 400, 404}, statuses)
+		assert.Equal(t, "Batched", response.Results[0].Product.Name)
+		assert.Equal(t, problem.CodeValidationFailed, response.Results[3].Problem.Code)
+	})
+
+	// Test that an invalid item aborts an atomic batch
+	t.Run("Atomic", func(t *testing.T) {
+		before, _ := repo.GetProducts(context.Background())
+		w, response := batch(models.BatchRequest{Atomic: true, Operations: []models.BatchOperation{
+			{Op: "create", Product: product},
+			{Op: "delete"},
+		}})
+		assert.Equal(t, http.StatusMultiStatus, w.Code)
+		require.Len(t, response.Results, 2)
+		assert.Equal(t, http.StatusFailedDependency, response.Results[0].Status)
+		assert.Equal(t, problem.CodeBatchAborted, response.Results[0].Problem.Code)
+		assert.Equal(t, http.StatusBadRequest, response.Results[1].Status)
+
+		after, _ := repo.GetProducts(context.Background())
+		assert.Len(t, after, len(before))
+	})
+
+	// Test that the envelope itself is validated
+	t.Run("Invalid", func(t *testing.T) {
+		w, _ := batch(models.BatchRequest{})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/products:unknown", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestCatalogueImportExport(t *testing.T) {
+	router, repo := setupRouter()
+	existing, _ := repo.CreateProduct(context.Background(), models.Product{Name: "Existing", Description: "Test Description", Price: 10.0, InventoryCount: 1})
+
+	importCatalogue := func(query, contentType, body string) (*httptest.ResponseRecorder, ImportReport) {
+		req, _ := http.NewRequest(http.MethodPost, "/api/products/import"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var report ImportReport
+		json.Unmarshal(w.Body.Bytes(), &report)
+		return w, report
+	}
+	csvBody := "id,name,description,price,inventoryCount\n" +
+		existing.ID + ",Renamed,Test Description,12.5,4\n" +
+		",New,Test Description,3,1\n" +
+		",No Price,Test Description,,1\n" +
+		",Bad Price,Test Description,abc,1\n"
+
+	// Test that a dry run validates without writing
+	t.Run("DryRun", func(t *testing.T) {
+		w, report := importCatalogue("?dryRun=true", "text/csv", csvBody)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 4, report.Rows)
+		assert.Equal(t, 2, report.Valid)
+		assert.Equal(t, 0, report.Created+report.Updated)
+		require.Len(t, report.Errors, 2)
+		assert.Equal(t, []int{4, 5}, []int{report.Errors[0].Line, report.Errors[1].Line})
+
+		products, _ := repo.GetProducts(context.Background())
+		assert.Len(t, products, 1)
+	})
+
+	// Test that valid rows are upserted and invalid ones reported
+	t.Run("CSV", func(t *testing.T) {
+		w, report := importCatalogue("", "text/csv", csvBody)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 1, report.Updated)
+		assert.Equal(t, 2, report.Failed)
+
+		updated, _ := repo.GetProductByID(context.Background(), existing.ID)
+		assert.Equal(t, "Renamed", updated.Name)
+		assert.Equal(t, 4, updated.InventoryCount)
+	})
+
+	// Test NDJSON import selected by query parameter
+	t.Run("NDJSON", func(t *testing.T) {
+		body := `{"name":"Streamed","description":"Test Description","price":1,"inventoryCount":1}` + "\n" + `{"name":""}` + "\n"
+		w, report := importCatalogue("?format=ndjson", "application/octet-stream", body)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1, report.Created)
+		require.Len(t, report.Errors, 1)
+		assert.Equal(t, 2, report.Errors[0].Line)
+	})
+
+	// Test that unreadable input is rejected outright
+	t.Run("Invalid", func(t *testing.T) {
+		w, _ := importCatalogue("", "application/json", "{}")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w, _ = importCatalogue("", "text/csv", "name,colour\n")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test streaming the filtered catalogue back out
+	t.Run("Export", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/api/products/export?format=csv&sort=name", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "products.csv")
+
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		require.Len(t, lines, 4)
+		assert.True(t, strings.HasPrefix(lines[0], "id,name"))
+		assert.Contains(t, lines[1], "New")
+
+		req, _ = http.NewRequest(http.MethodGet, "/api/products/export?format=ndjson&namePrefix=Stream", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		assert.Equal(t, 1, strings.Count(w.Body.String(), "\n"))
+
+		req, _ = http.NewRequest(http.MethodGet, "/api/products/export?format=xml", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test that an import cut short reports what it had written before
+	t.Run("Aborted", func(t *testing.T) {
+		body := io.MultiReader(
+			strings.NewReader("name,description,price,inventoryCount\nPartial,Test Description,2,1\n"),
+			iotest.ErrReader(errors.New("connection reset")),
+		)
+		req, _ := http.NewRequest(http.MethodPost, "/api/products/import", body)
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var report ImportReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.Equal(t, 1, report.Created)
+		require.NotNil(t, report.Aborted)
+		assert.Equal(t, 3, report.Aborted.Line)
+		assert.Contains(t, report.Aborted.Message, "connection reset")
+
+		page, _ := repo.QueryProducts(context.Background(), database.ProductQuery{Filter: database.ProductFilter{NamePrefix: "Partial"}})
+		assert.Len(t, page.Products, 1)
+	})
+}
+
+func TestProductHistory(t *testing.T) {
+	router, repo := setupRouter()
+	ctx := database.WithActor(context.Background(), "alice")
+	created, _ := repo.CreateProduct(ctx, models.Product{Name: "Original", Description: "Test Description", Price: 10.0, InventoryCount: 1})
+	time.Sleep(2 * time.Millisecond)
+	updated := created
+	updated.Name = "Renamed"
+	updated, _ = repo.UpdateProduct(ctx, updated)
+
+	request := func(method, url string, header map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, nil)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Test listing the revisions
+	t.Run("GetProductHistory", func(t *testing.T) {
+		w := request(http.MethodGet, "/api/products/"+created.ID+"/history", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var history []models.Revision
+		json.Unmarshal(w.Body.Bytes(), &history)
+		require.Len(t, history, 2)
+		assert.Equal(t, "alice", history[1].Actor)
+		assert.Equal(t, []string{"name"}, history[1].Changes)
+
+		w = request(http.MethodGet, "/api/products/missing/history", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	// Test point-in-time reads
+	t.Run("AsOf", func(t *testing.T) {
+		w := request(http.MethodGet, "/api/products/"+created.ID+"?asOf="+created.UpdatedAt.Format(time.RFC3339Nano), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("ETag"))
+
+		var product models.Product
+		json.Unmarshal(w.Body.Bytes(), &product)
+		assert.Equal(t, "Original", product.Name)
+
+		w = request(http.MethodGet, "/api/products/"+created.ID+"?asOf=2000-01-01T00:00:00Z", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = request(http.MethodGet, "/api/products/"+created.ID+"?asOf=yesterday", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test restoring a revision, attributed to the X-Actor header
+	t.Run("RestoreProduct", func(t *testing.T) {
+		w := request(http.MethodPost, "/api/products/"+created.ID+"/history/1/restore", map[string]string{"If-Match": `"1"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = request(http.MethodPost, "/api/products/"+created.ID+"/history/9/restore", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = request(http.MethodPost, "/api/products/"+created.ID+"/history/first/restore", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = request(http.MethodPost, "/api/products/"+created.ID+"/history/1/restore", map[string]string{"If-Match": etag(updated), "X-Actor": "bob"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+		history, _ := repo.GetProductHistory(context.Background(), created.ID)
+		require.Len(t, history, 3)
+		assert.Equal(t, models.RevisionRestored, history[2].Kind)
+		assert.Equal(t, "bob", history[2].Actor)
+		assert.Equal(t, "Original", history[2].Product.Name)
+	})
+
+	// Test that a deleted product can be brought back
+	t.Run("RestoreDeleted", func(t *testing.T) {
+		repo.DeleteProduct(ctx, created.ID, 0)
+
+		w := request(http.MethodPost, "/api/products/"+created.ID+"/history/2/restore", map[string]string{"If-Match": `"3"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = request(http.MethodPost, "/api/products/"+created.ID+"/history/2/restore", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		product, err := repo.GetProductByID(context.Background(), created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Renamed", product.Name)
+		assert.Equal(t, int64(5), product.Version)
+	})
+}
+
+func TestTrash(t *testing.T) {
+	router, repo := setupRouter()
+	created, _ := repo.CreateProduct(context.Background(), models.Product{Name: "Binned", Description: "Test Description", Price: 1.0, InventoryCount: 1})
+
+	request := func(method, url string, header map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, nil)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Test that DELETE moves the product to the trash
+	t.Run("Delete", func(t *testing.T) {
+		w := request(http.MethodDelete, "/api/products/"+created.ID, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = request(http.MethodGet, "/api/products/"+created.ID, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = request(http.MethodGet, "/api/products/trash?sort=-updatedAt", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var trash []models.Product
+		json.Unmarshal(w.Body.Bytes(), &trash)
+		require.Len(t, trash, 1)
+		assert.Equal(t, created.ID, trash[0].ID)
+		assert.NotNil(t, trash[0].DeletedAt)
+	})
+
+	// Test restoring, conditionally on the trashed version
+	t.Run("Restore", func(t *testing.T) {
+		w := request(http.MethodPost, "/api/products/"+created.ID+"/restore", map[string]string{"If-Match": `"1"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = request(http.MethodPost, "/api/products/"+created.ID+"/restore", map[string]string{"If-Match": `W/"2"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = request(http.MethodPost, "/api/products/"+created.ID+"/restore", map[string]string{"If-Match": `"2"`})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+		w = request(http.MethodPost, "/api/products/"+created.ID+"/restore", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = request(http.MethodGet, "/api/products/"+created.ID, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestAdjustInventory(t *testing.T) {
+	router, repo := setupRouter()
+	created, _ := repo.CreateProduct(context.Background(), models.Product{Name: "Stocked", Description: "Test Description", Price: 10.0, InventoryCount: 2})
+
+	adjust := func(id string, body interface{}) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, "/api/products/"+id+"/inventory/adjustments", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Test a successful adjustment
+	t.Run("Adjust", func(t *testing.T) {
+		w := adjust(created.ID, models.InventoryAdjustment{Delta: -2, Reason: "sale"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var availability models.ProductAvailability
+		json.Unmarshal(w.Body.Bytes(), &availability)
+		assert.Equal(t, 0, availability.InventoryCount)
+		assert.False(t, availability.IsAvailable)
+	})
+
+	// Test that going below zero is a conflict
+	t.Run("Insufficient", func(t *testing.T) {
+		w := adjust(created.ID, models.InventoryAdjustment{Delta: -1, Reason: "sale"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), problem.CodeInsufficientInventory)
+	})
+
+	// Test that a zero delta or unknown reason is rejected
+	t.Run("Invalid", func(t *testing.T) {
+		w := adjust(created.ID, models.InventoryAdjustment{Delta: 0, Reason: "restock"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = adjust(created.ID, models.InventoryAdjustment{Delta: 1, Reason: "found it"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test adjusting a missing product
+	t.Run("NotFound", func(t *testing.T) {
+		w := adjust("missing", models.InventoryAdjustment{Delta: 1, Reason: "restock"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestReservationHandlers(t *testing.T) {
+	router, repo := setupRouter()
+	created, _ := repo.CreateProduct(context.Background(), models.Product{Name: "Reserved", Description: "Test Description", Price: 10.0, InventoryCount: 5})
+	base := "/api/products/" + created.ID + "/reservations"
+
+	send := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	reserve := func(t *testing.T, quantity int) models.Reservation {
+		w := send(http.MethodPost, base, models.ReservationRequest{Quantity: quantity})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var reservation models.Reservation
+		json.Unmarshal(w.Body.Bytes(), &reservation)
+		assert.Equal(t, base+"/"+reservation.ID, w.Header().Get("Location"))
+		return reservation
+	}
+
+	// Test reserving and reading back a reservation
+	t.Run("Reserve", func(t *testing.T) {
+		reservation := reserve(t, 2)
+		assert.Equal(t, models.ReservationHeld, reservation.Status)
+		assert.WithinDuration(t, time.Now().Add(defaultReservationTTL), reservation.ExpiresAt, time.Minute)
+
+		w := send(http.MethodGet, base+"/"+reservation.ID, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodGet, "/api/products/"+created.ID+"/availability", nil)
+		var availability models.ProductAvailability
+		json.Unmarshal(w.Body.Bytes(), &availability)
+		assert.Equal(t, 2, availability.Reserved)
+		assert.Equal(t, 3, availability.AvailableToPromise)
+
+		send(http.MethodPost, base+"/"+reservation.ID+"/release", nil)
+	})
+
+	// Test confirming and releasing
+	t.Run("ConfirmAndRelease", func(t *testing.T) {
+		confirmed := reserve(t, 1)
+		w := send(http.MethodPost, base+"/"+confirmed.ID+"/confirm", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), models.ReservationConfirmed)
+
+		released := reserve(t, 1)
+		w = send(http.MethodPost, base+"/"+released.ID+"/release", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), models.ReservationReleased)
+
+		w = send(http.MethodPost, base+"/"+released.ID+"/confirm", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "Reservation not found")
+	})
+
+	// Test that invalid and oversized requests are rejected
+	t.Run("Invalid", func(t *testing.T) {
+		w := send(http.MethodPost, base, models.ReservationRequest{Quantity: 0})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = send(http.MethodPost, base, models.ReservationRequest{Quantity: 1, TTLSeconds: 90000})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = send(http.MethodPost, base, models.ReservationRequest{Quantity: 100})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), problem.CodeInsufficientInventory)
+
+		w = send(http.MethodPost, "/api/products/missing/reservations", models.ReservationRequest{Quantity: 1})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// failingRepository answers every call with the same error
+type failingRepository struct {
+	database.ProductRepository
+	err error
+}
+
+func (r failingRepository) GetProductByID(ctx context.Context, id string) (models.Product, error) {
+	return models.Product{}, r.err
+}
+
+func (r failingRepository) QueryProducts(ctx context.Context, query database.ProductQuery) (database.ProductPage, error) {
+	return database.ProductPage{}, r.err
+}
+
+func TestProductHandlersErrors(t *testing.T) {
+	newRouter := func(repo database.ProductRepository) *gin.Engine {
+		productHandler := NewProductHandler(repo)
+		router := gin.New()
+		router.GET("/api/products", productHandler.GetProducts)
+		router.GET("/api/products/:id", productHandler.GetProductByID)
+		router.POST("/api/products", productHandler.CreateProduct)
+		return router
+	}
+	decode := func(w *httptest.ResponseRecorder) problem.Problem {
+		var p problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &p)
+		return p
+	}
+
+	// Test that a missing product is a 404 problem
+	t.Run("NotFound", func(t *testing.T) {
+		router := newRouter(database.NewInMemoryRepository())
+		req, _ := http.NewRequest(http.MethodGet, "/api/products/missing", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+		assert.Equal(t, problem.CodeNotFound, decode(w).Code)
+		assert.Equal(t, "/api/products/missing", decode(w).Instance)
+	})
+
+	// Test that a duplicate ID is a 409 rather than a 500
+	t.Run("Conflict", func(t *testing.T) {
+		router := newRouter(database.NewInMemoryRepository())
+		product := models.Product{ID: "dup", Name: "Dup", Description: "Test Description", Price: 1.0, InventoryCount: 1}
+		for _, expected := range []int{http.StatusCreated, http.StatusConflict} {
+			jsonValue, _ := json.Marshal(product)
+			req, _ := http.NewRequest(http.MethodPost, "/api/products", bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, expected, w.Code)
+		}
+	})
+
+	// Test that validation failures are 400 problems
+	t.Run("ValidationFailed", func(t *testing.T) {
+		router := newRouter(database.NewInMemoryRepository())
+		req, _ := http.NewRequest(http.MethodPost, "/api/products", bytes.NewBufferString(`{"name":"No Price"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, problem.CodeValidationFailed, decode(w).Code)
+	})
+
+	// Test that an unavailable backend is a 503, not a 404
+	t.Run("Unavailable", func(t *testing.T) {
+		router := newRouter(failingRepository{err: fmt.Errorf("%w: connection refused", database.ErrUnavailable)})
+		for _, url := range []string{"/api/products", "/api/products/any"} {
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code, url)
+			assert.Equal(t, problem.CodeUnavailable, decode(w).Code)
+			assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		}
+	})
+
+	// Test that an expired deadline is reported as a gateway timeout
+	t.Run("Timeout", func(t *testing.T) {
+		router := newRouter(failingRepository{err: fmt.Errorf("query products: %w", context.DeadlineExceeded)})
+		req, _ := http.NewRequest(http.MethodGet, "/api/products", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Equal(t, problem.CodeTimeout, decode(w).Code)
+	})
+
+	// Test that the request context reaches the repository
+	t.Run("RequestContext", func(t *testing.T) {
+		router := newRouter(database.NewInMemoryRepository())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/products", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+
+	// Test that unclassified errors are a 500 that does not leak details
+	t.Run("Internal", func(t *testing.T) {
+		router := newRouter(failingRepository{err: errors.New("secret connection string")})
+		req, _ := http.NewRequest(http.MethodGet, "/api/products/any", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, problem.CodeInternal, decode(w).Code)
+		assert.NotContains(t, w.Body.String(), "secret")
+	})
+}
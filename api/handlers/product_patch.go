@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/patch"
+)
+
+// acceptPatch lists the patch formats PATCH understands, for the Accept-Patch header
+var acceptPatch = strings.Join([]string{patch.MergePatchType, patch.JSONPatchType}, ", ")
+
+// patchFunc applies a patch document to a JSON document
+type patchFunc func(doc, patch []byte) ([]byte, error)
+
+// patchFuncFor picks the patch format from the request's Content-Type
+func patchFuncFor(contentType string) (patchFunc, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	switch mediaType {
+	case patch.MergePatchType:
+		return patch.MergePatch, true
+	case patch.JSONPatchType:
+		return patch.JSONPatch, true
+	default:
+		return nil, false
+	}
+}
+
+// patchProduct applies body to the JSON form of product and validates the
+// result with the same rules as CreateProduct. The repository sets the ID,
+// timestamps and version afterwards, but the patch may not change the ID.
+func patchProduct(product models.Product, body []byte, apply patchFunc) (models.Product, error) {
+	doc, err := json.Marshal(product)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	patched, err := apply(doc, body)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	var result models.Product
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return models.Product{}, &validationError{fmt.Errorf("patched product is not valid: %w", err)}
+	}
+	if result.ID != product.ID {
+		return models.Product{}, &validationError{fmt.Errorf("id cannot be changed")}
+	}
+	if err := binding.Validator.ValidateStruct(&result); err != nil {
+		return models.Product{}, &validationError{err}
+	}
+
+	return result, nil
+}
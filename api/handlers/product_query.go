@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/internal/database"
+)
+
+// parseProductQuery builds a repository query from the GET /api/products query string
+func parseProductQuery(c *gin.Context) (database.ProductQuery, error) {
+	query := database.ProductQuery{Cursor: c.Query("cursor")}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > database.MaxPageSize {
+			return query, fmt.Errorf("limit must be an integer between 1 and %d", database.MaxPageSize)
+		}
+		query.Limit = n
+	}
+
+	sortFields, err := database.ParseSort(c.Query("sort"))
+	if err != nil {
+		return query, err
+	}
+	query.Sort = sortFields
+
+	if query.Filter.MinPrice, err = parseFloatParam(c, "minPrice"); err != nil {
+		return query, err
+	}
+	if query.Filter.MaxPrice, err = parseFloatParam(c, "maxPrice"); err != nil {
+		return query, err
+	}
+	if inStock := c.Query("inStock"); inStock != "" {
+		b, err := strconv.ParseBool(inStock)
+		if err != nil {
+			return query, fmt.Errorf("inStock must be true or false")
+		}
+		query.Filter.InStock = &b
+	}
+	query.Filter.NamePrefix = c.Query("namePrefix")
+
+	timeParams := map[string]**time.Time{
+		"createdAfter":  &query.Filter.CreatedAfter,
+		"createdBefore": &query.Filter.CreatedBefore,
+		"updatedAfter":  &query.Filter.UpdatedAfter,
+		"updatedBefore": &query.Filter.UpdatedBefore,
+	}
+	for name, target := range timeParams {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return query, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+		}
+		*target = &t
+	}
+
+	return query, nil
+}
+
+// parseFloatParam reads an optional numeric query parameter
+func parseFloatParam(c *gin.Context, name string) (*float64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", name)
+	}
+	return &f, nil
+}
+
+// nextPageURL returns the current request URL with the cursor replaced
+func nextPageURL(c *gin.Context, cursor string) string {
+	params := c.Request.URL.Query()
+	params.Set("cursor", cursor)
+
+	next := url.URL{Path: c.Request.URL.Path, RawQuery: params.Encode()}
+	return next.String()
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// defaultReservationTTL is how long stock is held when the request does not say
+const defaultReservationTTL = 15 * time.Minute
+
+// ReserveInventory godoc
+// @Summary Reserve product stock
+// @Description Hold a quantity of available-to-promise stock until the reservation is confirmed, released or expires (default 15 minutes)
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param reservation body models.ReservationRequest true "Quantity and optional hold time in seconds"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 201 {object} models.Reservation
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/{id}/reservations [post]
+func (h *ProductHandler) ReserveInventory(c *gin.Context) {
+	id := c.Param("id")
+
+	var request models.ReservationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	ttl := defaultReservationTTL
+	if request.TTLSeconds > 0 {
+		ttl = time.Duration(request.TTLSeconds) * time.Second
+	}
+
+	reservation, err := h.repo.ReserveInventory(c.Request.Context(), id, request.Quantity, ttl)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Header("Location", c.Request.URL.Path+"/"+reservation.ID)
+	c.JSON(http.StatusCreated, reservation)
+}
+
+// GetReservation godoc
+// @Summary Get a reservation
+// @Description Get a held reservation. Confirmed and released reservations are no longer found.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param reservationId path string true "Reservation ID"
+// @Success 200 {object} models.Reservation
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/{id}/reservations/{reservationId} [get]
+func (h *ProductHandler) GetReservation(c *gin.Context) {
+	reservation, err := h.repo.GetReservation(c.Request.Context(), c.Param("id"), c.Param("reservationId"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
+
+// ConfirmReservation godoc
+// @Summary Confirm a reservation
+// @Description Take the reserved quantity off the product's inventory and close the reservation
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param reservationId path string true "Reservation ID"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} models.Reservation
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/{id}/reservations/{reservationId}/confirm [post]
+func (h *ProductHandler) ConfirmReservation(c *gin.Context) {
+	reservation, err := h.repo.ConfirmReservation(c.Request.Context(), c.Param("id"), c.Param("reservationId"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
+
+// ReleaseReservation godoc
+// @Summary Release a reservation
+// @Description Return the reserved quantity to available-to-promise and close the reservation
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param reservationId path string true "Reservation ID"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} models.Reservation
+// @Failure 404 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/{id}/reservations/{reservationId}/release [post]
+func (h *ProductHandler) ReleaseReservation(c *gin.Context) {
+	reservation, err := h.repo.ReleaseReservation(c.Request.Context(), c.Param("id"), c.Param("reservationId"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
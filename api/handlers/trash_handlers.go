@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/api/problem"
+)
+
+// GetTrash godoc
+// @Summary List deleted products
+// @Description Get a page of products in the trash. Takes the same paging, sorting and filter parameters as
+// @Description GET /api/products; deleting a product sets its updatedAt, so sort=-updatedAt lists the most recently deleted first.
+// @Tags trash
+// @Accept json
+// @Produce json
+// @Param limit query int false "Page size (1-500, default 50)"
+// @Param cursor query string false "Opaque cursor from a previous page"
+// @Param sort query string false "Comma-separated sort fields, '-' prefix for descending (e.g. -updatedAt)"
+// @Success 200 {array} models.Product
+// @Header 200 {string} Link "Next page link"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page"
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/trash [get]
+func (h *ProductHandler) GetTrash(c *gin.Context) {
+	h.listProducts(c, true)
+}
+
+// UndeleteProduct godoc
+// @Summary Restore a deleted product
+// @Description Take a product back out of the trash. Send If-Match with the ETag of the trashed
+// @Description product (its version as listed in the trash) to make the restore conditional.
+// @Tags trash
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param If-Match header string false "Only restore if the trashed product still has this ETag"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} models.Product
+// @Header 200 {string} ETag "Product version tag"
+// @Failure 404 {object} problem.Problem
+// @Failure 412 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Failure 504 {object} problem.Problem
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/products/{id}/restore [post]
+func (h *ProductHandler) UndeleteProduct(c *gin.Context) {
+	version, ok := ifMatchVersion(c.GetHeader("If-Match"))
+	if !ok {
+		problem.Write(c, http.StatusPreconditionFailed, problem.CodePreconditionFailed, "Product does not match the request preconditions")
+		return
+	}
+
+	product, err := h.repo.UndeleteProduct(c.Request.Context(), c.Param("id"), version)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	setETag(c, product)
+	c.JSON(http.StatusOK, product)
+}
+
+// ifMatchVersion extracts the version named by an If-Match header for a
+// product that cannot be read first, such as one in the trash. An absent
+// header or "*" gives 0 (unconditional); anything but a single strong tag
+// cannot match and gives false.
+func ifMatchVersion(header string) (int64, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, false
+	}
+
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/webhooks"
+)
+
+// WebhookHandler handles webhook subscription requests
+type WebhookHandler struct {
+	service *webhooks.Service
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(service *webhooks.Service) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+// GetWebhooks godoc
+// @Summary List webhooks
+// @Description Get every webhook subscription, oldest first. Secrets are not returned.
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} models.Webhook
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/webhooks [get]
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	list, err := h.service.List(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	for i := range list {
+		list[i].Secret = ""
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetWebhook godoc
+// @Summary Get a webhook
+// @Description Get a webhook subscription by ID. The secret is not returned.
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} models.Webhook
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	webhook, err := h.service.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+// CreateWebhook godoc
+// @Summary Create a webhook
+// @Description Subscribe an http(s) URL to product events, optionally limited to some event types.
+// @Description Deliveries carry an X-Webhook-Signature header "t=<unix time>,v1=<hex HMAC-SHA256 of '<t>.<body>'>"
+// @Description keyed with the secret. A secret is generated when none is given; it is only returned here.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body models.Webhook true "Webhook subscription"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 201 {object} models.Webhook
+// @Failure 400 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var webhook models.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	created, err := h.service.Create(c.Request.Context(), webhook)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Header("Location", c.Request.URL.Path+"/"+created.ID)
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook
+// @Description Replace a webhook's URL and event types. The secret is rotated when a new one is given
+// @Description and kept otherwise; it is not returned.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param webhook body models.Webhook true "Webhook subscription"
+// @Success 200 {object} models.Webhook
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	var webhook models.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		badRequest(c, err)
+		return
+	}
+	webhook.ID = c.Param("id")
+
+	updated, err := h.service.Update(c.Request.Context(), webhook)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	updated.Secret = ""
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook
+// @Description Remove a webhook subscription together with its pending deliveries and delivery log
+// @Tags webhooks
+// @Param id path string true "Webhook ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	if err := h.service.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries godoc
+// @Summary List a webhook's deliveries
+// @Description Get the delivery log of a webhook, newest first: pending and dead-lettered deliveries
+// @Description and the most recent successful ones, with the outcome of their latest attempt.
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	deliveries, err := h.service.Deliveries(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetDeadLetters godoc
+// @Summary List dead-lettered deliveries
+// @Description Get every delivery, across webhooks, that failed all of its attempts, newest first
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/webhooks/dead-letters [get]
+func (h *WebhookHandler) GetDeadLetters(c *gin.Context) {
+	deliveries, err := h.service.DeadLetters(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RetryDeadLetter godoc
+// @Summary Retry a dead-lettered delivery
+// @Description Move a dead-lettered delivery back to pending with a fresh set of attempts
+// @Tags webhooks
+// @Produce json
+// @Param deliveryId path string true "Delivery ID"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/webhooks/dead-letters/{deliveryId}/retry [post]
+func (h *WebhookHandler) RetryDeadLetter(c *gin.Context) {
+	delivery, err := h.service.Redeliver(c.Request.Context(), c.Param("deliveryId"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/events"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/webhooks"
+)
+
+func setupWebhookRouter(service *webhooks.Service) *gin.Engine {
+	webhookHandler := NewWebhookHandler(service)
+
+	router := gin.Default()
+	routes := router.Group("/api/webhooks")
+	{
+		routes.GET("", webhookHandler.GetWebhooks)
+		routes.POST("", webhookHandler.CreateWebhook)
+		routes.GET("/dead-letters", webhookHandler.GetDeadLetters)
+		routes.POST("/dead-letters/:deliveryId/retry", webhookHandler.RetryDeadLetter)
+		routes.GET("/:id", webhookHandler.GetWebhook)
+		routes.PUT("/:id", webhookHandler.UpdateWebhook)
+		routes.DELETE("/:id", webhookHandler.DeleteWebhook)
+		routes.GET("/:id/deliveries", webhookHandler.GetWebhookDeliveries)
+	}
+	return router
+}
+
+func TestWebhooks(t *testing.T) {
+	ctx := context.Background()
+	secret := "partner-secret-0123456789"
+
+	// The receiver verifies signatures and fails while failing is set
+	var failing atomic.Bool
+	var received atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := webhooks.Verify(secret, r.Header.Get(webhooks.SignatureHeader), body, time.Now(), time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		received.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	repo := database.NewInMemoryRepository()
+	service := webhooks.NewService(receiver.Client(), webhooks.Options{MaxAttempts: 2, Backoff: time.Millisecond, Timeout: time.Second, LogSize: 10})
+	dispatcher := events.NewDispatcher(repo, service, 10, time.Second)
+	router := setupWebhookRouter(service)
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != nil {
+			data, _ := json.Marshal(body)
+			reader = bytes.NewReader(data)
+		}
+		req, _ := http.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var webhook models.Webhook
+
+	// Test creating a subscription; the secret is only returned here
+	t.Run("CreateWebhook", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/webhooks", models.Webhook{URL: receiver.URL, EventTypes: []string{models.EventPriceChanged}, Secret: secret})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &webhook))
+		assert.Equal(t, secret, webhook.Secret)
+		assert.Equal(t, "/api/webhooks/"+webhook.ID, w.Header().Get("Location"))
+
+		w = do(http.MethodGet, "/api/webhooks/"+webhook.ID, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), secret)
+		w = do(http.MethodGet, "/api/webhooks", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), secret)
+	})
+
+	// Test that invalid subscriptions are rejected
+	t.Run("Validation", func(t *testing.T) {
+		for _, invalid := range []models.Webhook{
+			{URL: "ftp://example.com/hook"},
+			{URL: "not a url"},
+			{URL: receiver.URL, EventTypes: []string{"SomethingHappened"}},
+			{URL: receiver.URL, Secret: "short"},
+		} {
+			w := do(http.MethodPost, "/api/webhooks", invalid)
+			assert.Equal(t, http.StatusBadRequest, w.Code, invalid)
+		}
+	})
+
+	// Test that a price change reaches the receiver end to end and is logged
+	t.Run("Delivery", func(t *testing.T) {
+		product, err := repo.CreateProduct(ctx, models.Product{Name: "Hooked", Description: "Test Description", Price: 5.0, InventoryCount: 1})
+		require.NoError(t, err)
+		product.Price = 6.0
+		_, err = repo.UpdateProduct(ctx, product)
+		require.NoError(t, err)
+
+		_, err = dispatcher.DispatchOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, service.DeliverDue(ctx, time.Now()))
+		assert.Equal(t, int32(1), received.Load())
+
+		w := do(http.MethodGet, "/api/webhooks/"+webhook.ID+"/deliveries", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var deliveries []models.WebhookDelivery
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deliveries))
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, models.EventPriceChanged, deliveries[0].Event.Type)
+		assert.Equal(t, models.DeliverySucceeded, deliveries[0].Status)
+		assert.Equal(t, http.StatusNoContent, deliveries[0].ResponseStatus)
+	})
+
+	// Test dead-lettering and retrying through the API
+	t.Run("DeadLetters", func(t *testing.T) {
+		failing.Store(true)
+		product, err := repo.CreateProduct(ctx, models.Product{Name: "Unlucky", Description: "Test Description", Price: 5.0, InventoryCount: 1})
+		require.NoError(t, err)
+		product.Price = 4.0
+		_, err = repo.UpdateProduct(ctx, product)
+		require.NoError(t, err)
+		_, err = dispatcher.DispatchOnce(ctx)
+		require.NoError(t, err)
+
+		service.DeliverDue(ctx, time.Now())
+		service.DeliverDue(ctx, time.Now().Add(time.Second))
+
+		w := do(http.MethodGet, "/api/webhooks/dead-letters", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var dead []models.WebhookDelivery
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &dead))
+		require.Len(t, dead, 1)
+		assert.Equal(t, product.ID, dead[0].Event.ProductID)
+		assert.Equal(t, http.StatusBadGateway, dead[0].ResponseStatus)
+
+		failing.Store(false)
+		w = do(http.MethodPost, "/api/webhooks/dead-letters/"+dead[0].ID+"/retry", nil)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		w = do(http.MethodPost, "/api/webhooks/dead-letters/"+dead[0].ID+"/retry", nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = do(http.MethodPost, "/api/webhooks/dead-letters/missing/retry", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		assert.Equal(t, 1, service.DeliverDue(ctx, time.Now()))
+		assert.Equal(t, int32(2), received.Load())
+	})
+
+	// Test updating and deleting a subscription
+	t.Run("UpdateAndDelete", func(t *testing.T) {
+		w := do(http.MethodPut, "/api/webhooks/"+webhook.ID, models.Webhook{URL: receiver.URL + "/v2"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var updated models.Webhook
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		assert.Equal(t, receiver.URL+"/v2", updated.URL)
+		assert.Empty(t, updated.EventTypes)
+		assert.Empty(t, updated.Secret)
+
+		w = do(http.MethodDelete, "/api/webhooks/"+webhook.ID, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = do(http.MethodGet, "/api/webhooks/"+webhook.ID, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = do(http.MethodGet, "/api/webhooks/"+webhook.ID+"/deliveries", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = do(http.MethodPut, "/api/webhooks/"+webhook.ID, models.Webhook{URL: receiver.URL})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
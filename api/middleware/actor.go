@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/internal/database"
+)
+
+// ActorHeader names who is making a request. It is taken on trust and only
+// used to attribute entries in a product's change history.
+const ActorHeader = "X-Actor"
+
+// maxActorLength keeps an oversized header out of the stored history
+const maxActorLength = 128
+
+// Actor records the request's ActorHeader on its context so the repository
+// can attribute the revisions the request produces
+func Actor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := strings.TrimSpace(c.GetHeader(ActorHeader))
+		if len(actor) > maxActorLength {
+			actor = actor[:maxActorLength]
+		}
+		if actor != "" {
+			c.Request = c.Request.WithContext(database.WithActor(c.Request.Context(), actor))
+		}
+		
+		c.Next()
+	}
+}
@@ -0,0 +1,261 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/apikeys"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// Roles a token can grant. Admin grants every other role.
+const (
+	RoleCatalogRead    = "catalog:read"
+	RoleCatalogWrite   = "catalog:write"
+	RoleInventoryWrite = "inventory:write"
+	RoleAdmin          = "admin"
+)
+
+// knownRoles are the roles taken from a token's claims; anything else is ignored
+var knownRoles = map[string]bool{
+	RoleCatalogRead:    true,
+	RoleCatalogWrite:   true,
+	RoleInventoryWrite: true,
+	RoleAdmin:          true,
+}
+
+// minSecretLength is the shortest HS256 secret accepted, matching the hash size
+const minSecretLength = 32
+
+// principalKey is the gin context key holding the authenticated Principal
+const principalKey = "auth.principal"
+
+// Principal is the caller identified by a verified token or API key. Tenant
+// is the tenant the caller is bound to, if any.
+type Principal struct {
+	Subject string
+	Tenant  string
+	Roles   map[string]bool
+}
+
+// HasRole reports whether the principal was granted role, directly or as admin
+func (p Principal) HasRole(role string) bool {
+	return p.Roles[role] || p.Roles[RoleAdmin]
+}
+
+// Claims are the token claims the service reads. Roles come from the "roles"
+// array and from the space-separated OAuth 2.0 "scope"; "tenant" binds the
+// token to one tenant.
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles  []string `json:"roles,omitempty"`
+	Scope  string   `json:"scope,omitempty"`
+	Tenant string   `json:"tenant,omitempty"`
+}
+
+// AuthOptions configures token verification. At least one of HMACSecret and
+// JWKSFile must be set; Issuer and Audience are only checked when set.
+type AuthOptions struct {
+	HMACSecret string
+	JWKSFile   string
+	Issuer     string
+	Audience   string
+}
+
+// Authenticator verifies bearer tokens signed with HS256 or RS256
+type Authenticator struct {
+	secret []byte
+	keys   map[string]interface{} // RS256 public keys by key ID
+	parser *jwt.Parser
+}
+
+// NewAuthenticator creates an Authenticator, reading the JWKS file if one is set
+func NewAuthenticator(options AuthOptions) (*Authenticator, error) {
+	a := &Authenticator{}
+	var methods []string
+	
+	if options.HMACSecret != "" {
+		if len(options.HMACSecret) < minSecretLength {
+			return nil, fmt.Errorf("HS256 secret must be at least %d bytes", minSecretLength)
+		}
+		a.secret = []byte(options.HMACSecret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if options.JWKSFile != "" {
+		keys, err := LoadJWKS(options.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = keys
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("an HS256 secret or a JWKS file is required")
+	}
+	
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if options.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(options.Issuer))
+	}
+	if options.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(options.Audience))
+	}
+	a.parser = jwt.NewParser(parserOptions...)
+	
+	return a, nil
+}
+
+// Verify checks a token's signature and claims and returns its principal
+func (a *Authenticator) Verify(token string) (Principal, error) {
+	var claims Claims
+	if _, err := a.parser.ParseWithClaims(token, &claims, a.key); err != nil {
+		return Principal{}, err
+	}
+	if claims.Subject == "" {
+		return Principal{}, errors.New("token has no subject")
+	}
+	
+	principal := Principal{Subject: claims.Subject, Tenant: claims.Tenant, Roles: make(map[string]bool)}
+	for _, role := range append(claims.Roles, strings.Fields(claims.Scope)...) {
+		if knownRoles[role] {
+			principal.Roles[role] = true
+		}
+	}
+	return principal, nil
+}
+
+// key picks the verification key for a token's algorithm and key ID
+func (a *Authenticator) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return a.secret, nil
+	case *jwt.SigningMethodRSA:
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := a.keys[kid]; ok {
+			return key, nil
+		}
+		// A token without a key ID is accepted when there is only one key
+		if kid == "" && len(a.keys) == 1 {
+			for _, key := range a.keys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
+
+// APIKeyHeader carries an API key, as an alternative to a bearer token
+const APIKeyHeader = "X-API-Key"
+
+// scopeRoles are the roles each API key scope grants. Keys are never admin.
+var scopeRoles = map[string][]string{
+	models.APIKeyScopeReadOnly:  {RoleCatalogRead},
+	models.APIKeyScopeInventory: {RoleCatalogRead, RoleInventoryWrite},
+	models.APIKeyScopeFull:      {RoleCatalogRead, RoleCatalogWrite, RoleInventoryWrite},
+}
+
+// ErrNoCredentials is returned by Identify when a call carries neither an
+// API key nor a bearer token
+var ErrNoCredentials = errors.New("Bearer token or API key required")
+
+// Identify returns the principal of apiKey, when one is given, or else of the
+// bearer token in authorization, an Authorization header value. It is shared
+// by every API so that all of them accept the same credentials.
+func Identify(ctx context.Context, tokens *Authenticator, keys *apikeys.Service, apiKey, authorization string) (Principal, error) {
+	if apiKey != "" && keys != nil {
+		key, err := keys.Verify(ctx, apiKey)
+		if err != nil {
+			return Principal{}, fmt.Errorf("Invalid API key: %w", err)
+		}
+		return keyPrincipal(key), nil
+	}
+	
+	scheme, token, _ := strings.Cut(authorization, " ")
+	if tokens == nil || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	principal, err := tokens.Verify(strings.TrimSpace(token))
+	if err != nil {
+		return Principal{}, fmt.Errorf("Invalid bearer token: %w", err)
+	}
+	return principal, nil
+}
+
+// Authenticate requires a valid API key or bearer token on every request and
+// records its principal. The principal's subject replaces any X-Actor header
+// in the history. Either tokens or keys may be nil to turn that method off.
+func Authenticate(tokens *Authenticator, keys *apikeys.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := Identify(c.Request.Context(), tokens, keys, c.GetHeader(APIKeyHeader), c.GetHeader("Authorization"))
+		if errors.Is(err, ErrNoCredentials) {
+			unauthorized(c, "", err.Error())
+			return
+		}
+		if err != nil {
+			unauthorized(c, "invalid_token", err.Error())
+			return
+		}
+		
+		c.Set(principalKey, principal)
+		c.Request = c.Request.WithContext(database.WithActor(c.Request.Context(), principal.Subject))
+		
+		c.Next()
+	}
+}
+
+// keyPrincipal is the principal of an API key, granted its scope's roles and
+// bound to the tenant it was minted in
+func keyPrincipal(key models.APIKey) Principal {
+	principal := Principal{Subject: "apikey:" + key.ID, Tenant: key.Tenant, Roles: make(map[string]bool)}
+	for _, role := range scopeRoles[key.Scope] {
+		principal.Roles[role] = true
+	}
+	return principal
+}
+
+// RequireRole answers 403 unless the caller was granted role. Requests are
+// let through when authentication is not enabled.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Authorized(c, role) {
+			problem.Write(c, http.StatusForbidden, problem.CodeForbidden, fmt.Sprintf("The %s role is required", role))
+			return
+		}
+		
+		c.Next()
+	}
+}
+
+// Authorized reports whether the caller may act with role, for handlers that
+// decide per request. It is true when authentication is not enabled.
+func Authorized(c *gin.Context, role string) bool {
+	value, authenticated := c.Get(principalKey)
+	if !authenticated {
+		return true
+	}
+	return value.(Principal).HasRole(role)
+}
+
+// unauthorized answers 401 with a Bearer challenge as RFC 6750 describes
+func unauthorized(c *gin.Context, code, detail string) {
+	challenge := `Bearer realm="product-service"`
+	if code != "" {
+		challenge += fmt.Sprintf(`, error=%q`, code)
+	}
+	c.Header("WWW-Authenticate", challenge)
+	problem.Write(c, http.StatusUnauthorized, problem.CodeUnauthorized, detail)
+}
@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/apikeys"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// writeJWKS writes the public half of key to a JWKS file under kid
+func writeJWKS(t *testing.T, kid string, key *rsa.PrivateKey) string {
+	set := map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// sign issues a token for subject with claims valid for an hour
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims Claims) string {
+	if claims.Subject == "" {
+		claims.Subject = "alice"
+	}
+	if claims.ExpiresAt == nil {
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+	}
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestAuth(t *testing.T) {
+	// Set Gin to test mode
+	gin.SetMode(gin.TestMode)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	authenticator, err := NewAuthenticator(AuthOptions{
+		HMACSecret: testSecret,
+		JWKSFile:   writeJWKS(t, "key-1", rsaKey),
+		Issuer:     "https://issuer.example.com",
+	})
+	require.NoError(t, err)
+
+	keys := apikeys.NewService(apikeys.NewMemoryStore())
+	router := gin.New()
+	router.Use(Actor(), Authenticate(authenticator, keys))
+	router.DELETE("/products/:id", RequireRole(RoleCatalogWrite), func(c *gin.Context) {
+		repo := database.NewInMemoryRepository()
+		product, _ := repo.CreateProduct(c.Request.Context(), models.Product{Name: "Attributed", Description: "Test Description", Price: 1.0})
+		history, _ := repo.GetProductHistory(c.Request.Context(), product.ID)
+		c.String(http.StatusOK, history[0].Actor)
+	})
+
+	do := func(token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodDelete, "/products/1", nil)
+		req.Header.Set(ActorHeader, "mallory")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	issued := func(roles ...string) Claims {
+		return Claims{RegisteredClaims: jwt.RegisteredClaims{Issuer: "https://issuer.example.com"}, Roles: roles}
+	}
+	decode := func(w *httptest.ResponseRecorder) problem.Problem {
+		var p problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &p)
+		return p
+	}
+
+	// Test that a request without a token gets a 401 challenge
+	t.Run("MissingToken", func(t *testing.T) {
+		w := do("")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="product-service"`, w.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, problem.CodeUnauthorized, decode(w).Code)
+	})
+
+	// Test HS256 and RS256 tokens, attributed to their subject rather than X-Actor
+	t.Run("Valid", func(t *testing.T) {
+		w := do(sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", issued(RoleCatalogWrite)))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "alice", w.Body.String())
+
+		w = do(sign(t, jwt.SigningMethodRS256, rsaKey, "key-1", issued(RoleCatalogWrite)))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Roles can also come from the OAuth scope, and admin grants every role
+		claims := issued()
+		claims.Scope = "openid catalog:write"
+		assert.Equal(t, http.StatusOK, do(sign(t, jwt.SigningMethodRS256, rsaKey, "key-1", claims)).Code)
+		assert.Equal(t, http.StatusOK, do(sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", issued(RoleAdmin))).Code)
+	})
+
+	// Test that a valid token without the route's role gets a 403
+	t.Run("Forbidden", func(t *testing.T) {
+		w := do(sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", issued(RoleCatalogRead, "catalog:delete")))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, problem.CodeForbidden, decode(w).Code)
+	})
+
+	// Test tokens that must be rejected
+	t.Run("Invalid", func(t *testing.T) {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		expired := issued(RoleCatalogWrite)
+		expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+		wrongIssuer := issued(RoleCatalogWrite)
+		wrongIssuer.Issuer = "https://evil.example.com"
+		noExpiry := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice", "iss": "https://issuer.example.com", "roles": []string{RoleCatalogWrite}})
+		unexpiring, err := noExpiry.SignedString([]byte(testSecret))
+		require.NoError(t, err)
+
+		tokens := map[string]string{
+			"Expired":     sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", expired),
+			"WrongIssuer": sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", wrongIssuer),
+			"WrongSecret": sign(t, jwt.SigningMethodHS256, []byte(strings.Repeat("x", 32)), "", issued(RoleCatalogWrite)),
+			"UnknownKey":  sign(t, jwt.SigningMethodRS256, otherKey, "key-2", issued(RoleCatalogWrite)),
+			"WrongRSAKey": sign(t, jwt.SigningMethodRS256, otherKey, "key-1", issued(RoleCatalogWrite)),
+			"HS384":       sign(t, jwt.SigningMethodHS384, []byte(testSecret), "", issued(RoleCatalogWrite)),
+			"NoExpiry":    unexpiring,
+			"Malformed":   "not-a-token",
+		}
+		for name, token := range tokens {
+			w := do(token)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+			assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`, name)
+		}
+	})
+
+	// Test API keys, attributed to the key and limited to their scope's roles
+	t.Run("APIKey", func(t *testing.T) {
+		withKey := func(key string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(http.MethodDelete, "/products/1", nil)
+			req.Header.Set(APIKeyHeader, key)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+		ctx := context.Background()
+		full, err := keys.Mint(ctx, models.APIKeyRequest{Name: "orders", Scope: models.APIKeyScopeFull})
+		require.NoError(t, err)
+		readOnly, err := keys.Mint(ctx, models.APIKeyRequest{Name: "reports", Scope: models.APIKeyScopeReadOnly})
+		require.NoError(t, err)
+
+		w := withKey(full.Key)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "apikey:"+full.ID, w.Body.String())
+		assert.Equal(t, http.StatusForbidden, withKey(readOnly.Key).Code)
+
+		w = withKey("pk_unknown")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+
+		// Keys never grant admin
+		assert.False(t, keyPrincipal(full).HasRole(RoleAdmin))
+	})
+
+	// Test that role checks pass when authentication is not enabled
+	t.Run("Disabled", func(t *testing.T) {
+		anonymous := gin.New()
+		anonymous.DELETE("/products/:id", RequireRole(RoleAdmin), func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+		req, _ := http.NewRequest(http.MethodDelete, "/products/1", nil)
+		w := httptest.NewRecorder()
+		anonymous.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	// Test configuration errors
+	t.Run("Options", func(t *testing.T) {
+		_, err := NewAuthenticator(AuthOptions{})
+		assert.Error(t, err)
+		_, err = NewAuthenticator(AuthOptions{HMACSecret: "short"})
+		assert.Error(t, err)
+		_, err = NewAuthenticator(AuthOptions{JWKSFile: filepath.Join(t.TempDir(), "missing.json")})
+		assert.Error(t, err)
+	})
+}
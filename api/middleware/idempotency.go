@@ -0,0 +1,284 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/requestid"
+	"github.com/yourusername/product-service/internal/tenancy"
+)
+
+const (
+	// IdempotencyKeyHeader names the client's key for a retryable POST
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader marks a response replayed from the store
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength bounds the keys clients may choose
+	maxIdempotencyKeyLength = 255
+
+	// maxIdempotentMemory bounds the request bodies buffered in memory to
+	// fingerprint; larger ones, such as catalogue imports, go to a temporary file
+	maxIdempotentMemory = 8 << 20
+)
+
+// StoredResponse is a response kept for replay
+type StoredResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// IdempotencyRecord is what a store holds for a key. Response is nil while
+// the first request with the key is still being handled.
+type IdempotencyRecord struct {
+	Fingerprint string
+	Response    *StoredResponse
+}
+
+// IdempotencyStore keeps the requests made with idempotency keys and their
+// responses. MemoryIdempotencyStore serves one replica; a store shared
+// between replicas lets a retry land on any of them.
+type IdempotencyStore interface {
+	// Reserve claims key for a request with fingerprint until ttl passes.
+	// If the key is taken it returns the existing record and false.
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration, now time.Time) (IdempotencyRecord, bool, error)
+
+	// Save records the response to the request that reserved key
+	Save(ctx context.Context, key string, response StoredResponse) error
+
+	// Release frees a reserved key whose request should be retried afresh
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyOptions configures Idempotency with how long keys are kept
+type IdempotencyOptions struct {
+	TTL   time.Duration
+	Store IdempotencyStore
+}
+
+// Idempotency makes POSTs carrying IdempotencyKeyHeader safe to retry. The
+// first request with a key runs and its response is kept for the TTL;
+// retries with the same key and body get that response again, marked with
+// IdempotentReplayedHeader. Reusing a key with a different request gets 422,
+// and retrying while the first request is still running gets 409. Keys are
+// scoped to the caller and tenant. Responses with a 5xx status are not kept,
+// so those requests can be retried.
+func Idempotency(options IdempotencyOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			problem.Write(c, http.StatusBadRequest, problem.CodeValidationFailed, fmt.Sprintf("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+			return
+		}
+		
+		sum := fingerprintHash(c.Request)
+		body, err := bufferBody(c.Request.Body, sum)
+		if err != nil {
+			problem.Write(c, http.StatusBadRequest, problem.CodeValidationFailed, "Failed to read request body")
+			return
+		}
+		defer body.Close()
+		c.Request.Body = body
+		
+		ctx := c.Request.Context()
+		scoped := tenancy.FromContext(ctx) + "|" + client(c) + "|" + key
+		fingerprint := hex.EncodeToString(sum.Sum(nil))
+		record, reserved, err := options.Store.Reserve(ctx, scoped, fingerprint, options.TTL, time.Now())
+		if err != nil {
+			requestid.Printf(ctx, "Idempotency store unavailable, handling request without it: %v", err)
+			c.Next()
+			return
+		}
+		if !reserved {
+			switch {
+			case record.Fingerprint != fingerprint:
+				problem.Write(c, http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused, IdempotencyKeyHeader+" was already used for a different request")
+			case record.Response == nil:
+				problem.Write(c, http.StatusConflict, problem.CodeConflict, "A request with this "+IdempotencyKeyHeader+" is still in progress")
+			default:
+				replay(c, *record.Response)
+			}
+			return
+		}
+		
+		before := c.Writer.Header().Clone()
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer func() {
+			c.Writer = writer.ResponseWriter
+			
+			// A detached context, as the request's may be done by now
+			storeCtx := context.Background()
+			if !writer.Written() || writer.Status() >= http.StatusInternalServerError {
+				if err := options.Store.Release(storeCtx, scoped); err != nil {
+					requestid.Printf(c.Request.Context(), "Failed to release idempotency key: %v", err)
+				}
+				return
+			}
+			
+			header := make(http.Header)
+			for name, values := range writer.Header() {
+				if _, existed := before[name]; !existed {
+					header[name] = values
+				}
+			}
+			response := StoredResponse{Status: writer.Status(), Header: header, Body: writer.body.Bytes()}
+			if err := options.Store.Save(storeCtx, scoped, response); err != nil {
+				requestid.Printf(c.Request.Context(), "Failed to save idempotent response: %v", err)
+			}
+		}()
+		
+		c.Next()
+	}
+}
+
+// fingerprintHash starts the hash identifying a request by its method,
+// target and body; the body is added as it is buffered
+func fingerprintHash(req *http.Request) hash.Hash {
+	sum := sha256.New()
+	sum.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	return sum
+}
+
+// bufferBody reads body through sum and returns a copy of it to hand on to
+// the handler. Bodies over maxIdempotentMemory are spilled to a temporary
+// file, removed when the copy is closed, so large imports are not refused.
+func bufferBody(body io.Reader, sum hash.Hash) (io.ReadCloser, error) {
+	body = io.TeeReader(body, sum)
+	var head bytes.Buffer
+	if _, err := io.Copy(&head, io.LimitReader(body, maxIdempotentMemory+1)); err != nil {
+		return nil, err
+	}
+	if head.Len() <= maxIdempotentMemory {
+		return io.NopCloser(bytes.NewReader(head.Bytes())), nil
+	}
+	
+	file, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return nil, err
+	}
+	spilled := &spilledBody{File: file}
+	if _, err := io.Copy(file, io.MultiReader(&head, body)); err != nil {
+		spilled.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		spilled.Close()
+		return nil, err
+	}
+	return spilled, nil
+}
+
+// spilledBody is a request body kept in a temporary file
+type spilledBody struct {
+	*os.File
+}
+
+// Close closes and removes the file
+func (b *spilledBody) Close() error {
+	b.File.Close()
+	return os.Remove(b.File.Name())
+}
+
+// replay sends a stored response again
+func replay(c *gin.Context, response StoredResponse) {
+	for name, values := range response.Header {
+		c.Writer.Header()[name] = values
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Status(response.Status)
+	c.Writer.Write(response.Body)
+	c.Abort()
+}
+
+// recordingWriter keeps a copy of the body written through it
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore holding records in memory
+type MemoryIdempotencyStore struct {
+	mutex     sync.Mutex
+	records   map[string]*idempotencyEntry
+	lastSweep time.Time
+}
+
+// idempotencyEntry is a record with the time it is forgotten
+type idempotencyEntry struct {
+	record  IdempotencyRecord
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]*idempotencyEntry)}
+}
+
+// Reserve implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Reserve(_ context.Context, key, fingerprint string, ttl time.Duration, now time.Time) (IdempotencyRecord, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, entry := range s.records {
+			if !now.Before(entry.expires) {
+				delete(s.records, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	
+	if entry, exists := s.records[key]; exists && now.Before(entry.expires) {
+		return entry.record, false, nil
+	}
+	s.records[key] = &idempotencyEntry{record: IdempotencyRecord{Fingerprint: fingerprint}, expires: now.Add(ttl)}
+	return IdempotencyRecord{Fingerprint: fingerprint}, true, nil
+}
+
+// Save implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Save(_ context.Context, key string, response StoredResponse) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if entry, exists := s.records[key]; exists {
+		entry.record.Response = &response
+	}
+	return nil
+}
+
+// Release implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	delete(s.records, key)
+	return nil
+}
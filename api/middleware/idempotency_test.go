@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/yourusername/product-service/internal/tenancy"
+)
+
+func TestIdempotency(t *testing.T) {
+	// Set Gin to test mode
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if tenant := c.GetHeader(TenantHeader); tenant != "" {
+			c.Request = c.Request.WithContext(tenancy.WithTenant(c.Request.Context(), tenant))
+		}
+	})
+	router.Use(Idempotency(IdempotencyOptions{TTL: time.Hour, Store: NewMemoryIdempotencyStore()}))
+	router.POST("/products", func(c *gin.Context) {
+		calls++
+		c.Header("Location", "/products/"+strconv.Itoa(calls))
+		c.JSON(http.StatusCreated, gin.H{"id": calls})
+	})
+	router.POST("/failing", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "unavailable"})
+	})
+	router.POST("/slow", func(c *gin.Context) {
+		calls++
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/slow", strings.NewReader("{}"))
+		req.Header.Set(IdempotencyKeyHeader, c.GetHeader(IdempotencyKeyHeader))
+		router.ServeHTTP(w, req)
+		c.String(http.StatusOK, strconv.Itoa(w.Code))
+	})
+	router.POST("/import", func(c *gin.Context) {
+		calls++
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, strconv.Itoa(len(body)))
+	})
+	do := func(path, key, tenant, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		if tenant != "" {
+			req.Header.Set(TenantHeader, tenant)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Test that a retry replays the first response without running again
+	t.Run("Replay", func(t *testing.T) {
+		calls = 0
+		first := do("/products", "create-1", "", `{"name":"Gadget"}`)
+		assert.Equal(t, http.StatusCreated, first.Code)
+		assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+
+		retry := do("/products", "create-1", "", `{"name":"Gadget"}`)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, "/products/1", retry.Header().Get("Location"))
+		assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, 1, calls)
+
+		// Without a key every request runs
+		do("/products", "", "", `{"name":"Gadget"}`)
+		do("/products", "", "", `{"name":"Gadget"}`)
+		assert.Equal(t, 3, calls)
+	})
+
+	// Test that a key cannot be reused for a different request
+	t.Run("Reuse", func(t *testing.T) {
+		calls = 0
+		do("/products", "create-2", "", `{"name":"Gadget"}`)
+		w := do("/products", "create-2", "", `{"name":"Widget"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "idempotency_key_reused")
+		assert.Equal(t, http.StatusUnprocessableEntity, do("/failing", "create-2", "", `{"name":"Gadget"}`).Code)
+		assert.Equal(t, 1, calls)
+
+		assert.Equal(t, http.StatusBadRequest, do("/products", strings.Repeat("k", 256), "", "{}").Code)
+	})
+
+	// Test that keys are scoped to the tenant
+	t.Run("Tenants", func(t *testing.T) {
+		calls = 0
+		do("/products", "create-3", "brand-a", `{"name":"Gadget"}`)
+		w := do("/products", "create-3", "brand-b", `{"name":"Gadget"}`)
+		assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, 2, calls)
+	})
+
+	// Test that failed requests are not kept and may be retried
+	t.Run("ServerError", func(t *testing.T) {
+		calls = 0
+		do("/failing", "fail-1", "", "{}")
+		w := do("/failing", "fail-1", "", "{}")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, 2, calls)
+	})
+
+	// Test that a retry during the first request gets 409
+	t.Run("InProgress", func(t *testing.T) {
+		w := do("/slow", "slow-1", "", "{}")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, strconv.Itoa(http.StatusConflict), w.Body.String())
+	})
+
+	// Test that bodies too large to keep in memory are passed on whole and fingerprinted
+	t.Run("LargeBody", func(t *testing.T) {
+		calls = 0
+		body := strings.Repeat("x", maxIdempotentMemory+1024)
+		w := do("/import", "import-1", "", body)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, strconv.Itoa(len(body)), w.Body.String())
+
+		w = do("/import", "import-1", "", body)
+		assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, 1, calls)
+
+		w = do("/import", "import-1", "", body[:len(body)-1]+"y")
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+}
@@ -1,13 +1,17 @@
 package middleware
 
 import (
-	"log"
+	"net/http"
+	"runtime/debug"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/requestid"
 )
 
-// Logger is a middleware function that logs the request method, path and how long it took to process
+// Logger is a middleware function that logs the request method, path and how long it took to process,
+// prefixed with the identifier set by RequestID like every other log line of the request
 func Logger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -17,7 +21,7 @@ func Logger() gin.HandlerFunc {
 		
 		// Log request details
 		latency := time.Since(start)
-		log.Printf("| %3d | %13v | %s | %s |",
+		requestid.Printf(c.Request.Context(), "| %3d | %13v | %s | %s |",
 			c.Writer.Status(),
 			latency,
 			c.Request.Method,
@@ -26,6 +30,32 @@ func Logger() gin.HandlerFunc {
 	}
 }
 
+// Recovery turns a panic in a handler into a 500 problem response, logging the
+// panic and its stack under the request's identifier
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				// The handler asked for the connection to be dropped
+				panic(recovered)
+			}
+			
+			requestid.Printf(c.Request.Context(), "Panic: %v\n%s", recovered, debug.Stack())
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			problem.Write(c, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+		}()
+		
+		c.Next()
+	}
+}
+
 // HealthCheck provides a simple health check endpoint
 func HealthCheck() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -8,6 +8,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/models"
 )
 
 func TestLogger(t *testing.T) {
@@ -53,3 +55,29 @@ func TestHealthCheck(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Body.String(), "UP")
 }
+
+func TestActor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Actor())
+	router.POST("/products", func(c *gin.Context) {
+		repo := database.NewInMemoryRepository()
+		product, _ := repo.CreateProduct(c.Request.Context(), models.Product{Name: "Attributed", Description: "Test Description", Price: 1.0})
+		history, _ := repo.GetProductHistory(c.Request.Context(), product.ID)
+		c.String(http.StatusOK, history[0].Actor)
+	})
+
+	// Test that the header is recorded as the actor
+	req, _ := http.NewRequest(http.MethodPost, "/products", nil)
+	req.Header.Set(ActorHeader, " alice ")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "alice", w.Body.String())
+
+	// Test that requests without the header are anonymous
+	req, _ = http.NewRequest(http.MethodPost, "/products", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "", w.Body.String())
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/requestid"
+)
+
+// sweepInterval is how often a MemoryLimiterStore drops buckets that have
+// refilled, so clients that went away do not hold memory
+const sweepInterval = time.Minute
+
+// Limit is a token bucket budget of Requests, refilled evenly over Window.
+// A zero Limit does not limit.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Decision is the outcome of taking a token from a bucket
+type Decision struct {
+	Allowed bool
+	// Remaining is how many requests the bucket still allows now
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, when this
+	// one was not
+	RetryAfter time.Duration
+}
+
+// LimiterStore keeps the token buckets of RateLimit. MemoryLimiterStore
+// limits each replica on its own; a store shared between replicas, e.g. one
+// backed by Redis, enforces a single budget across them.
+type LimiterStore interface {
+	// Take spends a token from the bucket named key, filled at limit, and
+	// reports whether there was one
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Decision, error)
+}
+
+// RateLimitOptions configures RateLimit with the budgets of read (GET, HEAD
+// and OPTIONS) and write requests
+type RateLimitOptions struct {
+	Read  Limit
+	Write Limit
+	Store LimiterStore
+}
+
+// RateLimit limits each client's requests with token buckets, one for reads
+// and one for writes. Clients are told apart by API key or token subject
+// once authenticated, otherwise by IP address. Limited responses carry
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and a
+// request over budget gets 429 with Retry-After. Requests are let through if
+// the store fails, so an outage of a shared store does not take the API down.
+func RateLimit(options RateLimitOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		class, limit := "write", options.Write
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			class, limit = "read", options.Read
+		}
+		if limit.Requests <= 0 || limit.Window <= 0 {
+			c.Next()
+			return
+		}
+		
+		decision, err := options.Store.Take(c.Request.Context(), class+":"+client(c), limit, time.Now())
+		if err != nil {
+			requestid.Printf(c.Request.Context(), "Rate limiter unavailable, allowing request: %v", err)
+			c.Next()
+			return
+		}
+		
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(seconds(decision.Reset)))
+		if !decision.Allowed {
+			c.Header("Retry-After", strconv.Itoa(seconds(decision.RetryAfter)))
+			problem.Write(c, http.StatusTooManyRequests, problem.CodeRateLimited, "Too many "+class+" requests; retry after "+strconv.Itoa(seconds(decision.RetryAfter))+"s")
+			return
+		}
+		
+		c.Next()
+	}
+}
+
+// client names the caller a bucket belongs to
+func client(c *gin.Context) string {
+	if value, authenticated := c.Get(principalKey); authenticated {
+		return "subject:" + value.(Principal).Subject
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// seconds rounds d up to whole seconds, as rate limit headers carry them
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// MemoryLimiterStore is a LimiterStore holding buckets in memory
+type MemoryLimiterStore struct {
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket is the state of one token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// NewMemoryLimiterStore returns an empty MemoryLimiterStore
+func NewMemoryLimiterStore() *MemoryLimiterStore {
+	return &MemoryLimiterStore{buckets: make(map[string]*bucket)}
+}
+
+// Take implements LimiterStore
+func (s *MemoryLimiterStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Decision, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	
+	capacity := float64(limit.Requests)
+	perSecond := capacity / limit.Window.Seconds()
+	b, exists := s.buckets[key]
+	if !exists {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*perSecond)
+		b.updated = now
+	}
+	
+	var decision Decision
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = time.Duration((capacity - b.tokens) / perSecond * float64(time.Second))
+	b.full = now.Add(decision.Reset)
+	return decision, nil
+}
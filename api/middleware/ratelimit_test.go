@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore is a LimiterStore that is always unavailable
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit, time.Time) (Decision, error) {
+	return Decision{}, errors.New("store unavailable")
+}
+
+func TestRateLimit(t *testing.T) {
+	// Set Gin to test mode
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(options RateLimitOptions, principal *Principal) *gin.Engine {
+		router := gin.New()
+		if principal != nil {
+			router.Use(func(c *gin.Context) { c.Set(principalKey, *principal) })
+		}
+		router.Use(RateLimit(options))
+		router.GET("/products", func(c *gin.Context) { c.Status(http.StatusOK) })
+		router.POST("/products", func(c *gin.Context) { c.Status(http.StatusCreated) })
+		return router
+	}
+	do := func(router *gin.Engine, method, remoteAddr string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/products", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Test that a bucket allows a burst and then refills evenly
+	t.Run("Bucket", func(t *testing.T) {
+		store := NewMemoryLimiterStore()
+		limit := Limit{Requests: 2, Window: 10 * time.Second}
+		now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+		decision, err := store.Take(context.Background(), "k", limit, now)
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 1, decision.Remaining)
+		assert.Equal(t, 5*time.Second, decision.Reset)
+		decision, _ = store.Take(context.Background(), "k", limit, now)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 0, decision.Remaining)
+
+		decision, _ = store.Take(context.Background(), "k", limit, now.Add(time.Second))
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, 4*time.Second, decision.RetryAfter)
+		assert.Equal(t, 9*time.Second, decision.Reset)
+
+		decision, _ = store.Take(context.Background(), "k", limit, now.Add(5*time.Second))
+		assert.True(t, decision.Allowed)
+
+		// Other keys have their own bucket
+		decision, _ = store.Take(context.Background(), "other", limit, now)
+		assert.True(t, decision.Allowed)
+
+		// Refilled buckets are dropped by the next sweep
+		store.Take(context.Background(), "k", limit, now.Add(time.Hour))
+		assert.Len(t, store.buckets, 1)
+	})
+
+	// Test the headers and 429 response, with separate read and write budgets
+	t.Run("Headers", func(t *testing.T) {
+		router := newRouter(RateLimitOptions{
+			Read:  Limit{Requests: 2, Window: time.Minute},
+			Write: Limit{Requests: 1, Window: time.Minute},
+			Store: NewMemoryLimiterStore(),
+		}, nil)
+
+		w := do(router, http.MethodPost, "192.0.2.1:1234")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+
+		w = do(router, http.MethodPost, "192.0.2.1:1234")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "rate_limited")
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+		// Reads have their own budget, and other clients their own buckets
+		w = do(router, http.MethodGet, "192.0.2.1:1234")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, http.StatusCreated, do(router, http.MethodPost, "192.0.2.2:1234").Code)
+	})
+
+	// Test that authenticated callers are limited by identity, not address
+	t.Run("Principal", func(t *testing.T) {
+		options := RateLimitOptions{Write: Limit{Requests: 1, Window: time.Minute}, Store: NewMemoryLimiterStore()}
+		router := newRouter(options, &Principal{Subject: "apikey:1"})
+		assert.Equal(t, http.StatusCreated, do(router, http.MethodPost, "192.0.2.1:1234").Code)
+		assert.Equal(t, http.StatusTooManyRequests, do(router, http.MethodPost, "192.0.2.2:1234").Code)
+
+		// Unlimited reads carry no headers
+		w := do(router, http.MethodGet, "192.0.2.1:1234")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+
+		other := newRouter(options, &Principal{Subject: "apikey:2"})
+		assert.Equal(t, http.StatusCreated, do(other, http.MethodPost, "192.0.2.1:1234").Code)
+	})
+
+	// Test that requests are let through when the store fails
+	t.Run("StoreFailure", func(t *testing.T) {
+		router := newRouter(RateLimitOptions{Write: Limit{Requests: 1, Window: time.Minute}, Store: failingStore{}}, nil)
+		for i := 0; i < 3; i++ {
+			w := do(router, http.MethodPost, "192.0.2.1:1234")
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/internal/requestid"
+)
+
+const (
+	// RequestIDHeader carries the identifier of a request, in both directions
+	RequestIDHeader = "X-Request-ID"
+
+	// TraceparentHeader is the W3C Trace Context header whose trace ID
+	// identifies a request that has no RequestIDHeader
+	TraceparentHeader = "traceparent"
+)
+
+// RequestID identifies every request by its RequestIDHeader, the trace ID
+// of its TraceparentHeader or a generated identifier. The identifier is put
+// on the request's context, for log lines and problem responses, and echoed
+// in the response's RequestIDHeader.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := requestid.Resolve(c.GetHeader(RequestIDHeader), c.GetHeader(TraceparentHeader))
+		c.Request = c.Request.WithContext(requestid.WithID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		
+		c.Next()
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/requestid"
+)
+
+func TestRequestID(t *testing.T) {
+	// Set Gin to test mode
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequestID(), Logger(), Recovery())
+	router.GET("/id", func(c *gin.Context) {
+		c.String(http.StatusOK, requestid.FromContext(c.Request.Context()))
+	})
+	router.GET("/fail", func(c *gin.Context) {
+		problem.Write(c, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	do := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Test that a client's identifier is kept and echoed
+	t.Run("Accepted", func(t *testing.T) {
+		w := do("/id", map[string]string{RequestIDHeader: "req-42"})
+		assert.Equal(t, "req-42", w.Body.String())
+		assert.Equal(t, "req-42", w.Header().Get(RequestIDHeader))
+
+		w = do("/id", map[string]string{TraceparentHeader: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"})
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", w.Header().Get(RequestIDHeader))
+	})
+
+	// Test that requests without a usable identifier get a new one
+	t.Run("Generated", func(t *testing.T) {
+		w := do("/id", map[string]string{RequestIDHeader: "not\tsafe"})
+		assert.NotEmpty(t, w.Body.String())
+		assert.NotEqual(t, "not\tsafe", w.Body.String())
+		assert.Equal(t, w.Body.String(), w.Header().Get(RequestIDHeader))
+		assert.NotEqual(t, w.Body.String(), do("/id", nil).Body.String())
+	})
+
+	// Test that problem responses name the request
+	t.Run("Problem", func(t *testing.T) {
+		w := do("/fail", map[string]string{RequestIDHeader: "req-500"})
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), `"requestId":"req-500"`)
+	})
+
+	// Test that request and panic log lines start with the identifier
+	t.Run("Logged", func(t *testing.T) {
+		var logs bytes.Buffer
+		log.SetOutput(&logs)
+		defer log.SetOutput(os.Stderr)
+
+		w := do("/panic", map[string]string{RequestIDHeader: "req-panic"})
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), `"requestId":"req-panic"`)
+		assert.Contains(t, logs.String(), "[req-panic] Panic: boom")
+		assert.Contains(t, logs.String(), "[req-panic] | 500 |")
+	})
+}
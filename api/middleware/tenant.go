@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/api/problem"
+	"github.com/yourusername/product-service/internal/tenancy"
+)
+
+// TenantHeader names the tenant a request is for
+const TenantHeader = "X-Tenant-ID"
+
+// TenantOptions configures tenant resolution. Domain, when set, lets the
+// tenant be named by a subdomain of it, as in brand-a.catalog.example.com.
+type TenantOptions struct {
+	Tenants []string
+	Domain  string
+}
+
+// Tenant resolves the tenant a request acts for and records it on the
+// request's context. A caller bound to a tenant, by a token's tenant claim
+// or an API key, always acts for that tenant and is refused if the request
+// names another. Unbound callers name the tenant with TenantHeader or a
+// subdomain; when authentication is enabled only admins may do so.
+func Tenant(options TenantOptions) gin.HandlerFunc {
+	known := make(map[string]bool, len(options.Tenants))
+	for _, tenant := range options.Tenants {
+		known[tenant] = true
+	}
+	
+	return func(c *gin.Context) {
+		requested := strings.TrimSpace(c.GetHeader(TenantHeader))
+		if requested == "" && options.Domain != "" {
+			requested = subdomain(c.Request.Host, options.Domain)
+		}
+		
+		tenant := requested
+		if value, authenticated := c.Get(principalKey); authenticated {
+			principal := value.(Principal)
+			switch {
+			case principal.Tenant != "":
+				if requested != "" && requested != principal.Tenant {
+					problem.Write(c, http.StatusForbidden, problem.CodeForbidden, fmt.Sprintf("Credentials are for tenant %q", principal.Tenant))
+					return
+				}
+				tenant = principal.Tenant
+			case !principal.HasRole(RoleAdmin):
+				problem.Write(c, http.StatusForbidden, problem.CodeForbidden, "Credentials are not bound to a tenant")
+				return
+			}
+		}
+		
+		if tenant == "" {
+			problem.Write(c, http.StatusBadRequest, problem.CodeUnknownTenant, "A tenant is required; name it with the "+TenantHeader+" header")
+			return
+		}
+		if !known[tenant] {
+			problem.Write(c, http.StatusBadRequest, problem.CodeUnknownTenant, fmt.Sprintf("Unknown tenant %q", tenant))
+			return
+		}
+		
+		c.Request = c.Request.WithContext(tenancy.WithTenant(c.Request.Context(), tenant))
+		
+		c.Next()
+	}
+}
+
+// subdomain returns the label host has directly under domain, or "" if host
+// is not a single-label subdomain of it
+func subdomain(host, domain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label, found := strings.CutSuffix(strings.ToLower(host), "."+domain)
+	if !found || label == "" || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/yourusername/product-service/internal/tenancy"
+)
+
+func TestTenant(t *testing.T) {
+	// Set Gin to test mode
+	gin.SetMode(gin.TestMode)
+
+	options := TenantOptions{Tenants: []string{"brand-a", "brand-b"}, Domain: "catalog.example.com"}
+	newRouter := func(principal *Principal) *gin.Engine {
+		router := gin.New()
+		if principal != nil {
+			router.Use(func(c *gin.Context) { c.Set(principalKey, *principal) })
+		}
+		router.Use(Tenant(options))
+		router.GET("/products", func(c *gin.Context) {
+			c.String(http.StatusOK, tenancy.FromContext(c.Request.Context()))
+		})
+		return router
+	}
+	do := func(router *gin.Engine, host, tenant string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+host+"/products", nil)
+		if tenant != "" {
+			req.Header.Set(TenantHeader, tenant)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Test naming the tenant with the header or a subdomain
+	t.Run("Anonymous", func(t *testing.T) {
+		router := newRouter(nil)
+		w := do(router, "localhost:8080", "brand-a")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "brand-a", w.Body.String())
+
+		w = do(router, "Brand-B.catalog.example.com:443", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "brand-b", w.Body.String())
+
+		// The header wins over the subdomain
+		assert.Equal(t, "brand-a", do(router, "brand-b.catalog.example.com", "brand-a").Body.String())
+
+		assert.Equal(t, http.StatusBadRequest, do(router, "localhost", "").Code)
+		assert.Equal(t, http.StatusBadRequest, do(router, "localhost", "brand-c").Code)
+		assert.Equal(t, http.StatusBadRequest, do(router, "x.brand-a.catalog.example.com", "").Code)
+		assert.Equal(t, http.StatusBadRequest, do(router, "catalog.example.com", "").Code)
+	})
+
+	// Test that bound callers act for their own tenant only
+	t.Run("Bound", func(t *testing.T) {
+		router := newRouter(&Principal{Subject: "alice", Tenant: "brand-a", Roles: map[string]bool{RoleCatalogWrite: true}})
+		w := do(router, "localhost", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "brand-a", w.Body.String())
+		assert.Equal(t, http.StatusOK, do(router, "brand-a.catalog.example.com", "").Code)
+
+		assert.Equal(t, http.StatusForbidden, do(router, "localhost", "brand-b").Code)
+		assert.Equal(t, http.StatusForbidden, do(router, "brand-b.catalog.example.com", "").Code)
+	})
+
+	// Test that only admins may choose a tenant when not bound to one
+	t.Run("Unbound", func(t *testing.T) {
+		router := newRouter(&Principal{Subject: "bob", Roles: map[string]bool{RoleCatalogWrite: true}})
+		assert.Equal(t, http.StatusForbidden, do(router, "localhost", "brand-a").Code)
+
+		router = newRouter(&Principal{Subject: "root", Roles: map[string]bool{RoleAdmin: true}})
+		w := do(router, "localhost", "brand-b")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "brand-b", w.Body.String())
+	})
+}
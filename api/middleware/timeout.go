@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/api/problem"
+)
+
+// Timeout bounds every request with a deadline on its context. Routes are
+// looked up as "METHOD /registered/:path" in routes and fall back to
+// defaultTimeout; a zero timeout leaves the request unbounded.
+//
+// Handlers must pass c.Request.Context() to the repository for the deadline
+// to take effect. If the deadline expires and the handler has not written a
+// response, a 504 problem is sent; a request cancelled by the client gets 503.
+func Timeout(defaultTimeout time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout, ok := routes[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			timeout = defaultTimeout
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+		
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		
+		c.Next()
+		
+		if c.Writer.Written() {
+			return
+		}
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			problem.Write(c, http.StatusGatewayTimeout, problem.CodeTimeout, "Request timed out")
+		case errors.Is(ctx.Err(), context.Canceled):
+			problem.Write(c, http.StatusServiceUnavailable, problem.CodeUnavailable, "Request was cancelled")
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/yourusername/product-service/api/problem"
+)
+
+func TestTimeout(t *testing.T) {
+	// Set Gin to test mode
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Timeout(time.Second, map[string]time.Duration{
+		"GET /slow/:id": 10 * time.Millisecond,
+	}))
+
+	// Blocks until the request context is done and writes nothing, like a
+	// handler whose repository call was cancelled
+	block := func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	}
+	router.GET("/slow/:id", block)
+	router.GET("/deadline", func(c *gin.Context) {
+		deadline, ok := c.Request.Context().Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+		c.String(http.StatusOK, "OK")
+	})
+	router.GET("/cancelled", block)
+
+	// Test that a route-specific timeout produces a 504 problem
+	t.Run("RouteTimeout", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/slow/1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), problem.CodeTimeout)
+	})
+
+	// Test that other routes get the default deadline
+	t.Run("DefaultTimeout", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/deadline", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	// Test that a client disconnect produces a 503 problem
+	t.Run("ClientCancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/cancelled", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
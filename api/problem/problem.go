@@ -0,0 +1,72 @@
+// Package problem renders RFC 7807 "problem details" error responses.
+package problem
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/product-service/internal/requestid"
+)
+
+// ContentType is the media type of problem details bodies
+const ContentType = "application/problem+json"
+
+// Stable machine-readable error codes. Clients may switch on these; the
+// human-readable title and detail can change at any time.
+const (
+	CodeValidationFailed      = "validation_failed"
+	CodeInvalidCursor         = "invalid_cursor"
+	CodeInvalidPatch          = "invalid_patch"
+	CodePatchTestFailed       = "patch_test_failed"
+	CodeUnsupportedMediaType  = "unsupported_media_type"
+	CodeUnauthorized          = "unauthorized"
+	CodeForbidden             = "forbidden"
+	CodeNotFound              = "not_found"
+	CodeUnknownTenant         = "unknown_tenant"
+	CodeQuotaExceeded         = "quota_exceeded"
+	CodeRateLimited           = "rate_limited"
+	CodeIdempotencyKeyReused  = "idempotency_key_reused"
+	CodeConflict              = "conflict"
+	CodePreconditionFailed    = "precondition_failed"
+	CodeInsufficientInventory = "insufficient_inventory"
+	CodeReservationExpired    = "reservation_expired"
+	CodeBatchAborted          = "batch_aborted"
+	CodeNotSupported          = "not_supported"
+	CodeUnavailable           = "unavailable"
+	CodeTimeout               = "timeout"
+	CodeInternal              = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details body with a stable error code
+// @Description RFC 7807 problem details
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// New builds a problem for the given status and code
+func New(status int, code, detail string) Problem {
+	return Problem{
+		Type:   "/problems/" + strings.ReplaceAll(code, "_", "-"),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write sends a problem response and aborts the handler chain
+func Write(c *gin.Context, status int, code, detail string) {
+	p := New(status, code, detail)
+	p.Instance = c.Request.URL.Path
+	p.RequestID = requestid.FromContext(c.Request.Context())
+
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(status, p)
+}
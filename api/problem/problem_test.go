@@ -0,0 +1,39 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	// Set Gin to test mode
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/test", func(c *gin.Context) {
+		Write(c, http.StatusNotFound, CodeNotFound, "Product not found")
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+
+	var p Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, Problem{
+		Type:     "/problems/not-found",
+		Title:    "Not Found",
+		Status:   http.StatusNotFound,
+		Detail:   "Product not found",
+		Instance: "/test",
+		Code:     CodeNotFound,
+	}, p)
+}
@@ -0,0 +1,201 @@
+// Package apikeys issues and verifies API keys for service-to-service callers.
+// A key is shown once when it is minted or rotated; only its SHA-256 hash is
+// kept, so a leaked store does not leak usable keys.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/tenancy"
+)
+
+var (
+	// ErrNotFound is returned when an API key does not exist
+	ErrNotFound = errors.New("API key not found")
+
+	// ErrRevoked is returned when using or rotating a revoked key
+	ErrRevoked = errors.New("API key has been revoked")
+
+	// ErrExpired is returned when using a key past its expiry
+	ErrExpired = errors.New("API key has expired")
+
+	// ErrInvalidKey is returned when a presented key matches no stored key
+	ErrInvalidKey = errors.New("invalid API key")
+)
+
+const (
+	// keyPrefix marks a string as one of this service's API keys
+	keyPrefix = "pk_"
+
+	// prefixLength is how much of a key is kept in the clear to identify it
+	prefixLength = 11
+
+	// lastUsedResolution is how stale LastUsedAt may get before a use is
+	// recorded, so busy keys do not write to the store on every request
+	lastUsedResolution = time.Minute
+)
+
+// Service mints, rotates, revokes and verifies API keys held in a Store
+type Service struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewService returns a Service keeping keys in store
+func NewService(store Store) *Service {
+	return &Service{store: store, now: time.Now}
+}
+
+// List returns the keys of the tenant in ctx, oldest first, without secrets
+func (s *Service) List(ctx context.Context) ([]models.APIKey, error) {
+	keys, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := keys[:0]
+	for _, key := range keys {
+		if tenancy.Visible(ctx, key.Tenant) {
+			visible = append(visible, key)
+		}
+	}
+	return visible, nil
+}
+
+// Get returns one of the keys of the tenant in ctx, without its secret
+func (s *Service) Get(ctx context.Context, id string) (models.APIKey, error) {
+	key, err := s.store.Get(ctx, id)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if !tenancy.Visible(ctx, key.Tenant) {
+		return models.APIKey{}, ErrNotFound
+	}
+	return key, nil
+}
+
+// Mint creates a key for the tenant in ctx. The returned key is the only copy
+// with Key set.
+func (s *Service) Mint(ctx context.Context, req models.APIKeyRequest) (models.APIKey, error) {
+	secret, err := newKey()
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	key := models.APIKey{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Scope:     req.Scope,
+		Tenant:    tenancy.FromContext(ctx),
+		Prefix:    secret[:prefixLength],
+		Hash:      hash(secret),
+		CreatedAt: s.now().UTC(),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.store.Create(ctx, key); err != nil {
+		return models.APIKey{}, err
+	}
+
+	key.Key = secret
+	return key, nil
+}
+
+// Rotate replaces a key's secret, invalidating the old one at once. The
+// expiry moves when the rotation sets one.
+func (s *Service) Rotate(ctx context.Context, id string, rotation models.APIKeyRotation) (models.APIKey, error) {
+	secret, err := newKey()
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	key, err := s.store.Modify(ctx, id, func(key models.APIKey) (models.APIKey, error) {
+		if !tenancy.Visible(ctx, key.Tenant) {
+			return models.APIKey{}, ErrNotFound
+		}
+		if key.RevokedAt != nil {
+			return models.APIKey{}, ErrRevoked
+		}
+
+		now := s.now().UTC()
+		key.Prefix = secret[:prefixLength]
+		key.Hash = hash(secret)
+		key.RotatedAt = &now
+		if rotation.ExpiresAt != nil {
+			key.ExpiresAt = rotation.ExpiresAt
+		}
+		return key, nil
+	})
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	key.Key = secret
+	return key, nil
+}
+
+// Revoke disables a key for good. Revoking a revoked key is a no-op.
+func (s *Service) Revoke(ctx context.Context, id string) (models.APIKey, error) {
+	return s.store.Modify(ctx, id, func(key models.APIKey) (models.APIKey, error) {
+		if !tenancy.Visible(ctx, key.Tenant) {
+			return models.APIKey{}, ErrNotFound
+		}
+		if key.RevokedAt == nil {
+			now := s.now().UTC()
+			key.RevokedAt = &now
+		}
+		return key, nil
+	})
+}
+
+// Verify returns the key matching a presented secret, recording its use. It
+// fails with ErrInvalidKey, ErrRevoked or ErrExpired.
+func (s *Service) Verify(ctx context.Context, secret string) (models.APIKey, error) {
+	key, err := s.store.FindByHash(ctx, hash(secret))
+	if errors.Is(err, ErrNotFound) {
+		return models.APIKey{}, ErrInvalidKey
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	now := s.now().UTC()
+	if key.RevokedAt != nil {
+		return models.APIKey{}, ErrRevoked
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return models.APIKey{}, ErrExpired
+	}
+
+	// Only the use is recorded, so a concurrent revocation or rotation is
+	// never overwritten with this copy of the key
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := s.store.TouchLastUsed(ctx, key.ID, now); err != nil {
+			return models.APIKey{}, err
+		}
+		key.LastUsedAt = &now
+	}
+	return key, nil
+}
+
+// newKey returns a random key of 32 bytes, base64url encoded after keyPrefix
+func newKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hash returns the hex SHA-256 of a key, as kept in the store
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,252 @@
+package apikeys
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/tenancy"
+)
+
+// interleavingStore runs afterFind between a key being found by hash and
+// Verify recording its use, to interleave admin changes deterministically
+type interleavingStore struct {
+	*MemoryStore
+	afterFind func()
+}
+
+func (s *interleavingStore) FindByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	key, err := s.MemoryStore.FindByHash(ctx, hash)
+	if s.afterFind != nil {
+		s.afterFind()
+	}
+	return key, err
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	newService := func() (*Service, *MemoryStore) {
+		store := NewMemoryStore()
+		service := NewService(store)
+		service.now = func() time.Time { return now }
+		return service, store
+	}
+
+	// Test that a minted key is returned once and stored only as a hash
+	t.Run("Mint", func(t *testing.T) {
+		service, store := newService()
+		key, err := service.Mint(ctx, models.APIKeyRequest{Name: "orders", Scope: models.APIKeyScopeReadOnly})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(key.Key, keyPrefix))
+		assert.Equal(t, key.Key[:prefixLength], key.Prefix)
+		assert.Equal(t, now, key.CreatedAt)
+
+		stored, err := store.Get(ctx, key.ID)
+		require.NoError(t, err)
+		assert.Empty(t, stored.Key)
+		assert.NotContains(t, stored.Hash, key.Key)
+		assert.Equal(t, hash(key.Key), stored.Hash)
+
+		listed, err := service.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Empty(t, listed[0].Key)
+
+		other, err := service.Mint(ctx, models.APIKeyRequest{Name: "orders", Scope: models.APIKeyScopeReadOnly})
+		require.NoError(t, err)
+		assert.NotEqual(t, key.Key, other.Key)
+	})
+
+	// Test verification and last-used tracking
+	t.Run("Verify", func(t *testing.T) {
+		service, _ := newService()
+		key, err := service.Mint(ctx, models.APIKeyRequest{Name: "orders", Scope: models.APIKeyScopeFull})
+		require.NoError(t, err)
+
+		verified, err := service.Verify(ctx, key.Key)
+		require.NoError(t, err)
+		assert.Equal(t, key.ID, verified.ID)
+		assert.Equal(t, models.APIKeyScopeFull, verified.Scope)
+		require.NotNil(t, verified.LastUsedAt)
+		assert.Equal(t, now, *verified.LastUsedAt)
+
+		// Uses within a minute are not recorded again
+		first := now
+		now = now.Add(30 * time.Second)
+		verified, err = service.Verify(ctx, key.Key)
+		require.NoError(t, err)
+		assert.Equal(t, first, *verified.LastUsedAt)
+
+		now = now.Add(time.Minute)
+		service.Verify(ctx, key.Key)
+		stored, _ := service.Get(ctx, key.ID)
+		assert.Equal(t, now, *stored.LastUsedAt)
+
+		_, err = service.Verify(ctx, "pk_unknown")
+		assert.ErrorIs(t, err, ErrInvalidKey)
+	})
+
+	// Test that expired keys are rejected
+	t.Run("Expiry", func(t *testing.T) {
+		service, _ := newService()
+		expiresAt := now.Add(time.Hour)
+		key, err := service.Mint(ctx, models.APIKeyRequest{Name: "batch", Scope: models.APIKeyScopeInventory, ExpiresAt: &expiresAt})
+		require.NoError(t, err)
+
+		_, err = service.Verify(ctx, key.Key)
+		assert.NoError(t, err)
+
+		now = expiresAt
+		_, err = service.Verify(ctx, key.Key)
+		assert.ErrorIs(t, err, ErrExpired)
+	})
+
+	// Test that rotation replaces the secret and keeps the key's identity
+	t.Run("Rotate", func(t *testing.T) {
+		service, _ := newService()
+		key, err := service.Mint(ctx, models.APIKeyRequest{Name: "orders", Scope: models.APIKeyScopeReadOnly})
+		require.NoError(t, err)
+
+		expiresAt := now.Add(24 * time.Hour)
+		rotated, err := service.Rotate(ctx, key.ID, models.APIKeyRotation{ExpiresAt: &expiresAt})
+		require.NoError(t, err)
+		assert.Equal(t, key.ID, rotated.ID)
+		assert.NotEqual(t, key.Key, rotated.Key)
+		assert.Equal(t, expiresAt, *rotated.ExpiresAt)
+		require.NotNil(t, rotated.RotatedAt)
+
+		_, err = service.Verify(ctx, key.Key)
+		assert.ErrorIs(t, err, ErrInvalidKey)
+		verified, err := service.Verify(ctx, rotated.Key)
+		require.NoError(t, err)
+		assert.Equal(t, key.ID, verified.ID)
+
+		_, err = service.Rotate(ctx, "missing", models.APIKeyRotation{})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	// Test that revoked keys can neither be used nor rotated
+	t.Run("Revoke", func(t *testing.T) {
+		service, _ := newService()
+		key, err := service.Mint(ctx, models.APIKeyRequest{Name: "orders", Scope: models.APIKeyScopeFull})
+		require.NoError(t, err)
+
+		revoked, err := service.Revoke(ctx, key.ID)
+		require.NoError(t, err)
+		require.NotNil(t, revoked.RevokedAt)
+
+		_, err = service.Verify(ctx, key.Key)
+		assert.ErrorIs(t, err, ErrRevoked)
+		_, err = service.Rotate(ctx, key.ID, models.APIKeyRotation{})
+		assert.ErrorIs(t, err, ErrRevoked)
+
+		again, err := service.Revoke(ctx, key.ID)
+		require.NoError(t, err)
+		assert.Equal(t, *revoked.RevokedAt, *again.RevokedAt)
+
+		_, err = service.Revoke(ctx, "missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	// Test that keys belong to the tenant they were minted in
+	t.Run("Tenants", func(t *testing.T) {
+		service, _ := newService()
+		brandA := tenancy.WithTenant(ctx, "brand-a")
+		brandB := tenancy.WithTenant(ctx, "brand-b")
+		key, err := service.Mint(brandA, models.APIKeyRequest{Name: "orders", Scope: models.APIKeyScopeFull})
+		require.NoError(t, err)
+		assert.Equal(t, "brand-a", key.Tenant)
+
+		listed, err := service.List(brandB)
+		require.NoError(t, err)
+		assert.Empty(t, listed)
+		_, err = service.Get(brandB, key.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = service.Rotate(brandB, key.ID, models.APIKeyRotation{})
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = service.Revoke(brandB, key.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		listed, err = service.List(brandA)
+		require.NoError(t, err)
+		assert.Len(t, listed, 1)
+		verified, err := service.Verify(ctx, key.Key)
+		require.NoError(t, err)
+		assert.Equal(t, "brand-a", verified.Tenant)
+	})
+
+	// Test that recording a use never undoes a revocation or rotation made
+	// while the key was being verified
+	t.Run("VerifyDuringRevoke", func(t *testing.T) {
+		store := &interleavingStore{MemoryStore: NewMemoryStore()}
+		service := NewService(store)
+		key, err := service.Mint(ctx, models.APIKeyRequest{Name: "orders", Scope: models.APIKeyScopeFull})
+		require.NoError(t, err)
+
+		store.afterFind = func() {
+			store.afterFind = nil
+			_, err := service.Revoke(ctx, key.ID)
+			require.NoError(t, err)
+		}
+		_, err = service.Verify(ctx, key.Key)
+		require.NoError(t, err)
+		_, err = service.Verify(ctx, key.Key)
+		assert.ErrorIs(t, err, ErrRevoked)
+		stored, _ := service.Get(ctx, key.ID)
+		assert.NotNil(t, stored.RevokedAt)
+		assert.NotNil(t, stored.LastUsedAt)
+
+		other, err := service.Mint(ctx, models.APIKeyRequest{Name: "batch", Scope: models.APIKeyScopeFull})
+		require.NoError(t, err)
+		var rotated models.APIKey
+		store.afterFind = func() {
+			store.afterFind = nil
+			rotated, err = service.Rotate(ctx, other.ID, models.APIKeyRotation{})
+			require.NoError(t, err)
+		}
+		_, err = service.Verify(ctx, other.Key)
+		require.NoError(t, err)
+		_, err = service.Verify(ctx, other.Key)
+		assert.ErrorIs(t, err, ErrInvalidKey)
+		_, err = service.Verify(ctx, rotated.Key)
+		assert.NoError(t, err)
+	})
+
+	// Test concurrent verification and revocation, for the race detector
+	t.Run("ConcurrentRevoke", func(t *testing.T) {
+		service := NewService(NewMemoryStore())
+		var clock int64
+		service.now = func() time.Time {
+			return now.Add(time.Duration(atomic.AddInt64(&clock, 1)) * lastUsedResolution)
+		}
+		key, err := service.Mint(ctx, models.APIKeyRequest{Name: "orders", Scope: models.APIKeyScopeFull})
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 200; j++ {
+					service.Verify(ctx, key.Key)
+				}
+			}()
+		}
+		_, err = service.Revoke(ctx, key.ID)
+		require.NoError(t, err)
+		wg.Wait()
+
+		stored, err := service.Get(ctx, key.ID)
+		require.NoError(t, err)
+		assert.NotNil(t, stored.RevokedAt)
+		_, err = service.Verify(ctx, key.Key)
+		assert.ErrorIs(t, err, ErrRevoked)
+	})
+}
@@ -0,0 +1,158 @@
+package apikeys
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// Store persists API keys. Implementations never see a key itself, only its
+// hash, and must be safe for concurrent use. Changes go through Modify or
+// TouchLastUsed so that concurrent updates to one key cannot undo each other.
+type Store interface {
+	Create(ctx context.Context, key models.APIKey) error
+	Get(ctx context.Context, id string) (models.APIKey, error)
+	FindByHash(ctx context.Context, hash string) (models.APIKey, error)
+	List(ctx context.Context) ([]models.APIKey, error)
+
+	// Modify replaces a key with the result of calling modify on its current
+	// state, atomically. An error from modify aborts the change and is
+	// returned as is.
+	Modify(ctx context.Context, id string, modify func(models.APIKey) (models.APIKey, error)) (models.APIKey, error)
+
+	// TouchLastUsed records a use of a key at at, leaving everything else
+	// about the key as it is. Earlier times than the recorded one are ignored.
+	TouchLastUsed(ctx context.Context, id string, at time.Time) error
+}
+
+// MemoryStore is a Store holding keys in memory. Keys are lost on restart.
+type MemoryStore struct {
+	mutex  sync.RWMutex
+	keys   map[string]models.APIKey
+	byHash map[string]string // key IDs by hash
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		keys:   make(map[string]models.APIKey),
+		byHash: make(map[string]string),
+	}
+}
+
+// Create stores a new key
+func (s *MemoryStore) Create(ctx context.Context, key models.APIKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.keys[key.ID] = key
+	s.byHash[key.Hash] = key.ID
+	return nil
+}
+
+// Get returns a key by ID
+func (s *MemoryStore) Get(ctx context.Context, id string) (models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return models.APIKey{}, err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	key, exists := s.keys[id]
+	if !exists {
+		return models.APIKey{}, ErrNotFound
+	}
+	return key, nil
+}
+
+// FindByHash returns the key whose hash is hash
+func (s *MemoryStore) FindByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return models.APIKey{}, err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	id, exists := s.byHash[hash]
+	if !exists {
+		return models.APIKey{}, ErrNotFound
+	}
+	return s.keys[id], nil
+}
+
+// List returns every key, oldest first
+func (s *MemoryStore) List(ctx context.Context) ([]models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := make([]models.APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+// Modify replaces a stored key under the write lock, re-indexing it if its
+// hash changed
+func (s *MemoryStore) Modify(ctx context.Context, id string, modify func(models.APIKey) (models.APIKey, error)) (models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return models.APIKey{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous, exists := s.keys[id]
+	if !exists {
+		return models.APIKey{}, ErrNotFound
+	}
+	key, err := modify(previous)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	key.ID = previous.ID
+	delete(s.byHash, previous.Hash)
+	s.keys[id] = key
+	s.byHash[key.Hash] = id
+	return key, nil
+}
+
+// TouchLastUsed sets a key's LastUsedAt
+func (s *MemoryStore) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, exists := s.keys[id]
+	if !exists {
+		return ErrNotFound
+	}
+	if key.LastUsedAt == nil || at.After(*key.LastUsedAt) {
+		key.LastUsedAt = &at
+		s.keys[id] = key
+	}
+	return nil
+}
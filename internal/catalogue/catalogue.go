@@ -0,0 +1,40 @@
+// Package catalogue reads and writes products as CSV or newline-delimited
+// JSON, one product at a time, so whole catalogues never sit in memory.
+package catalogue
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Supported formats
+const (
+	CSV    = "csv"
+	NDJSON = "ndjson"
+)
+
+// ContentType returns the media type of format
+func ContentType(format string) string {
+	if format == CSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// ParseFormat accepts a format name or one of the media types ContentType returns
+func ParseFormat(value string) (string, error) {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(value, ";")[0]))
+	switch mediaType {
+	case CSV, "text/csv":
+		return CSV, nil
+	case NDJSON, "application/x-ndjson", "application/ndjson":
+		return NDJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q, expected csv or ndjson", value)
+	}
+}
+
+// columns are the CSV columns written by export and accepted by import, in
+// order. Import ignores createdAt, updatedAt and version, which the
+// repository owns, so an export can be edited and imported again.
+var columns = []string{"id", "name", "description", "price", "inventoryCount", "createdAt", "updatedAt", "version"}
@@ -0,0 +1,142 @@
+package catalogue
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// decodeAll reads every row from input
+func decodeAll(t *testing.T, input string, format string) []Row {
+	decoder, err := NewDecoder(strings.NewReader(input), format)
+	require.NoError(t, err)
+
+	var rows []Row
+	for {
+		row, err := decoder.Decode()
+		if err == io.EOF {
+			return rows
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	products := []models.Product{
+		{ID: "1", Name: "Plain", Description: "Test Description", Price: 9.99, InventoryCount: 3, CreatedAt: now, UpdatedAt: now, Version: 2},
+		{ID: "2", Name: "Quoted, \"with\" commas", Description: "Line\nbreak", Price: 1, InventoryCount: 0, CreatedAt: now, UpdatedAt: now, Version: 1},
+	}
+
+	for _, format := range []string{CSV, NDJSON} {
+		// Test that exported products import unchanged, minus repository-owned fields
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewEncoder(&buf, format)
+			for _, product := range products {
+				require.NoError(t, encoder.Encode(product))
+			}
+			require.NoError(t, encoder.Flush())
+
+			rows := decodeAll(t, buf.String(), format)
+			require.Len(t, rows, len(products))
+			for i, row := range rows {
+				assert.NoError(t, row.Err)
+				want := products[i]
+				want.CreatedAt, want.UpdatedAt, want.Version = time.Time{}, time.Time{}, 0
+				assert.Equal(t, want, row.Product)
+			}
+		})
+	}
+
+	// Test that an empty CSV export still has its header
+	t.Run("EmptyCSV", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewEncoder(&buf, CSV).Flush())
+		assert.Equal(t, strings.Join(columns, ",")+"\n", buf.String())
+	})
+}
+
+func TestDecoder(t *testing.T) {
+	// Test that CSV columns may be reordered and omitted
+	t.Run("CSVColumns", func(t *testing.T) {
+		rows := decodeAll(t, "price,name\n2.5,Widget\n", CSV)
+		require.Len(t, rows, 1)
+		assert.Equal(t, 2, rows[0].Line)
+		assert.Equal(t, models.Product{Name: "Widget", Price: 2.5}, rows[0].Product)
+	})
+
+	// Test that header problems fail the whole input
+	t.Run("CSVHeader", func(t *testing.T) {
+		_, err := NewDecoder(strings.NewReader(""), CSV)
+		assert.Error(t, err)
+
+		_, err = NewDecoder(strings.NewReader("name,colour\n"), CSV)
+		assert.ErrorContains(t, err, "colour")
+	})
+
+	// Test that bad rows are reported against their line and decoding carries on
+	t.Run("RowErrors", func(t *testing.T) {
+		rows := decodeAll(t, "name,price\nA,cheap\nB,2\nC,\"x\"y\nD,4\n", CSV)
+		require.Len(t, rows, 4)
+		assert.ErrorContains(t, rows[0].Err, "price")
+		assert.NoError(t, rows[1].Err)
+		assert.Equal(t, 4, rows[2].Line)
+		assert.Error(t, rows[2].Err)
+		assert.Equal(t, "D", rows[3].Product.Name)
+
+		// A malformed first field spoils only its row as well
+		for _, input := range []string{"name,price\na\"b,2\nE,5\n", "name,price\n\"x\"y,2\nE,5\n"} {
+			rows = decodeAll(t, input, CSV)
+			require.Len(t, rows, 2, input)
+			assert.Equal(t, 2, rows[0].Line, input)
+			assert.Error(t, rows[0].Err, input)
+			assert.Equal(t, "E", rows[1].Product.Name, input)
+		}
+
+		rows = decodeAll(t, "{\"name\":\"A\"}\n\n{not json}\n{\"name\":\"C\",\"version\":7}\n", NDJSON)
+		require.Len(t, rows, 3)
+		assert.Equal(t, []int{1, 3, 4}, []int{rows[0].Line, rows[1].Line, rows[2].Line})
+		assert.Error(t, rows[1].Err)
+		assert.Equal(t, int64(0), rows[2].Product.Version)
+	})
+
+	// Test that an input that cannot be read further says where it stopped
+	t.Run("ReadError", func(t *testing.T) {
+		for format, input := range map[string]string{CSV: "name\nA\n", NDJSON: "{\"name\":\"A\"}\n"} {
+			decoder, err := NewDecoder(io.MultiReader(strings.NewReader(input), iotest.ErrReader(errors.New("connection reset"))), format)
+			require.NoError(t, err, format)
+
+			_, err = decoder.Decode()
+			require.NoError(t, err, format)
+			row, err := decoder.Decode()
+			assert.ErrorContains(t, err, "connection reset", format)
+			assert.Equal(t, map[string]int{CSV: 3, NDJSON: 2}[format], row.Line, format)
+		}
+	})
+}
+
+func TestParseFormat(t *testing.T) {
+	for value, want := range map[string]string{
+		"csv":                     CSV,
+		"text/csv; charset=utf-8": CSV,
+		"NDJSON":                  NDJSON,
+		"application/x-ndjson":    NDJSON,
+	} {
+		got, err := ParseFormat(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+
+	_, err := ParseFormat("application/json")
+	assert.Error(t, err)
+}
@@ -0,0 +1,160 @@
+package catalogue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// maxLineSize bounds a single NDJSON line
+const maxLineSize = 1 << 20
+
+// Row is one decoded record. Err is set when the record itself is malformed;
+// decoding can carry on with the next row.
+type Row struct {
+	Line    int
+	Product models.Product
+	Err     error
+}
+
+// Decoder reads products one at a time. Decode returns io.EOF after the last
+// row, and any other error when the input as a whole cannot be read further,
+// along with a Row whose Line is the line it stopped at.
+type Decoder interface {
+	Decode() (Row, error)
+}
+
+// NewDecoder returns a Decoder reading format from r. CSV input must start
+// with a header row naming its columns; columns may come in any order.
+func NewDecoder(r io.Reader, format string) (Decoder, error) {
+	if format == CSV {
+		return newCSVDecoder(r)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &ndjsonDecoder{scanner: scanner}, nil
+}
+
+type csvDecoder struct {
+	reader *csv.Reader
+	index  map[string]int // column name to field position
+	line   int            // line of the last record read
+}
+
+func newCSVDecoder(r io.Reader) (*csvDecoder, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing CSV header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	known := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		known[column] = true
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if !known[name] {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		index[name] = i
+	}
+
+	return &csvDecoder{reader: reader, index: index, line: 1}, nil
+}
+
+func (d *csvDecoder) Decode() (Row, error) {
+	record, err := d.reader.Read()
+	if err == io.EOF {
+		return Row{}, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		// A malformed record only spoils its own row. FieldPos is only
+		// defined for records that parsed, so take the line from the error.
+		line := parseErr.StartLine
+		if line == 0 {
+			line = parseErr.Line
+		}
+		d.line = line
+		return Row{Line: line, Err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return Row{Line: d.line + 1}, err
+	}
+
+	d.line, _ = d.reader.FieldPos(0)
+	row := Row{Line: d.line}
+	field := func(name string) string {
+		i, ok := d.index[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row.Product.ID = field("id")
+	row.Product.Name = field("name")
+	row.Product.Description = field("description")
+	if value := field("price"); value != "" {
+		if row.Product.Price, err = strconv.ParseFloat(value, 64); err != nil {
+			row.Err = fmt.Errorf("price %q is not a number", value)
+			return row, nil
+		}
+	}
+	if value := field("inventoryCount"); value != "" {
+		if row.Product.InventoryCount, err = strconv.Atoi(value); err != nil {
+			row.Err = fmt.Errorf("inventoryCount %q is not an integer", value)
+			return row, nil
+		}
+	}
+
+	return row, nil
+}
+
+type ndjsonDecoder struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (d *ndjsonDecoder) Decode() (Row, error) {
+	for d.scanner.Scan() {
+		d.line++
+		data := bytes.TrimSpace(d.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		row := Row{Line: d.line}
+		if err := json.Unmarshal(data, &row.Product); err != nil {
+			row.Err = err
+		}
+		// The repository owns these; ignore them as CSV import does
+		row.Product.CreatedAt = time.Time{}
+		row.Product.UpdatedAt = time.Time{}
+		row.Product.Version = 0
+		return row, nil
+	}
+
+	if err := d.scanner.Err(); err != nil {
+		return Row{Line: d.line + 1}, err
+	}
+	return Row{}, io.EOF
+}
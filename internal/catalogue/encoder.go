@@ -0,0 +1,76 @@
+package catalogue
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// Encoder writes products one at a time
+type Encoder interface {
+	Encode(product models.Product) error
+	// Flush writes any buffered output
+	Flush() error
+}
+
+// NewEncoder returns an Encoder writing format to w. CSV output starts with a header row.
+func NewEncoder(w io.Writer, format string) Encoder {
+	if format == CSV {
+		return &csvEncoder{writer: csv.NewWriter(w)}
+	}
+	return &ndjsonEncoder{encoder: json.NewEncoder(w)}
+}
+
+type csvEncoder struct {
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+func (e *csvEncoder) Encode(product models.Product) error {
+	if !e.wroteHeader {
+		if err := e.writer.Write(columns); err != nil {
+			return err
+		}
+		e.wroteHeader = true
+	}
+
+	return e.writer.Write([]string{
+		product.ID,
+		product.Name,
+		product.Description,
+		strconv.FormatFloat(product.Price, 'f', -1, 64),
+		strconv.Itoa(product.InventoryCount),
+		product.CreatedAt.Format(time.RFC3339Nano),
+		product.UpdatedAt.Format(time.RFC3339Nano),
+		strconv.FormatInt(product.Version, 10),
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	// An empty export still gets its header row
+	if !e.wroteHeader {
+		if err := e.writer.Write(columns); err != nil {
+			return err
+		}
+		e.wroteHeader = true
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(product models.Product) error {
+	// json.Encoder terminates every value with a newline
+	return e.encoder.Encode(product)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return nil
+}
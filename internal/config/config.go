@@ -4,17 +4,83 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/yourusername/product-service/internal/tenancy"
 )
 
 // Config holds all configuration for the service
 type Config struct {
 	ServerPort   int
+	GRPCPort int
 	CosmosDBURI  string
 	DatabaseName string
 	ContainerName string
 	Environment  string
+	StorageBackend string
+	SeedSampleData bool
+	DataDir string
+	SnapshotInterval int
+	RequestTimeout time.Duration
+	RouteTimeouts map[string]time.Duration
+	ReservationReapInterval time.Duration
+	TrashRetention time.Duration
+	TrashPurgeInterval time.Duration
+	EventDispatchInterval time.Duration
+	EventBatchSize int
+	ChangeFeedBuffer int
+	WebhookMaxAttempts int
+	WebhookRetryBackoff time.Duration
+	WebhookTimeout time.Duration
+	GraphQLMaxComplexity int
+	GraphQLMaxDepth int
+	AuthEnabled bool
+	JWTSecret string
+	JWKSFile string
+	JWTIssuer string
+	JWTAudience string
+	Tenants []string
+	TenantDomain string
+	TenantMaxProducts int
+	TenantProductQuotas map[string]int
+	RateLimitRead int
+	RateLimitWrite int
+	RateLimitWindow time.Duration
+	IdempotencyKeyTTL time.Duration
+}
+
+// Default returns the configuration used when no environment variables are set
+func Default() *Config {
+	return &Config{
+		ServerPort:   8080,
+		GRPCPort: 9090,
+		Environment:  "development",
+		DatabaseName: "product-db",
+		ContainerName: "products",
+		StorageBackend: "memory",
+		DataDir: "data",
+		SnapshotInterval: 1000,
+		RequestTimeout: 30 * time.Second,
+		RouteTimeouts: map[string]time.Duration{},
+		ReservationReapInterval: 30 * time.Second,
+		TrashRetention: 30 * 24 * time.Hour,
+		TrashPurgeInterval: time.Hour,
+		EventDispatchInterval: time.Second,
+		EventBatchSize: 100,
+		ChangeFeedBuffer: 1000,
+		WebhookMaxAttempts: 6,
+		WebhookRetryBackoff: 10 * time.Second,
+		WebhookTimeout: 10 * time.Second,
+		GraphQLMaxComplexity: 5000,
+		GraphQLMaxDepth: 10,
+		TenantProductQuotas: map[string]int{},
+		RateLimitRead: 1200,
+		RateLimitWrite: 300,
+		RateLimitWindow: time.Minute,
+		IdempotencyKeyTTL: 24 * time.Hour,
+	}
 }
 
 // LoadConfig loads configuration from environment variables
@@ -23,12 +89,7 @@ func LoadConfig() (*Config, error) {
 	godotenv.Load()
 	
 	// Set default values
-	config := &Config{
-		ServerPort:   8080,
-		Environment:  "development",
-		DatabaseName: "product-db",
-		ContainerName: "products",
-	}
+	config := Default()
 	
 	// Override with environment variables if set
 	if port := os.Getenv("SERVER_PORT"); port != "" {
@@ -37,12 +98,16 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 	
+	if port := os.Getenv("GRPC_PORT"); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil || p <= 0 {
+			return nil, fmt.Errorf("invalid GRPC_PORT %q", port)
+		}
+		config.GRPCPort = p
+	}
+	
 	if uri := os.Getenv("COSMOS_DB_URI"); uri != "" {
 		config.CosmosDBURI = uri
-	} else {
-		// In a production environment, we would return an error here
-		// For development, we'll just log a warning
-		fmt.Println("Warning: COSMOS_DB_URI not set")
 	}
 	
 	if dbName := os.Getenv("COSMOS_DB_NAME"); dbName != "" {
@@ -57,5 +122,281 @@ func LoadConfig() (*Config, error) {
 		config.Environment = env
 	}
 	
+	if backend := os.Getenv("STORAGE_BACKEND"); backend != "" {
+		config.StorageBackend = strings.ToLower(backend)
+	}
+	
+	if seed := os.Getenv("SEED_SAMPLE_DATA"); seed != "" {
+		s, err := strconv.ParseBool(seed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SEED_SAMPLE_DATA %q: %w", seed, err)
+		}
+		config.SeedSampleData = s
+	}
+	
+	if dir := os.Getenv("DATA_DIR"); dir != "" {
+		config.DataDir = dir
+	}
+	
+	if interval := os.Getenv("SNAPSHOT_INTERVAL"); interval != "" {
+		i, err := strconv.Atoi(interval)
+		if err != nil || i <= 0 {
+			return nil, fmt.Errorf("invalid SNAPSHOT_INTERVAL %q", interval)
+		}
+		config.SnapshotInterval = i
+	}
+	
+	if timeout := os.Getenv("REQUEST_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid REQUEST_TIMEOUT %q", timeout)
+		}
+		config.RequestTimeout = d
+	}
+	
+	if routes := os.Getenv("ROUTE_TIMEOUTS"); routes != "" {
+		timeouts, err := parseRouteTimeouts(routes)
+		if err != nil {
+			return nil, err
+		}
+		config.RouteTimeouts = timeouts
+	}
+	
+	if interval := os.Getenv("RESERVATION_REAP_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid RESERVATION_REAP_INTERVAL %q", interval)
+		}
+		config.ReservationReapInterval = d
+	}
+	
+	if retention := os.Getenv("TRASH_RETENTION"); retention != "" {
+		d, err := time.ParseDuration(retention)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid TRASH_RETENTION %q", retention)
+		}
+		config.TrashRetention = d
+	}
+	
+	if interval := os.Getenv("TRASH_PURGE_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid TRASH_PURGE_INTERVAL %q", interval)
+		}
+		config.TrashPurgeInterval = d
+	}
+	
+	if interval := os.Getenv("EVENT_DISPATCH_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid EVENT_DISPATCH_INTERVAL %q", interval)
+		}
+		config.EventDispatchInterval = d
+	}
+	
+	if size := os.Getenv("EVENT_BATCH_SIZE"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid EVENT_BATCH_SIZE %q", size)
+		}
+		config.EventBatchSize = n
+	}
+	
+	if size := os.Getenv("CHANGE_FEED_BUFFER"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid CHANGE_FEED_BUFFER %q", size)
+		}
+		config.ChangeFeedBuffer = n
+	}
+	
+	if attempts := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); attempts != "" {
+		n, err := strconv.Atoi(attempts)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS %q", attempts)
+		}
+		config.WebhookMaxAttempts = n
+	}
+	
+	if backoff := os.Getenv("WEBHOOK_RETRY_BACKOFF"); backoff != "" {
+		d, err := time.ParseDuration(backoff)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid WEBHOOK_RETRY_BACKOFF %q", backoff)
+		}
+		config.WebhookRetryBackoff = d
+	}
+	
+	if timeout := os.Getenv("WEBHOOK_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid WEBHOOK_TIMEOUT %q", timeout)
+		}
+		config.WebhookTimeout = d
+	}
+	
+	if complexity := os.Getenv("GRAPHQL_MAX_COMPLEXITY"); complexity != "" {
+		n, err := strconv.Atoi(complexity)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid GRAPHQL_MAX_COMPLEXITY %q", complexity)
+		}
+		config.GraphQLMaxComplexity = n
+	}
+	
+	if depth := os.Getenv("GRAPHQL_MAX_DEPTH"); depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid GRAPHQL_MAX_DEPTH %q", depth)
+		}
+		config.GraphQLMaxDepth = n
+	}
+	
+	if enabled := os.Getenv("AUTH_ENABLED"); enabled != "" {
+		b, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_ENABLED %q: %w", enabled, err)
+		}
+		config.AuthEnabled = b
+	}
+	config.JWTSecret = os.Getenv("JWT_HS256_SECRET")
+	config.JWKSFile = os.Getenv("JWT_JWKS_FILE")
+	config.JWTIssuer = os.Getenv("JWT_ISSUER")
+	config.JWTAudience = os.Getenv("JWT_AUDIENCE")
+	
+	if config.AuthEnabled && config.JWTSecret == "" && config.JWKSFile == "" {
+		return nil, fmt.Errorf("JWT_HS256_SECRET or JWT_JWKS_FILE must be set when AUTH_ENABLED is true")
+	}
+	
+	if tenants := os.Getenv("TENANTS"); tenants != "" {
+		names, err := parseTenants(tenants)
+		if err != nil {
+			return nil, err
+		}
+		config.Tenants = names
+	}
+	config.TenantDomain = strings.ToLower(strings.Trim(os.Getenv("TENANT_DOMAIN"), "."))
+	
+	if limit := os.Getenv("TENANT_MAX_PRODUCTS"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid TENANT_MAX_PRODUCTS %q", limit)
+		}
+		config.TenantMaxProducts = n
+	}
+	
+	if quotas := os.Getenv("TENANT_PRODUCT_QUOTAS"); quotas != "" {
+		q, err := parseTenantQuotas(quotas, config.Tenants)
+		if err != nil {
+			return nil, err
+		}
+		config.TenantProductQuotas = q
+	}
+	
+	if limit := os.Getenv("RATE_LIMIT_READ"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_READ %q", limit)
+		}
+		config.RateLimitRead = n
+	}
+	
+	if limit := os.Getenv("RATE_LIMIT_WRITE"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_WRITE %q", limit)
+		}
+		config.RateLimitWrite = n
+	}
+	
+	if window := os.Getenv("RATE_LIMIT_WINDOW"); window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_WINDOW %q", window)
+		}
+		config.RateLimitWindow = d
+	}
+	
+	if ttl := os.Getenv("IDEMPOTENCY_KEY_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid IDEMPOTENCY_KEY_TTL %q", ttl)
+		}
+		config.IdempotencyKeyTTL = d
+	}
+	
+	if config.StorageBackend == "cosmos" && config.CosmosDBURI == "" {
+		return nil, fmt.Errorf("COSMOS_DB_URI must be set when STORAGE_BACKEND is cosmos")
+	}
+	
 	return config, nil
-}
\ No newline at end of file
+}
+
+// parseRouteTimeouts parses per-route timeouts such as
+// "GET /api/products=5s,POST /api/products/:id/inventory/adjustments=2s".
+// Routes use the same pattern syntax they are registered with.
+func parseRouteTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		route, duration, found := strings.Cut(strings.TrimSpace(entry), "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !found || !hasPath || method == "" || path == "" {
+			return nil, fmt.Errorf("invalid ROUTE_TIMEOUTS entry %q, expected \"METHOD /path=duration\"", entry)
+		}
+		
+		d, err := time.ParseDuration(strings.TrimSpace(duration))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout in ROUTE_TIMEOUTS entry %q", entry)
+		}
+		timeouts[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = d
+	}
+	
+	return timeouts, nil
+}
+
+// parseTenants parses a comma-separated list of tenant names such as
+// "brand-a,brand-b". Every name must be a lowercase DNS label.
+func parseTenants(value string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if !tenancy.ValidName(name) {
+			return nil, fmt.Errorf("invalid tenant %q in TENANTS, expected a lowercase DNS label", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("tenant %q is listed twice in TENANTS", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	
+	return names, nil
+}
+
+// parseTenantQuotas parses per-tenant product quotas such as
+// "brand-a=500,brand-b=1000". Every tenant must be one of tenants.
+func parseTenantQuotas(value string, tenants []string) (map[string]int, error) {
+	known := make(map[string]bool, len(tenants))
+	for _, tenant := range tenants {
+		known[tenant] = true
+	}
+	
+	quotas := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		tenant, quota, found := strings.Cut(strings.TrimSpace(entry), "=")
+		tenant = strings.TrimSpace(tenant)
+		if !found || tenant == "" {
+			return nil, fmt.Errorf("invalid TENANT_PRODUCT_QUOTAS entry %q, expected \"tenant=count\"", entry)
+		}
+		if !known[tenant] {
+			return nil, fmt.Errorf("TENANT_PRODUCT_QUOTAS names tenant %q, which is not in TENANTS", tenant)
+		}
+		
+		n, err := strconv.Atoi(strings.TrimSpace(quota))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid quota in TENANT_PRODUCT_QUOTAS entry %q", entry)
+		}
+		quotas[tenant] = n
+	}
+	
+	return quotas, nil
+}
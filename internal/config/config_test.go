@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -16,6 +17,13 @@ func TestLoadConfig(t *testing.T) {
 	os.Unsetenv("COSMOS_DB_NAME")
 	os.Unsetenv("COSMOS_CONTAINER_NAME")
 	os.Unsetenv("ENVIRONMENT")
+	os.Unsetenv("STORAGE_BACKEND")
+	os.Unsetenv("SEED_SAMPLE_DATA")
+	os.Unsetenv("REQUEST_TIMEOUT")
+	os.Unsetenv("ROUTE_TIMEOUTS")
+	os.Unsetenv("RESERVATION_REAP_INTERVAL")
+	os.Unsetenv("TRASH_RETENTION")
+	os.Unsetenv("TRASH_PURGE_INTERVAL")
 
 	// Test case 1: Default values
 	t.Run("DefaultValues", func(t *testing.T) {
@@ -26,6 +34,15 @@ func TestLoadConfig(t *testing.T) {
 		assert.Equal(t, "product-db", config.DatabaseName)
 		assert.Equal(t, "products", config.ContainerName)
 		assert.Equal(t, "", config.CosmosDBURI)
+		assert.Equal(t, "memory", config.StorageBackend)
+		assert.False(t, config.SeedSampleData)
+		assert.Equal(t, "data", config.DataDir)
+		assert.Equal(t, 1000, config.SnapshotInterval)
+		assert.Equal(t, 30*time.Second, config.RequestTimeout)
+		assert.Empty(t, config.RouteTimeouts)
+		assert.Equal(t, 30*time.Second, config.ReservationReapInterval)
+		assert.Equal(t, 30*24*time.Hour, config.TrashRetention)
+		assert.Equal(t, time.Hour, config.TrashPurgeInterval)
 	})
 
 	// Test case 2: Environment variables override defaults
@@ -54,4 +71,259 @@ func TestLoadConfig(t *testing.T) {
 		os.Unsetenv("COSMOS_CONTAINER_NAME")
 		os.Unsetenv("ENVIRONMENT")
 	})
+
+	// Test case 3: Storage backend selection
+	t.Run("WithStorageBackend", func(t *testing.T) {
+		os.Setenv("STORAGE_BACKEND", "Cosmos")
+		os.Setenv("COSMOS_DB_URI", "test_uri")
+		os.Setenv("SEED_SAMPLE_DATA", "true")
+		defer os.Unsetenv("STORAGE_BACKEND")
+		defer os.Unsetenv("COSMOS_DB_URI")
+		defer os.Unsetenv("SEED_SAMPLE_DATA")
+
+		config, err := LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, "cosmos", config.StorageBackend)
+		assert.True(t, config.SeedSampleData)
+	})
+
+	// Test case 4: Invalid storage settings fail loudly
+	t.Run("InvalidStorageSettings", func(t *testing.T) {
+		os.Setenv("STORAGE_BACKEND", "cosmos")
+		defer os.Unsetenv("STORAGE_BACKEND")
+
+		_, err := LoadConfig()
+		assert.ErrorContains(t, err, "COSMOS_DB_URI")
+
+		os.Setenv("STORAGE_BACKEND", "memory")
+		os.Setenv("SEED_SAMPLE_DATA", "sometimes")
+		defer os.Unsetenv("SEED_SAMPLE_DATA")
+
+		_, err = LoadConfig()
+		assert.Error(t, err)
+		os.Unsetenv("SEED_SAMPLE_DATA")
+
+		os.Setenv("SNAPSHOT_INTERVAL", "0")
+		defer os.Unsetenv("SNAPSHOT_INTERVAL")
+
+		_, err = LoadConfig()
+		assert.Error(t, err)
+	})
+
+	// Test case 5: Request timeouts
+	t.Run("WithRequestTimeouts", func(t *testing.T) {
+		os.Setenv("REQUEST_TIMEOUT", "10s")
+		os.Setenv("ROUTE_TIMEOUTS", "get /api/products=5s, POST /api/products/:id = 250ms")
+		defer os.Unsetenv("REQUEST_TIMEOUT")
+		defer os.Unsetenv("ROUTE_TIMEOUTS")
+
+		config, err := LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, 10*time.Second, config.RequestTimeout)
+		assert.Equal(t, map[string]time.Duration{
+			"GET /api/products":      5 * time.Second,
+			"POST /api/products/:id": 250 * time.Millisecond,
+		}, config.RouteTimeouts)
+
+		os.Setenv("RESERVATION_REAP_INTERVAL", "0s")
+		defer os.Unsetenv("RESERVATION_REAP_INTERVAL")
+		_, err = LoadConfig()
+		assert.Error(t, err)
+		os.Unsetenv("RESERVATION_REAP_INTERVAL")
+
+		for _, invalid := range []string{"/api/products=5s", "GET /api/products", "GET /api/products=soon", "GET /api/products=-1s"} {
+			os.Setenv("ROUTE_TIMEOUTS", invalid)
+			_, err = LoadConfig()
+			assert.Error(t, err, invalid)
+		}
+	})
+
+	// Test case 6: Trash retention
+	t.Run("WithTrashRetention", func(t *testing.T) {
+		os.Setenv("TRASH_RETENTION", "168h")
+		os.Setenv("TRASH_PURGE_INTERVAL", "10m")
+		defer os.Unsetenv("TRASH_RETENTION")
+		defer os.Unsetenv("TRASH_PURGE_INTERVAL")
+
+		config, err := LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, 7*24*time.Hour, config.TrashRetention)
+		assert.Equal(t, 10*time.Minute, config.TrashPurgeInterval)
+
+		os.Setenv("TRASH_RETENTION", "forever")
+		_, err = LoadConfig()
+		assert.Error(t, err)
+	})
+
+	// Test case 7: Event dispatch
+	t.Run("WithEventDispatch", func(t *testing.T) {
+		os.Setenv("EVENT_DISPATCH_INTERVAL", "250ms")
+		os.Setenv("EVENT_BATCH_SIZE", "20")
+		defer os.Unsetenv("EVENT_DISPATCH_INTERVAL")
+		defer os.Unsetenv("EVENT_BATCH_SIZE")
+
+		config, err := LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, 250*time.Millisecond, config.EventDispatchInterval)
+		assert.Equal(t, 20, config.EventBatchSize)
+
+		os.Setenv("EVENT_BATCH_SIZE", "0")
+		_, err = LoadConfig()
+		assert.Error(t, err)
+		os.Unsetenv("EVENT_BATCH_SIZE")
+
+		os.Setenv("CHANGE_FEED_BUFFER", "5000")
+		defer os.Unsetenv("CHANGE_FEED_BUFFER")
+		config, err = LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, 5000, config.ChangeFeedBuffer)
+	})
+
+	// Test case 8: Webhook delivery
+	t.Run("WithWebhooks", func(t *testing.T) {
+		os.Setenv("WEBHOOK_MAX_ATTEMPTS", "3")
+		os.Setenv("WEBHOOK_RETRY_BACKOFF", "1s")
+		os.Setenv("WEBHOOK_TIMEOUT", "2s")
+		defer os.Unsetenv("WEBHOOK_MAX_ATTEMPTS")
+		defer os.Unsetenv("WEBHOOK_RETRY_BACKOFF")
+		defer os.Unsetenv("WEBHOOK_TIMEOUT")
+
+		config, err := LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, 3, config.WebhookMaxAttempts)
+		assert.Equal(t, time.Second, config.WebhookRetryBackoff)
+		assert.Equal(t, 2*time.Second, config.WebhookTimeout)
+
+		os.Setenv("WEBHOOK_MAX_ATTEMPTS", "none")
+		_, err = LoadConfig()
+		assert.Error(t, err)
+	})
+
+	// Test case 9: gRPC port
+	t.Run("WithGRPCPort", func(t *testing.T) {
+		os.Setenv("GRPC_PORT", "50051")
+		defer os.Unsetenv("GRPC_PORT")
+
+		config, err := LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, 50051, config.GRPCPort)
+
+		os.Setenv("GRPC_PORT", "grpc")
+		_, err = LoadConfig()
+		assert.Error(t, err)
+	})
+
+	// Test case 10: GraphQL limits, where 0 disables a limit
+	t.Run("WithGraphQLLimits", func(t *testing.T) {
+		os.Setenv("GRAPHQL_MAX_COMPLEXITY", "0")
+		os.Setenv("GRAPHQL_MAX_DEPTH", "6")
+		defer os.Unsetenv("GRAPHQL_MAX_COMPLEXITY")
+		defer os.Unsetenv("GRAPHQL_MAX_DEPTH")
+
+		config, err := LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, config.GraphQLMaxComplexity)
+		assert.Equal(t, 6, config.GraphQLMaxDepth)
+
+		os.Setenv("GRAPHQL_MAX_DEPTH", "-1")
+		_, err = LoadConfig()
+		assert.Error(t, err)
+	})
+
+	// Test case 11: authentication needs a key to verify tokens with
+	t.Run("WithAuth", func(t *testing.T) {
+		os.Setenv("AUTH_ENABLED", "true")
+		defer os.Unsetenv("AUTH_ENABLED")
+
+		_, err := LoadConfig()
+		assert.Error(t, err)
+
+		os.Setenv("JWT_JWKS_FILE", "/etc/product-service/jwks.json")
+		os.Setenv("JWT_AUDIENCE", "product-service")
+		defer os.Unsetenv("JWT_JWKS_FILE")
+		defer os.Unsetenv("JWT_AUDIENCE")
+
+		config, err := LoadConfig()
+		assert.NoError(t, err)
+		assert.True(t, config.AuthEnabled)
+		assert.Equal(t, "/etc/product-service/jwks.json", config.JWKSFile)
+		assert.Equal(t, "product-service", config.JWTAudience)
+	})
+
+	// Test case 12: tenants and their product quotas
+	t.Run("WithTenants", func(t *testing.T) {
+		os.Setenv("TENANTS", "brand-a, brand-b")
+		os.Setenv("TENANT_DOMAIN", "Catalog.Example.com.")
+		os.Setenv("TENANT_MAX_PRODUCTS", "100")
+		os.Setenv("TENANT_PRODUCT_QUOTAS", "brand-b=500")
+		defer os.Unsetenv("TENANTS")
+		defer os.Unsetenv("TENANT_DOMAIN")
+		defer os.Unsetenv("TENANT_MAX_PRODUCTS")
+		defer os.Unsetenv("TENANT_PRODUCT_QUOTAS")
+
+		config, err := LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"brand-a", "brand-b"}, config.Tenants)
+		assert.Equal(t, "catalog.example.com", config.TenantDomain)
+		assert.Equal(t, 100, config.TenantMaxProducts)
+		assert.Equal(t, map[string]int{"brand-b": 500}, config.TenantProductQuotas)
+
+		os.Setenv("TENANT_PRODUCT_QUOTAS", "brand-c=500")
+		_, err = LoadConfig()
+		assert.Error(t, err)
+		os.Setenv("TENANT_PRODUCT_QUOTAS", "brand-b=-1")
+		_, err = LoadConfig()
+		assert.Error(t, err)
+		os.Unsetenv("TENANT_PRODUCT_QUOTAS")
+
+		for _, invalid := range []string{"Brand-A", "brand_a", "brand-a,brand-a", "brand-a,,brand-b"} {
+			os.Setenv("TENANTS", invalid)
+			_, err = LoadConfig()
+			assert.Error(t, err, invalid)
+		}
+	})
+
+	// Test case 13: rate limits, where 0 disables a limit
+	t.Run("WithRateLimits", func(t *testing.T) {
+		config, err := LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, 1200, config.RateLimitRead)
+		assert.Equal(t, 300, config.RateLimitWrite)
+		assert.Equal(t, time.Minute, config.RateLimitWindow)
+
+		os.Setenv("RATE_LIMIT_READ", "0")
+		os.Setenv("RATE_LIMIT_WRITE", "50")
+		os.Setenv("RATE_LIMIT_WINDOW", "10s")
+		defer os.Unsetenv("RATE_LIMIT_READ")
+		defer os.Unsetenv("RATE_LIMIT_WRITE")
+		defer os.Unsetenv("RATE_LIMIT_WINDOW")
+
+		config, err = LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, config.RateLimitRead)
+		assert.Equal(t, 50, config.RateLimitWrite)
+		assert.Equal(t, 10*time.Second, config.RateLimitWindow)
+
+		os.Setenv("RATE_LIMIT_WINDOW", "0s")
+		_, err = LoadConfig()
+		assert.Error(t, err)
+	})
+
+	// Test case 14: how long idempotency keys are kept
+	t.Run("WithIdempotencyKeyTTL", func(t *testing.T) {
+		config, err := LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, 24*time.Hour, config.IdempotencyKeyTTL)
+
+		os.Setenv("IDEMPOTENCY_KEY_TTL", "1h")
+		defer os.Unsetenv("IDEMPOTENCY_KEY_TTL")
+
+		config, err = LoadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, time.Hour, config.IdempotencyKeyTTL)
+
+		os.Setenv("IDEMPOTENCY_KEY_TTL", "forever")
+		_, err = LoadConfig()
+		assert.Error(t, err)
+	})
 }
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// Bulk operation kinds
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+	// BulkUpsert updates the product if its ID exists and creates it otherwise
+	BulkUpsert = "upsert"
+)
+
+// BulkOperation is one write in a BulkWrite. Creates and updates carry the
+// full product; a delete, which moves the product to the trash, only uses
+// Product.ID. A non-zero Product.Version makes an update or delete
+// conditional, as with UpdateProduct.
+type BulkOperation struct {
+	Op      string
+	Product models.Product
+}
+
+// BulkResult is the outcome of one BulkOperation: the stored product after a
+// create or update, or the error that operation failed with
+type BulkResult struct {
+	Product models.Product
+	Err     error
+}
+
+// stageBulkOperation computes the product an operation stores given the
+// current state of its target, which may be in the trash. A delete stores the
+// tombstoned product.
+func stageBulkOperation(op BulkOperation, existing models.Product, exists bool, now time.Time) (models.Product, error) {
+	product := op.Product
+	product.DeletedAt = nil
+	if exists && isDeleted(existing) {
+		// A trashed product still holds its ID
+		if op.Op == BulkCreate || op.Op == BulkUpsert {
+			return models.Product{}, ErrConflict
+		}
+		exists = false
+	}
+	if op.Op == BulkUpsert {
+		op.Op = BulkCreate
+		if exists {
+			op.Op = BulkUpdate
+		}
+	}
+
+	switch op.Op {
+	case BulkCreate:
+		if product.ID == "" {
+			product.ID = uuid.New().String()
+		} else if exists {
+			return models.Product{}, ErrConflict
+		}
+		product.CreatedAt = now
+		product.UpdatedAt = now
+		product.Version = 1
+		return product, nil
+	case BulkUpdate, BulkDelete:
+		if !exists {
+			return models.Product{}, ErrNotFound
+		}
+		if product.Version != 0 && product.Version != existing.Version {
+			return models.Product{}, ErrPreconditionFailed
+		}
+		if op.Op == BulkDelete {
+			return tombstone(existing, now), nil
+		}
+		product.Version = existing.Version + 1
+		product.CreatedAt = existing.CreatedAt
+		product.UpdatedAt = now
+		return product, nil
+	default:
+		return models.Product{}, ErrNotSupported
+	}
+}
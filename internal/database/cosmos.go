@@ -1,45 +1,1227 @@
 package database
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 	"github.com/yourusername/product-service/internal/models"
 )
 
-// CosmosDBRepository is a placeholder for a Cosmos DB implementation
-// Note: This is just a placeholder and doesn't actually connect to Cosmos DB
-// For local testing, use the InMemoryRepository instead
-type CosmosDBRepository struct{}
+const (
+	// cosmosAPIVersion is the REST API version sent in the x-ms-version header
+	cosmosAPIVersion = "2018-12-31"
+
+	// cosmosMaxRetries is how many times a throttled (429) request is retried
+	cosmosMaxRetries = 9
+
+	// cosmosDefaultRetryAfter is used when a 429 response carries no retry hint
+	cosmosDefaultRetryAfter = 100 * time.Millisecond
+
+	// cosmosMaxQueryScan caps the documents QueryProducts reads to cut out one
+	// page, since every page is sorted in process
+	cosmosMaxQueryScan = 10000
+)
+
+// CosmosDBRepository implements ProductRepository on top of the Cosmos DB SQL REST API.
+// Products are stored as documents partitioned by their ID ("/id").
+type CosmosDBRepository struct {
+	client        *http.Client
+	endpoint      *url.URL
+	key           []byte
+	databaseName  string
+	containerName string
+	maxRetries    int
+	pageSize      int
+	maxQueryScan  int
+}
+
+// cosmosDocument is the stored form of a product. The timestamps are duplicated as
+// Unix microseconds because RFC 3339 strings do not sort chronologically when
+// their fractional seconds differ in length.
+//
+// Held reservations live in the product's own document so that reserving and
+// confirming are a single ETag-guarded write. ReservationExpiryTs is the
+// earliest expiry among them and lets the reaper find lapsed holds by query.
+//
+// The product's revision history is kept in the document too, so it is
+// written together with each change. A deleted product stays in its document
+// with DeletedTs set until it is purged, which removes the history with it.
+//
+// Domain events raised by a change wait in Outbox until they are delivered,
+// written by the same replace as the change. OutboxTs is the time of the
+// oldest of them and lets the dispatcher find pending events by query.
+type cosmosDocument struct {
+	models.Product
+	CreatedTs           int64                         `json:"createdTs"`
+	UpdatedTs           int64                         `json:"updatedTs"`
+	DeletedTs           int64                         `json:"deletedTs,omitempty"`
+	Reservations        map[string]models.Reservation `json:"reservations,omitempty"`
+	ReservationExpiryTs int64                         `json:"reservationExpiryTs,omitempty"`
+	History             []models.Revision             `json:"history,omitempty"`
+	Outbox              []models.Event                `json:"outbox,omitempty"`
+	OutboxTs            int64                         `json:"outboxTs,omitempty"`
+	ETag                string                        `json:"_etag,omitempty"`
+
+	// restoredFrom tells modify that the change restores this revision
+	restoredFrom int64
+}
+
+// cosmosFields maps sortable product fields to document properties
+var cosmosFields = map[string]string{
+	"id":             "c.id",
+	"name":           "c.name",
+	"price":          "c.price",
+	"inventoryCount": "c.inventoryCount",
+	"createdAt":      "c.createdTs",
+	"updatedAt":      "c.updatedTs",
+}
+
+// newCosmosDocument wraps a product in its stored form
+func newCosmosDocument(product models.Product) cosmosDocument {
+	doc := cosmosDocument{
+		Product:   product,
+		CreatedTs: product.CreatedAt.UnixMicro(),
+		UpdatedTs: product.UpdatedAt.UnixMicro(),
+	}
+	if isDeleted(product) {
+		doc.DeletedTs = product.DeletedAt.UnixMicro()
+	}
+	return doc
+}
+
+// stored recomputes the derived properties of a document that is about to be written
+func (doc cosmosDocument) stored() cosmosDocument {
+	next := newCosmosDocument(doc.Product)
+	next.History = doc.History
+	if len(doc.Outbox) > 0 {
+		next.Outbox = doc.Outbox
+		for _, event := range doc.Outbox {
+			occurred := event.OccurredAt.UnixMicro()
+			if next.OutboxTs == 0 || occurred < next.OutboxTs {
+				next.OutboxTs = occurred
+			}
+		}
+	}
+	if len(doc.Reservations) > 0 {
+		next.Reservations = doc.Reservations
+		for _, reservation := range doc.Reservations {
+			expiry := reservation.ExpiresAt.UnixMicro()
+			if next.ReservationExpiryTs == 0 || expiry < next.ReservationExpiryTs {
+				next.ReservationExpiryTs = expiry
+			}
+		}
+	}
+	return next
+}
+
+// cosmosRequest describes a single call to the Cosmos DB REST API
+type cosmosRequest struct {
+	method       string
+	path         string // path relative to the account endpoint
+	resourceLink string // resource link signed in the authorization header
+	partitionKey string
+	headers      map[string]string
+	body         []byte
+}
+
+// cosmosResponse holds the parts of a Cosmos DB response the repository cares about
+type cosmosResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// cosmosQuery is the body of a SQL query request
+type cosmosQuery struct {
+	Query      string             `json:"query"`
+	Parameters []cosmosQueryParam `json:"parameters"`
+}
+
+// cosmosQueryParam is a named parameter of a SQL query
+type cosmosQueryParam struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
 
-// NewCosmosDBRepository creates a new repository connected to Cosmos DB
-// Note: This is just a placeholder and will return an error
+// NewCosmosDBRepository creates a new repository connected to Cosmos DB.
+// The connection string uses the format shown in the Azure portal:
+// "AccountEndpoint=https://<account>.documents.azure.com:443/;AccountKey=<key>;"
 func NewCosmosDBRepository(connectionString, databaseName, containerName string) (*CosmosDBRepository, error) {
-	return nil, errors.New("Cosmos DB repository is not available in this build - use NewInMemoryRepository() instead")
+	endpoint, key, err := parseCosmosConnectionString(connectionString)
+	if err != nil {
+		return nil, err
+	}
+	if databaseName == "" || containerName == "" {
+		return nil, errors.New("cosmos: database and container names are required")
+	}
+
+	return &CosmosDBRepository{
+		client:        &http.Client{Timeout: 30 * time.Second},
+		endpoint:      endpoint,
+		key:           key,
+		databaseName:  databaseName,
+		containerName: containerName,
+		maxRetries:    cosmosMaxRetries,
+		pageSize:      100,
+		maxQueryScan:  cosmosMaxQueryScan,
+	}, nil
 }
 
-// The following methods are placeholders and will all return errors
-// They are included to satisfy the ProductRepository interface
+// parseCosmosConnectionString extracts the account endpoint and decoded master key
+func parseCosmosConnectionString(connectionString string) (*url.URL, []byte, error) {
+	var rawEndpoint, rawKey string
+	for _, part := range strings.Split(connectionString, ";") {
+		name, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch strings.ToLower(name) {
+		case "accountendpoint":
+			rawEndpoint = value
+		case "accountkey":
+			rawKey = value
+		}
+	}
+
+	if rawEndpoint == "" || rawKey == "" {
+		return nil, nil, errors.New("cosmos: connection string must contain AccountEndpoint and AccountKey")
+	}
+
+	endpoint, err := url.Parse(rawEndpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, nil, fmt.Errorf("cosmos: invalid AccountEndpoint %q", rawEndpoint)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(rawKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cosmos: invalid AccountKey: %w", err)
+	}
 
+	return endpoint, key, nil
+}
+
+// GetProducts retrieves all products in creation order. Documents come back in
+// no particular order and are sorted in process.
 func (r *CosmosDBRepository) GetProducts(ctx context.Context) ([]models.Product, error) {
-	return nil, errors.New("not implemented")
+	query := ProductQuery{}.normalize()
+
+	products, err := r.queryAll(ctx, buildCosmosQuery(query, nil), 0)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return compareProducts(products[i], products[j], query.Sort) < 0
+	})
+	return products, nil
+}
+
+// CountProducts counts the products that are not in the trash. Aggregates
+// across partitions need a query plan the REST API does not provide, so it
+// pages through their IDs instead.
+func (r *CosmosDBRepository) CountProducts(ctx context.Context) (int, error) {
+	query := cosmosQuery{Query: "SELECT c.id FROM c WHERE NOT IS_DEFINED(c.deletedTs)", Parameters: []cosmosQueryParam{}}
+
+	count := 0
+	continuation := ""
+	for {
+		docs, next, err := r.queryDocuments(ctx, query, continuation, r.pageSize)
+		if err != nil {
+			return 0, err
+		}
+		count += len(docs)
+		if next == "" {
+			return count, nil
+		}
+		continuation = next
+	}
+}
+
+// QueryProducts pushes the filter down to Cosmos DB SQL but sorts and pages in
+// process. Products are partitioned by ID, and the gateway cannot serve an
+// ORDER BY or TOP across partitions without the query plan the SDKs compute,
+// so cursors are keyset cursors as for the in-memory repository. A cursor also
+// bounds the first sort field in the query, so later pages read less.
+//
+// Every page still reads all the matching products from the cursor on, so
+// paging through n products costs O(n²) reads. A query is refused with
+// ErrNotSupported once it would read more than maxQueryScan of them.
+func (r *CosmosDBRepository) QueryProducts(ctx context.Context, query ProductQuery) (ProductPage, error) {
+	query = query.normalize()
+
+	after, err := cursorAfter(query)
+	if err != nil {
+		return ProductPage{}, err
+	}
+
+	products, err := r.queryAll(ctx, buildCosmosQuery(query, after), r.maxQueryScan)
+	if err != nil {
+		return ProductPage{}, err
+	}
+	return keysetPage(products, query, after), nil
 }
 
+// buildCosmosQuery translates a normalized ProductQuery into parameterised SQL.
+// The query has no ORDER BY; when after is set it only excludes documents that
+// sort before after on the first sort field.
+func buildCosmosQuery(query ProductQuery, after *models.Product) cosmosQuery {
+	var conditions []string
+	params := []cosmosQueryParam{}
+	where := func(condition, name string, value interface{}) {
+		conditions = append(conditions, condition)
+		params = append(params, cosmosQueryParam{Name: name, Value: value})
+	}
+
+	filter := query.Filter
+	if filter.Deleted {
+		conditions = append(conditions, "IS_DEFINED(c.deletedTs)")
+	} else {
+		conditions = append(conditions, "NOT IS_DEFINED(c.deletedTs)")
+	}
+	if filter.MinPrice != nil {
+		where("c.price >= @minPrice", "@minPrice", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		where("c.price <= @maxPrice", "@maxPrice", *filter.MaxPrice)
+	}
+	if filter.InStock != nil {
+		if *filter.InStock {
+			conditions = append(conditions, "c.inventoryCount > 0")
+		} else {
+			conditions = append(conditions, "c.inventoryCount <= 0")
+		}
+	}
+	if filter.NamePrefix != "" {
+		where("STARTSWITH(c.name, @namePrefix)", "@namePrefix", filter.NamePrefix)
+	}
+	if filter.CreatedAfter != nil {
+		where("c.createdTs >= @createdAfter", "@createdAfter", filter.CreatedAfter.UnixMicro())
+	}
+	if filter.CreatedBefore != nil {
+		where("c.createdTs < @createdBefore", "@createdBefore", filter.CreatedBefore.UnixMicro())
+	}
+	if filter.UpdatedAfter != nil {
+		where("c.updatedTs >= @updatedAfter", "@updatedAfter", filter.UpdatedAfter.UnixMicro())
+	}
+	if filter.UpdatedBefore != nil {
+		where("c.updatedTs < @updatedBefore", "@updatedBefore", filter.UpdatedBefore.UnixMicro())
+	}
+	if after != nil {
+		first := query.Sort[0]
+		operator := ">="
+		if first.Descending {
+			operator = "<="
+		}
+		where(cosmosFields[first.Field]+" "+operator+" @after", "@after", cosmosSortValue(*after, first.Field))
+	}
+
+	sql := "SELECT * FROM c"
+	if len(conditions) > 0 {
+		sql += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	return cosmosQuery{Query: sql, Parameters: params}
+}
+
+// cosmosSortValue is the value of the document property that field sorts by
+func cosmosSortValue(product models.Product, field string) interface{} {
+	switch field {
+	case "name":
+		return product.Name
+	case "price":
+		return product.Price
+	case "inventoryCount":
+		return product.InventoryCount
+	case "createdAt":
+		return product.CreatedAt.UnixMicro()
+	case "updatedAt":
+		return product.UpdatedAt.UnixMicro()
+	}
+	return product.ID
+}
+
+// GetProductByID retrieves a product by its ID
 func (r *CosmosDBRepository) GetProductByID(ctx context.Context, id string) (models.Product, error) {
-	return models.Product{}, errors.New("not implemented")
+	doc, err := r.getLiveDocument(ctx, id)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	return doc.Product, nil
+}
+
+// getLiveDocument reads a product document, reporting products in the trash as not found
+func (r *CosmosDBRepository) getLiveDocument(ctx context.Context, id string) (cosmosDocument, error) {
+	doc, err := r.getDocument(ctx, id)
+	if err != nil {
+		return cosmosDocument{}, err
+	}
+	if isDeleted(doc.Product) {
+		return cosmosDocument{}, ErrNotFound
+	}
+
+	return doc, nil
 }
 
+// getDocument reads a product document including its _etag
+func (r *CosmosDBRepository) getDocument(ctx context.Context, id string) (cosmosDocument, error) {
+	resp, err := r.do(ctx, r.documentRequest(http.MethodGet, id, nil))
+	if err != nil {
+		return cosmosDocument{}, err
+	}
+
+	switch resp.status {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return cosmosDocument{}, ErrNotFound
+	default:
+		return cosmosDocument{}, cosmosError(resp)
+	}
+
+	var doc cosmosDocument
+	if err := json.Unmarshal(resp.body, &doc); err != nil {
+		return cosmosDocument{}, fmt.Errorf("cosmos: decoding product: %w", err)
+	}
+
+	return doc, nil
+}
+
+// CreateProduct creates a new product document
 func (r *CosmosDBRepository) CreateProduct(ctx context.Context, product models.Product) (models.Product, error) {
-	return models.Product{}, errors.New("not implemented")
+	// Generate a UUID if not provided
+	if product.ID == "" {
+		product.ID = uuid.New().String()
+	}
+
+	// Set timestamps and the initial version
+	now := time.Now().UTC()
+	product.CreatedAt = now
+	product.UpdatedAt = now
+	product.Version = 1
+	product.DeletedAt = nil
+
+	// A product in the trash keeps its document, so its ID still conflicts
+	revision := newRevision(nil, nil, product, actorFrom(ctx), 0)
+	doc := cosmosDocument{
+		Product: product,
+		History: []models.Revision{revision},
+		Outbox:  productEvents(nil, revision),
+	}
+	body, err := json.Marshal(doc.stored())
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	resp, err := r.do(ctx, cosmosRequest{
+		method:       http.MethodPost,
+		path:         r.collectionLink() + "/docs",
+		resourceLink: r.collectionLink(),
+		partitionKey: product.ID,
+		body:         body,
+	})
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	switch resp.status {
+	case http.StatusCreated, http.StatusOK:
+		return product, nil
+	case http.StatusConflict:
+		return models.Product{}, ErrConflict
+	default:
+		return models.Product{}, cosmosError(resp)
+	}
 }
 
-func (r *CosmosDBRepository) UpdateProduct(ctx context.Context, product models.Product) error {
-	return errors.New("not implemented")
+// UpdateProduct replaces an existing product document and returns the stored result.
+// When product.Version is non-zero it must match the stored version, otherwise
+// ErrPreconditionFailed is returned.
+func (r *CosmosDBRepository) UpdateProduct(ctx context.Context, product models.Product) (models.Product, error) {
+	doc, err := r.modify(ctx, product.ID, func(doc *cosmosDocument) error {
+		if product.Version != 0 && product.Version != doc.Version {
+			return ErrPreconditionFailed
+		}
+
+		next := product
+		next.DeletedAt = nil
+		next.Version = doc.Version + 1
+		next.CreatedAt = doc.CreatedAt
+		next.UpdatedAt = time.Now().UTC()
+		if err := checkHeld(next, heldQuantity(doc.Reservations, next.UpdatedAt)); err != nil {
+			return err
+		}
+		doc.Product = next
+		return nil
+	})
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	return doc.Product, nil
 }
 
-func (r *CosmosDBRepository) DeleteProduct(ctx context.Context, id string) error {
-	return errors.New("not implemented")
+// PatchProduct replaces a product with the result of calling patch on its
+// current state. The write is guarded by the document's ETag and patch is
+// re-run on a fresh copy if another writer got in first. When version is
+// non-zero it must match the stored version.
+func (r *CosmosDBRepository) PatchProduct(ctx context.Context, id string, version int64, patch func(models.Product) (models.Product, error)) (models.Product, error) {
+	doc, err := r.modify(ctx, id, func(doc *cosmosDocument) error {
+		if version != 0 && version != doc.Version {
+			return ErrPreconditionFailed
+		}
+
+		now := time.Now().UTC()
+		product, err := patchProduct(doc.Product, patch, now)
+		if err != nil {
+			return err
+		}
+		if err := checkHeld(product, heldQuantity(doc.Reservations, now)); err != nil {
+			return err
+		}
+		doc.Product = product
+		return nil
+	})
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	return doc.Product, nil
 }
 
+// DeleteProduct moves a product to the trash and releases its held reservations.
+// When version is non-zero it must match the stored version.
+func (r *CosmosDBRepository) DeleteProduct(ctx context.Context, id string, version int64) error {
+	_, err := r.modify(ctx, id, func(doc *cosmosDocument) error {
+		if version != 0 && version != doc.Version {
+			return ErrPreconditionFailed
+		}
+
+		doc.Product = tombstone(doc.Product, time.Now().UTC())
+		doc.Reservations = nil
+		return nil
+	})
+	return err
+}
+
+// UndeleteProduct takes a product back out of the trash. When version is
+// non-zero it must match the version of the trashed product.
+func (r *CosmosDBRepository) UndeleteProduct(ctx context.Context, id string, version int64) (models.Product, error) {
+	doc, err := r.modifyDocument(ctx, id, func(doc *cosmosDocument) error {
+		if !isDeleted(doc.Product) {
+			return ErrNotFound
+		}
+		if version != 0 && version != doc.Version {
+			return ErrPreconditionFailed
+		}
+
+		doc.Product = untombstone(doc.Product, time.Now().UTC())
+		return nil
+	})
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	return doc.Product, nil
+}
+
+// PurgeDeletedProducts permanently removes the documents of products that
+// went into the trash at or before before, and returns how many were removed.
+// A document with undelivered events is kept until they have been delivered.
+func (r *CosmosDBRepository) PurgeDeletedProducts(ctx context.Context, before time.Time) (int, error) {
+	query := cosmosQuery{
+		Query:      "SELECT * FROM c WHERE c.deletedTs <= @before",
+		Parameters: []cosmosQueryParam{{Name: "@before", Value: before.UnixMicro()}},
+	}
+
+	var ids []string
+	continuation := ""
+	for {
+		page, next, err := r.queryPage(ctx, query, continuation, r.pageSize)
+		if err != nil {
+			return 0, err
+		}
+		for _, product := range page {
+			ids = append(ids, product.ID)
+		}
+		if next == "" {
+			break
+		}
+		continuation = next
+	}
+
+	purged := 0
+	for _, id := range ids {
+		removed, err := r.purge(ctx, id, before)
+		if err != nil {
+			return purged, err
+		}
+		if removed {
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// purge deletes a product document if it is still purgeable, guarding the
+// delete with the _etag so a concurrent restore wins
+func (r *CosmosDBRepository) purge(ctx context.Context, id string, before time.Time) (bool, error) {
+	for {
+		doc, err := r.getDocument(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if !purgeable(doc.Product, before) || len(doc.Outbox) > 0 {
+			// Keep the document until its last events have been delivered
+			return false, nil
+		}
+
+		req := r.documentRequest(http.MethodDelete, id, nil)
+		req.headers = map[string]string{"If-Match": doc.ETag}
+		resp, err := r.do(ctx, req)
+		if err != nil {
+			return false, err
+		}
+
+		switch resp.status {
+		case http.StatusNoContent, http.StatusOK:
+			return true, nil
+		case http.StatusNotFound:
+			return false, nil
+		case http.StatusPreconditionFailed:
+			// Another writer changed the document since we read it; check again
+			continue
+		default:
+			return false, cosmosError(resp)
+		}
+	}
+}
+
+// BulkWrite applies ops one after another. Cosmos DB transactions cannot span
+// partitions and every product is its own partition, so atomic batches are
+// refused with ErrNotSupported.
+func (r *CosmosDBRepository) BulkWrite(ctx context.Context, ops []BulkOperation, atomic bool) ([]BulkResult, error) {
+	if atomic {
+		return nil, fmt.Errorf("%w: atomic batches span partitions", ErrNotSupported)
+	}
+
+	results := make([]BulkResult, len(ops))
+	for i, op := range ops {
+		switch op.Op {
+		case BulkCreate:
+			results[i].Product, results[i].Err = r.CreateProduct(ctx, op.Product)
+		case BulkUpdate:
+			results[i].Product, results[i].Err = r.UpdateProduct(ctx, op.Product)
+		case BulkDelete:
+			results[i].Err = r.DeleteProduct(ctx, op.Product.ID, op.Product.Version)
+		case BulkUpsert:
+			results[i].Product, results[i].Err = r.UpdateProduct(ctx, op.Product)
+			if errors.Is(results[i].Err, ErrNotFound) {
+				results[i].Product, results[i].Err = r.CreateProduct(ctx, op.Product)
+			}
+		default:
+			results[i].Err = ErrNotSupported
+		}
+	}
+
+	return results, nil
+}
+
+// modify is modifyDocument for products that are not in the trash
+func (r *CosmosDBRepository) modify(ctx context.Context, id string, fn func(doc *cosmosDocument) error) (cosmosDocument, error) {
+	return r.modifyDocument(ctx, id, func(doc *cosmosDocument) error {
+		if isDeleted(doc.Product) {
+			return ErrNotFound
+		}
+		return fn(doc)
+	})
+}
+
+// modifyDocument performs an optimistic read-modify-write of one document. fn may
+// change the document or return an error to abort; the replace is guarded by the
+// _etag that was read, and fn is re-run on a fresh copy whenever another writer
+// wins. A change that bumps the product's version is recorded in its history
+// and raises its domain events in the outbox.
+func (r *CosmosDBRepository) modifyDocument(ctx context.Context, id string, fn func(doc *cosmosDocument) error) (cosmosDocument, error) {
+	for {
+		doc, err := r.getDocument(ctx, id)
+		if err != nil {
+			return cosmosDocument{}, err
+		}
+
+		etag := doc.ETag
+		previous := doc.Product
+		if err := fn(&doc); err != nil {
+			return cosmosDocument{}, err
+		}
+		if doc.Version != previous.Version {
+			revision := newRevision(doc.History, &previous, doc.Product, actorFrom(ctx), doc.restoredFrom)
+			doc.History = append(doc.History, revision)
+			doc.Outbox = append(doc.Outbox, productEvents(&previous, revision)...)
+		}
+
+		stored := doc.stored()
+		body, err := json.Marshal(stored)
+		if err != nil {
+			return cosmosDocument{}, err
+		}
+
+		req := r.documentRequest(http.MethodPut, id, body)
+		req.headers = map[string]string{"If-Match": etag}
+		resp, err := r.do(ctx, req)
+		if err != nil {
+			return cosmosDocument{}, err
+		}
+
+		switch resp.status {
+		case http.StatusOK:
+			return stored, nil
+		case http.StatusNotFound:
+			return cosmosDocument{}, ErrNotFound
+		case http.StatusPreconditionFailed:
+			continue
+		default:
+			return cosmosDocument{}, cosmosError(resp)
+		}
+	}
+}
+
+// CheckProductAvailability checks if a product is available
 func (r *CosmosDBRepository) CheckProductAvailability(ctx context.Context, id string) (models.ProductAvailability, error) {
-	return models.ProductAvailability{}, errors.New("not implemented")
+	doc, err := r.getLiveDocument(ctx, id)
+	if err != nil {
+		return models.ProductAvailability{}, err
+	}
+
+	return availabilityOf(doc.Product, heldQuantity(doc.Reservations, time.Now())), nil
+}
+
+// CheckProductsAvailability reads several products with one query. Products
+// that do not exist or are in the trash are left out of the result.
+func (r *CosmosDBRepository) CheckProductsAvailability(ctx context.Context, ids []string) (map[string]models.ProductAvailability, error) {
+	availability := make(map[string]models.ProductAvailability, len(ids))
+	if len(ids) == 0 {
+		return availability, nil
+	}
+
+	query := cosmosQuery{
+		Query:      "SELECT * FROM c WHERE ARRAY_CONTAINS(@ids, c.id) AND NOT IS_DEFINED(c.deletedTs)",
+		Parameters: []cosmosQueryParam{{Name: "@ids", Value: ids}},
+	}
+
+	now := time.Now()
+	continuation := ""
+	for {
+		docs, next, err := r.queryDocuments(ctx, query, continuation, r.pageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			availability[doc.ID] = availabilityOf(doc.Product, heldQuantity(doc.Reservations, now))
+		}
+		if next == "" {
+			return availability, nil
+		}
+		continuation = next
+	}
+}
+
+// AdjustInventory applies a signed delta to a product's inventory count. The
+// read-modify-write is retried on ETag conflicts, so concurrent adjustments
+// never lose updates.
+func (r *CosmosDBRepository) AdjustInventory(ctx context.Context, id string, adjustment models.InventoryAdjustment) (models.ProductAvailability, error) {
+	var reserved int
+	doc, err := r.modify(ctx, id, func(doc *cosmosDocument) error {
+		now := time.Now().UTC()
+		reserved = heldQuantity(doc.Reservations, now)
+		if err := adjustInventory(&doc.Product, reserved, adjustment); err != nil {
+			return err
+		}
+		doc.Version++
+		doc.UpdatedAt = now
+		return nil
+	})
+	if err != nil {
+		return models.ProductAvailability{}, err
+	}
+
+	return availabilityOf(doc.Product, reserved), nil
+}
+
+// ReserveInventory holds quantity of a product's available-to-promise stock
+// until ttl has passed
+func (r *CosmosDBRepository) ReserveInventory(ctx context.Context, productID string, quantity int, ttl time.Duration) (models.Reservation, error) {
+	var reservation models.Reservation
+	_, err := r.modify(ctx, productID, func(doc *cosmosDocument) error {
+		now := time.Now().UTC()
+		var err error
+		reservation, err = reserve(doc.Product, heldQuantity(doc.Reservations, now), quantity, ttl, now)
+		if err != nil {
+			return err
+		}
+		if doc.Reservations == nil {
+			doc.Reservations = make(map[string]models.Reservation)
+		}
+		doc.Reservations[reservation.ID] = reservation
+		return nil
+	})
+	if err != nil {
+		return models.Reservation{}, err
+	}
+
+	return reservation, nil
+}
+
+// GetReservation retrieves a reservation that is still held. A hold past its
+// expiry that the reaper has not removed yet is reported as expired.
+func (r *CosmosDBRepository) GetReservation(ctx context.Context, productID, reservationID string) (models.Reservation, error) {
+	doc, err := r.getLiveDocument(ctx, productID)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+
+	reservation, exists := doc.Reservations[reservationID]
+	if !exists {
+		return models.Reservation{}, ErrReservationNotFound
+	}
+
+	return withCurrentStatus(reservation, time.Now()), nil
+}
+
+// ConfirmReservation turns a hold into a sale: the reserved quantity is taken
+// off the product's inventory and the reservation is removed in the same write
+func (r *CosmosDBRepository) ConfirmReservation(ctx context.Context, productID, reservationID string) (models.Reservation, error) {
+	var reservation models.Reservation
+	_, err := r.modify(ctx, productID, func(doc *cosmosDocument) error {
+		var exists bool
+		reservation, exists = doc.Reservations[reservationID]
+		if !exists {
+			return ErrReservationNotFound
+		}
+		if err := confirm(&doc.Product, &reservation, time.Now().UTC()); err != nil {
+			return err
+		}
+		delete(doc.Reservations, reservationID)
+		return nil
+	})
+	if err != nil {
+		return models.Reservation{}, err
+	}
+
+	return reservation, nil
+}
+
+// ReleaseReservation gives a held quantity back to available-to-promise
+func (r *CosmosDBRepository) ReleaseReservation(ctx context.Context, productID, reservationID string) (models.Reservation, error) {
+	var reservation models.Reservation
+	_, err := r.modify(ctx, productID, func(doc *cosmosDocument) error {
+		var exists bool
+		reservation, exists = doc.Reservations[reservationID]
+		if !exists {
+			return ErrReservationNotFound
+		}
+		delete(doc.Reservations, reservationID)
+		return nil
+	})
+	if err != nil {
+		return models.Reservation{}, err
+	}
+
+	return release(reservation, time.Now()), nil
+}
+
+// ExpireReservations removes every hold that expired at or before now and
+// returns how many were removed. Products holding lapsed reservations are
+// found through their earliest expiry, then cleaned up one document at a time.
+func (r *CosmosDBRepository) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	query := cosmosQuery{
+		Query:      "SELECT * FROM c WHERE c.reservationExpiryTs <= @now",
+		Parameters: []cosmosQueryParam{{Name: "@now", Value: now.UnixMicro()}},
+	}
+
+	var ids []string
+	continuation := ""
+	for {
+		page, next, err := r.queryPage(ctx, query, continuation, r.pageSize)
+		if err != nil {
+			return 0, err
+		}
+		for _, product := range page {
+			ids = append(ids, product.ID)
+		}
+		if next == "" {
+			break
+		}
+		continuation = next
+	}
+
+	expired := 0
+	for _, id := range ids {
+		removed := 0
+		_, err := r.modify(ctx, id, func(doc *cosmosDocument) error {
+			removed = 0
+			for reservationID, reservation := range doc.Reservations {
+				if isExpired(reservation, now) {
+					delete(doc.Reservations, reservationID)
+					removed++
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, ErrNotFound) {
+			// Deleted since the query ran, taking its reservations with it
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired += removed
+	}
+
+	return expired, nil
+}
+
+// GetProductHistory returns every revision of a product, oldest first
+func (r *CosmosDBRepository) GetProductHistory(ctx context.Context, id string) ([]models.Revision, error) {
+	doc, err := r.getDocument(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.History, nil
+}
+
+// GetProductAsOf returns a product as it stood at the given time
+func (r *CosmosDBRepository) GetProductAsOf(ctx context.Context, id string, at time.Time) (models.Product, error) {
+	doc, err := r.getDocument(ctx, id)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	return productAsOf(doc.History, at)
+}
+
+// RestoreProduct writes the state recorded by revision seq back as a new
+// revision, taking the product out of the trash if it is there. When version
+// is non-zero it must match the stored version.
+func (r *CosmosDBRepository) RestoreProduct(ctx context.Context, id string, seq int64, version int64) (models.Product, error) {
+	doc, err := r.modifyDocument(ctx, id, func(doc *cosmosDocument) error {
+		if version != 0 && version != doc.Version {
+			return ErrPreconditionFailed
+		}
+
+		product, err := restoreRevision(doc.History, seq, doc.Product, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		doc.Product = product
+		doc.restoredFrom = seq
+		return nil
+	})
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	return doc.Product, nil
+}
+
+// PendingEvents returns up to limit undelivered domain events. Products are
+// visited oldest pending event first and each product's events are returned
+// in order, so events for one product are never reordered. The gateway cannot
+// ORDER BY across partitions, so every product with pending events is read
+// and they are sorted in process.
+func (r *CosmosDBRepository) PendingEvents(ctx context.Context, limit int) ([]models.Event, error) {
+	query := cosmosQuery{
+		Query:      "SELECT * FROM c WHERE IS_DEFINED(c.outboxTs)",
+		Parameters: []cosmosQueryParam{},
+	}
+
+	var pending []cosmosDocument
+	continuation := ""
+	for {
+		docs, next, err := r.queryDocuments(ctx, query, continuation, r.pageSize)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, docs...)
+		if next == "" {
+			break
+		}
+		continuation = next
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].OutboxTs != pending[j].OutboxTs {
+			return pending[i].OutboxTs < pending[j].OutboxTs
+		}
+		return pending[i].ID < pending[j].ID
+	})
+
+	events := []models.Event{}
+	for _, doc := range pending {
+		if len(events) >= limit {
+			break
+		}
+		events = append(events, doc.Outbox...)
+	}
+
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// AckEvents removes delivered events from their products' outboxes. Events
+// that are no longer pending are ignored, so acknowledging twice is harmless.
+func (r *CosmosDBRepository) AckEvents(ctx context.Context, events []models.Event) error {
+	byProduct := make(map[string][]models.Event)
+	var ids []string
+	for _, event := range events {
+		if _, seen := byProduct[event.ProductID]; !seen {
+			ids = append(ids, event.ProductID)
+		}
+		byProduct[event.ProductID] = append(byProduct[event.ProductID], event)
+	}
+
+	for _, id := range ids {
+		acked := eventIDs(byProduct[id])
+		_, err := r.modifyDocument(ctx, id, func(doc *cosmosDocument) error {
+			doc.Outbox = withoutEvents(doc.Outbox, acked)
+			return nil
+		})
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queryAll runs a cross-partition SQL query and returns every product it
+// selects, following continuation tokens across pages. When limit is non-zero
+// it stops with ErrNotSupported as soon as more than limit products are read.
+func (r *CosmosDBRepository) queryAll(ctx context.Context, query cosmosQuery, limit int) ([]models.Product, error) {
+	products := []models.Product{}
+	continuation := ""
+	for {
+		page, next, err := r.queryPage(ctx, query, continuation, r.pageSize)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, page...)
+		if limit > 0 && len(products) > limit {
+			return nil, fmt.Errorf("%w: the query matches more than %d products, narrow it with filters", ErrNotSupported, limit)
+		}
+		if next == "" {
+			return products, nil
+		}
+		continuation = next
+	}
+}
+
+// queryPage runs a cross-partition SQL query and returns one page of results
+// along with the continuation token for the next page ("" when exhausted)
+func (r *CosmosDBRepository) queryPage(ctx context.Context, query cosmosQuery, continuation string, maxItems int) ([]models.Product, string, error) {
+	docs, next, err := r.queryDocuments(ctx, query, continuation, maxItems)
+	if err != nil {
+		return nil, "", err
+	}
+
+	products := make([]models.Product, len(docs))
+	for i, doc := range docs {
+		products[i] = doc.Product
+	}
+
+	return products, next, nil
+}
+
+// queryDocuments is queryPage returning the stored documents themselves
+func (r *CosmosDBRepository) queryDocuments(ctx context.Context, query cosmosQuery, continuation string, maxItems int) ([]cosmosDocument, string, error) {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	headers := map[string]string{
+		"Content-Type":                               "application/query+json",
+		"x-ms-documentdb-isquery":                    "True",
+		"x-ms-documentdb-query-enablecrosspartition": "True",
+		"x-ms-max-item-count":                        strconv.Itoa(maxItems),
+	}
+	if continuation != "" {
+		headers["x-ms-continuation"] = continuation
+	}
+
+	resp, err := r.do(ctx, cosmosRequest{
+		method:       http.MethodPost,
+		path:         r.collectionLink() + "/docs",
+		resourceLink: r.collectionLink(),
+		headers:      headers,
+		body:         body,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.status != http.StatusOK {
+		return nil, "", cosmosError(resp)
+	}
+
+	var result struct {
+		Documents []cosmosDocument `json:"Documents"`
+	}
+	if err := json.Unmarshal(resp.body, &result); err != nil {
+		return nil, "", fmt.Errorf("cosmos: decoding query result: %w", err)
+	}
+
+	return result.Documents, resp.header.Get("x-ms-continuation"), nil
+}
+
+// do signs and sends a request, retrying while Cosmos DB throttles it with 429 responses
+func (r *CosmosDBRepository) do(ctx context.Context, creq cosmosRequest) (*cosmosResponse, error) {
+	target := *r.endpoint
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + creq.path
+
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if creq.body != nil {
+			reader = bytes.NewReader(creq.body)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, creq.method, target.String(), reader)
+		if err != nil {
+			return nil, err
+		}
+
+		date := time.Now().UTC().Format(http.TimeFormat)
+		req.Header.Set("x-ms-date", date)
+		req.Header.Set("x-ms-version", cosmosAPIVersion)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", r.authorization(creq.method, "docs", creq.resourceLink, date))
+		if creq.body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if creq.partitionKey != "" {
+			pk, _ := json.Marshal([]string{creq.partitionKey})
+			req.Header.Set("x-ms-documentdb-partitionkey", string(pk))
+		}
+		for name, value := range creq.headers {
+			req.Header.Set(name, value)
+		}
+
+		res, err := r.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("%w: cosmos: %s %s: %w", ErrUnavailable, creq.method, creq.resourceLink, err)
+		}
+		data, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: cosmos: reading response: %w", ErrUnavailable, err)
+		}
+
+		resp := &cosmosResponse{status: res.StatusCode, header: res.Header, body: data}
+		if resp.status != http.StatusTooManyRequests || attempt >= r.maxRetries {
+			return resp, nil
+		}
+
+		// Throttled: wait for the interval Cosmos DB asked for before retrying
+		timer := time.NewTimer(retryAfter(res.Header))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// authorization builds the master-key HMAC authorization header value
+func (r *CosmosDBRepository) authorization(method, resourceType, resourceLink, date string) string {
+	payload := strings.ToLower(method) + "\n" +
+		strings.ToLower(resourceType) + "\n" +
+		resourceLink + "\n" +
+		strings.ToLower(date) + "\n" +
+		"" + "\n"
+
+	return cosmosSignature(r.key, payload)
+}
+
+// cosmosSignature signs payload with the master key and returns the URL-encoded token
+func cosmosSignature(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return url.QueryEscape("type=master&ver=1.0&sig=" + sig)
+}
+
+// collectionLink returns the resource link of the products container
+func (r *CosmosDBRepository) collectionLink() string {
+	return "dbs/" + r.databaseName + "/colls/" + r.containerName
+}
+
+// documentLink returns the resource link of a single product document
+func (r *CosmosDBRepository) documentLink(id string) string {
+	return r.collectionLink() + "/docs/" + id
+}
+
+// documentRequest builds a point operation on a single product document
+func (r *CosmosDBRepository) documentRequest(method, id string, body []byte) cosmosRequest {
+	return cosmosRequest{
+		method:       method,
+		path:         r.documentLink(id),
+		resourceLink: r.documentLink(id),
+		partitionKey: id,
+		body:         body,
+	}
+}
+
+// retryAfter reads the throttling interval from a 429 response
+func retryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.Atoi(header.Get("x-ms-retry-after-ms")); err == nil && ms >= 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return cosmosDefaultRetryAfter
+}
+
+// cosmosError converts an unexpected Cosmos DB response into an error. Throttling
+// that outlasted the retries and server-side failures wrap ErrUnavailable.
+func cosmosError(resp *cosmosResponse) error {
+	detail := fmt.Sprintf("cosmos: unexpected status %d", resp.status)
+
+	var body struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(resp.body, &body); err == nil && body.Message != "" {
+		detail = fmt.Sprintf("cosmos: unexpected status %d (%s): %s", resp.status, body.Code, body.Message)
+	}
+
+	switch {
+	case resp.status == http.StatusTooManyRequests,
+		resp.status == http.StatusRequestTimeout,
+		resp.status >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: %s", ErrUnavailable, detail)
+	}
+	return errors.New(detail)
 }
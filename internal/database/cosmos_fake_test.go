@@ -0,0 +1,504 @@
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeCosmos is an in-process stand-in for the Cosmos DB SQL REST API.
+// It serves a single database/container pair, verifies master-key signatures
+// and partition key headers, and can be told to throttle requests with 429s.
+type fakeCosmos struct {
+	server    *httptest.Server
+	key       []byte
+	database  string
+	container string
+
+	mu       sync.Mutex
+	docs     map[string]map[string]interface{}
+	order    []string
+	throttle int
+	requests int
+}
+
+// newFakeCosmos starts a fake Cosmos DB account that is shut down with the test
+func newFakeCosmos(t *testing.T) *fakeCosmos {
+	t.Helper()
+
+	f := &fakeCosmos{
+		key:       []byte("fake-cosmos-master-key"),
+		database:  "product-db",
+		container: "products",
+		docs:      make(map[string]map[string]interface{}),
+	}
+	f.server = httptest.NewServer(f)
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+// connectionString returns a connection string pointing at the fake account
+func (f *fakeCosmos) connectionString() string {
+	return "AccountEndpoint=" + f.server.URL + "/;AccountKey=" + base64.StdEncoding.EncodeToString(f.key) + ";"
+}
+
+// newRepository returns a CosmosDBRepository wired to the fake account
+func (f *fakeCosmos) newRepository(t *testing.T) *CosmosDBRepository {
+	t.Helper()
+
+	repo, err := NewCosmosDBRepository(f.connectionString(), f.database, f.container)
+	if err != nil {
+		t.Fatalf("creating cosmos repository: %v", err)
+	}
+	return repo
+}
+
+// throttleNext makes the next n requests fail with 429 Too Many Requests
+func (f *fakeCosmos) throttleNext(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.throttle = n
+}
+
+// requestCount returns how many requests the fake has received
+func (f *fakeCosmos) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func (f *fakeCosmos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	collection := "dbs/" + f.database + "/colls/" + f.container
+	path := strings.TrimPrefix(r.URL.Path, "/")
+
+	var resourceLink, id string
+	switch {
+	case path == collection+"/docs":
+		resourceLink = collection
+	case strings.HasPrefix(path, collection+"/docs/"):
+		id = strings.TrimPrefix(path, collection+"/docs/")
+		resourceLink = path
+	default:
+		f.fail(w, http.StatusNotFound, "NotFound", "unknown resource "+path)
+		return
+	}
+
+	if !f.authorized(r, resourceLink) {
+		f.fail(w, http.StatusUnauthorized, "Unauthorized", "invalid master key signature")
+		return
+	}
+
+	if f.throttle > 0 {
+		f.throttle--
+		w.Header().Set("x-ms-retry-after-ms", "1")
+		f.fail(w, http.StatusTooManyRequests, "TooManyRequests", "request rate is large")
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+
+	if id == "" {
+		switch {
+		case r.Method == http.MethodPost && r.Header.Get("x-ms-documentdb-isquery") == "True":
+			f.query(w, r, body)
+		case r.Method == http.MethodPost:
+			f.create(w, r, body)
+		default:
+			f.fail(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+		}
+		return
+	}
+
+	if !f.partitionKeyMatches(r, id) {
+		f.fail(w, http.StatusBadRequest, "BadRequest", "partition key does not match document")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		doc, ok := f.docs[id]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NotFound", "document not found")
+			return
+		}
+		f.write(w, http.StatusOK, doc)
+	case http.MethodPut:
+		if _, ok := f.docs[id]; !ok {
+			f.fail(w, http.StatusNotFound, "NotFound", "document not found")
+			return
+		}
+		if !f.ifMatch(r, id) {
+			f.fail(w, http.StatusPreconditionFailed, "PreconditionFailed", "etag does not match")
+			return
+		}
+		doc, ok := f.decode(w, body)
+		if !ok {
+			return
+		}
+		doc["id"] = id
+		f.store(doc)
+		f.write(w, http.StatusOK, doc)
+	case http.MethodDelete:
+		if _, ok := f.docs[id]; !ok {
+			f.fail(w, http.StatusNotFound, "NotFound", "document not found")
+			return
+		}
+		if !f.ifMatch(r, id) {
+			f.fail(w, http.StatusPreconditionFailed, "PreconditionFailed", "etag does not match")
+			return
+		}
+		delete(f.docs, id)
+		for i, existing := range f.order {
+			if existing == id {
+				f.order = append(f.order[:i], f.order[i+1:]...)
+				break
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.fail(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+// create handles POST on the docs feed
+func (f *fakeCosmos) create(w http.ResponseWriter, r *http.Request, body []byte) {
+	doc, ok := f.decode(w, body)
+	if !ok {
+		return
+	}
+
+	id, _ := doc["id"].(string)
+	if id == "" {
+		f.fail(w, http.StatusBadRequest, "BadRequest", "document id is required")
+		return
+	}
+	if !f.partitionKeyMatches(r, id) {
+		f.fail(w, http.StatusBadRequest, "BadRequest", "partition key does not match document")
+		return
+	}
+	if _, exists := f.docs[id]; exists {
+		f.fail(w, http.StatusConflict, "Conflict", "entity with the specified id already exists")
+		return
+	}
+
+	f.order = append(f.order, id)
+	f.store(doc)
+	f.write(w, http.StatusCreated, doc)
+}
+
+// query handles SQL queries on the docs feed. It understands the subset of
+// Cosmos DB SQL that the repository generates: a conjunction of comparisons and
+// STARTSWITH, IS_DEFINED and ARRAY_CONTAINS calls followed by an ORDER BY list.
+// Like the real gateway, it refuses ORDER BY on a cross-partition query, which
+// only the SDKs can run by fetching a query plan first.
+func (f *fakeCosmos) query(w http.ResponseWriter, r *http.Request, body []byte) {
+	var q cosmosQuery
+	if err := json.Unmarshal(body, &q); err != nil {
+		f.fail(w, http.StatusBadRequest, "BadRequest", "invalid query body")
+		return
+	}
+	if r.Header.Get("x-ms-documentdb-query-enablecrosspartition") == "True" && strings.Contains(q.Query, " ORDER BY ") {
+		f.fail(w, http.StatusBadRequest, "BadRequest", "The provided cross partition query can not be directly served by the gateway.")
+		return
+	}
+
+	matches, err := f.evaluate(q)
+	if err != nil {
+		f.fail(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	offset := 0
+	if token := r.Header.Get("x-ms-continuation"); token != "" {
+		n, err := strconv.Atoi(token)
+		if err != nil {
+			f.fail(w, http.StatusBadRequest, "BadRequest", "invalid continuation token")
+			return
+		}
+		offset = n
+	}
+
+	limit := len(matches)
+	if n, err := strconv.Atoi(r.Header.Get("x-ms-max-item-count")); err == nil && n > 0 {
+		limit = n
+	}
+
+	documents := []map[string]interface{}{}
+	end := offset
+	for ; end < len(matches) && len(documents) < limit; end++ {
+		documents = append(documents, matches[end])
+	}
+	if end < len(matches) {
+		w.Header().Set("x-ms-continuation", strconv.Itoa(end))
+	}
+
+	f.write(w, http.StatusOK, map[string]interface{}{
+		"_rid":      "fake",
+		"Documents": documents,
+		"_count":    len(documents),
+	})
+}
+
+var (
+	fakeSelectPattern     = regexp.MustCompile(`^SELECT (\*|c\.id) FROM c(?: WHERE (.+?))?(?: ORDER BY (.+))?$`)
+	fakeComparePattern    = regexp.MustCompile(`^c\.(\w+) (>=|<=|>|<|=) (@\w+|-?[0-9.]+)$`)
+	fakeStartsWithPattern = regexp.MustCompile(`^STARTSWITH\(c\.(\w+), (@\w+)\)$`)
+	fakeIsDefinedPattern  = regexp.MustCompile(`^(NOT )?IS_DEFINED\(c\.(\w+)\)$`)
+	fakeContainsPattern   = regexp.MustCompile(`^ARRAY_CONTAINS\((@\w+), c\.(\w+)\)$`)
+	fakeOrderPattern      = regexp.MustCompile(`^c\.(\w+) (ASC|DESC)$`)
+)
+
+// evaluate returns the documents selected by q in the requested order
+func (f *fakeCosmos) evaluate(q cosmosQuery) ([]map[string]interface{}, error) {
+	parts := fakeSelectPattern.FindStringSubmatch(q.Query)
+	if parts == nil {
+		return nil, fmt.Errorf("unsupported query %q", q.Query)
+	}
+
+	params := make(map[string]interface{})
+	for _, param := range q.Parameters {
+		params[param.Name] = param.Value
+	}
+
+	var predicates []func(doc map[string]interface{}) bool
+	if parts[2] != "" {
+		for _, condition := range strings.Split(parts[2], " AND ") {
+			predicate, err := fakePredicate(condition, params)
+			if err != nil {
+				return nil, err
+			}
+			predicates = append(predicates, predicate)
+		}
+	}
+
+	type orderBy struct {
+		property   string
+		descending bool
+	}
+	var order []orderBy
+	if parts[3] != "" {
+		for _, term := range strings.Split(parts[3], ", ") {
+			m := fakeOrderPattern.FindStringSubmatch(term)
+			if m == nil {
+				return nil, fmt.Errorf("unsupported ORDER BY term %q", term)
+			}
+			order = append(order, orderBy{property: m[1], descending: m[2] == "DESC"})
+		}
+	}
+
+	matches := []map[string]interface{}{}
+	for _, id := range f.order {
+		doc := f.docs[id]
+		selected := true
+		for _, predicate := range predicates {
+			if !predicate(doc) {
+				selected = false
+				break
+			}
+		}
+		// Like Cosmos DB, ORDER BY drops documents missing an ordered property
+		for _, term := range order {
+			if _, ok := doc[term.property]; !ok {
+				selected = false
+			}
+		}
+		if selected {
+			if parts[1] == "c.id" {
+				doc = map[string]interface{}{"id": doc["id"]}
+			}
+			matches = append(matches, doc)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		for _, term := range order {
+			c, _ := fakeCompare(matches[i][term.property], matches[j][term.property])
+			if term.descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	return matches, nil
+}
+
+// fakePredicate compiles one WHERE condition
+func fakePredicate(condition string, params map[string]interface{}) (func(map[string]interface{}) bool, error) {
+	if m := fakeIsDefinedPattern.FindStringSubmatch(condition); m != nil {
+		return func(doc map[string]interface{}) bool {
+			_, defined := doc[m[2]]
+			return defined != (m[1] != "")
+		}, nil
+	}
+
+	if m := fakeContainsPattern.FindStringSubmatch(condition); m != nil {
+		values, ok := params[m[1]].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("missing array parameter %s", m[1])
+		}
+		return func(doc map[string]interface{}) bool {
+			for _, value := range values {
+				if c, ok := fakeCompare(doc[m[2]], value); ok && c == 0 {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	if m := fakeStartsWithPattern.FindStringSubmatch(condition); m != nil {
+		prefix, ok := params[m[2]].(string)
+		if !ok {
+			return nil, fmt.Errorf("missing string parameter %s", m[2])
+		}
+		return func(doc map[string]interface{}) bool {
+			value, ok := doc[m[1]].(string)
+			return ok && strings.HasPrefix(value, prefix)
+		}, nil
+	}
+
+	m := fakeComparePattern.FindStringSubmatch(condition)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported condition %q", condition)
+	}
+
+	var operand interface{}
+	if strings.HasPrefix(m[3], "@") {
+		value, ok := params[m[3]]
+		if !ok {
+			return nil, fmt.Errorf("missing parameter %s", m[3])
+		}
+		operand = value
+	} else {
+		n, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			return nil, err
+		}
+		operand = n
+	}
+
+	return func(doc map[string]interface{}) bool {
+		c, ok := fakeCompare(doc[m[1]], operand)
+		if !ok {
+			return false
+		}
+		switch m[2] {
+		case ">=":
+			return c >= 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		case "<":
+			return c < 0
+		default:
+			return c == 0
+		}
+	}, nil
+}
+
+// fakeCompare compares two JSON values of the same kind; ok is false otherwise
+func fakeCompare(a, b interface{}) (c int, ok bool) {
+	switch x := a.(type) {
+	case float64:
+		y, isNumber := b.(float64)
+		if !isNumber {
+			return 0, false
+		}
+		return compareOrdered(x, y), true
+	case string:
+		y, isString := b.(string)
+		if !isString {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	}
+	return 0, false
+}
+
+// authorized recomputes the master-key signature the way the Cosmos DB service does
+func (f *fakeCosmos) authorized(r *http.Request, resourceLink string) bool {
+	date := r.Header.Get("x-ms-date")
+	if date == "" || r.Header.Get("x-ms-version") == "" {
+		return false
+	}
+
+	token, err := url.QueryUnescape(r.Header.Get("Authorization"))
+	if err != nil || !strings.HasPrefix(token, "type=master&ver=1.0&sig=") {
+		return false
+	}
+
+	payload := strings.ToLower(r.Method) + "\ndocs\n" + resourceLink + "\n" + strings.ToLower(date) + "\n\n"
+	mac := hmac.New(sha256.New, f.key)
+	mac.Write([]byte(payload))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(strings.TrimPrefix(token, "type=master&ver=1.0&sig=")), []byte(expected))
+}
+
+// ifMatch evaluates an optional If-Match header against the stored _etag
+func (f *fakeCosmos) ifMatch(r *http.Request, id string) bool {
+	expected := r.Header.Get("If-Match")
+	return expected == "" || expected == f.docs[id]["_etag"]
+}
+
+// partitionKeyMatches checks the x-ms-documentdb-partitionkey header against the document ID
+func (f *fakeCosmos) partitionKeyMatches(r *http.Request, id string) bool {
+	var pk []string
+	if err := json.Unmarshal([]byte(r.Header.Get("x-ms-documentdb-partitionkey")), &pk); err != nil {
+		return false
+	}
+	return len(pk) == 1 && pk[0] == id
+}
+
+// store saves a document, stamping the system properties Cosmos DB adds
+func (f *fakeCosmos) store(doc map[string]interface{}) {
+	doc["_rid"] = uuid.New().String()
+	doc["_etag"] = `"` + uuid.New().String() + `"`
+	doc["_ts"] = time.Now().Unix()
+	f.docs[doc["id"].(string)] = doc
+}
+
+// decode parses a JSON document body, answering 400 when it is malformed
+func (f *fakeCosmos) decode(w http.ResponseWriter, body []byte) (map[string]interface{}, bool) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		f.fail(w, http.StatusBadRequest, "BadRequest", "invalid document")
+		return nil, false
+	}
+	return doc, true
+}
+
+func (f *fakeCosmos) write(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeCosmos) fail(w http.ResponseWriter, status int, code, message string) {
+	f.write(w, status, map[string]string{"code": code, "message": message})
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+func TestCosmosDBRepository(t *testing.T) {
+	testProductRepository(t, func(t *testing.T) ProductRepository {
+		return newFakeCosmos(t).newRepository(t)
+	})
+}
+
+func TestNewCosmosDBRepository(t *testing.T) {
+	// Test a valid connection string
+	t.Run("ValidConnectionString", func(t *testing.T) {
+		repo, err := NewCosmosDBRepository("AccountEndpoint=https://example.documents.azure.com:443/;AccountKey=a2V5PT0=;", "db", "coll")
+		require.NoError(t, err)
+		assert.Equal(t, "example.documents.azure.com:443", repo.endpoint.Host)
+		assert.Equal(t, []byte("key=="), repo.key)
+	})
+
+	// Test missing parts of the connection string
+	t.Run("InvalidConnectionString", func(t *testing.T) {
+		_, err := NewCosmosDBRepository("", "db", "coll")
+		assert.Error(t, err)
+
+		_, err = NewCosmosDBRepository("AccountEndpoint=https://example.documents.azure.com:443/;", "db", "coll")
+		assert.Error(t, err)
+
+		_, err = NewCosmosDBRepository("AccountEndpoint=https://example.documents.azure.com:443/;AccountKey=not base64;", "db", "coll")
+		assert.Error(t, err)
+	})
+
+	// Test missing database or container names
+	t.Run("MissingNames", func(t *testing.T) {
+		_, err := NewCosmosDBRepository("AccountEndpoint=https://example.documents.azure.com:443/;AccountKey=a2V5;", "", "coll")
+		assert.Error(t, err)
+	})
+}
+
+func TestCosmosDBRepositoryProtocol(t *testing.T) {
+	ctx := context.Background()
+
+	// Test that requests signed with the wrong key are rejected
+	t.Run("WrongKey", func(t *testing.T) {
+		fake := newFakeCosmos(t)
+		repo := fake.newRepository(t)
+		repo.key = []byte("wrong-key")
+
+		_, err := repo.GetProducts(ctx)
+		assert.ErrorContains(t, err, "401")
+	})
+
+	// Test that throttled requests are retried after x-ms-retry-after-ms
+	t.Run("RetryOnThrottle", func(t *testing.T) {
+		fake := newFakeCosmos(t)
+		repo := fake.newRepository(t)
+		fake.throttleNext(3)
+
+		created, err := repo.CreateProduct(ctx, models.Product{Name: "Throttled", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		assert.Equal(t, 4, fake.requestCount())
+
+		_, err = repo.GetProductByID(ctx, created.ID)
+		assert.NoError(t, err)
+	})
+
+	// Test that retries give up after maxRetries
+	t.Run("RetryExhausted", func(t *testing.T) {
+		fake := newFakeCosmos(t)
+		repo := fake.newRepository(t)
+		repo.maxRetries = 2
+		fake.throttleNext(5)
+
+		_, err := repo.GetProductByID(ctx, "any")
+		assert.ErrorContains(t, err, "429")
+		assert.ErrorIs(t, err, ErrUnavailable)
+		assert.Equal(t, 3, fake.requestCount())
+	})
+
+	// Test that a cancelled context stops the retry loop
+	t.Run("RetryCancelled", func(t *testing.T) {
+		fake := newFakeCosmos(t)
+		repo := fake.newRepository(t)
+		fake.throttleNext(100)
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := repo.GetProductByID(cancelled, "any")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	// Test that an unreachable account is reported as unavailable
+	t.Run("Unreachable", func(t *testing.T) {
+		fake := newFakeCosmos(t)
+		repo := fake.newRepository(t)
+		fake.server.Close()
+
+		_, err := repo.GetProductByID(ctx, "any")
+		assert.ErrorIs(t, err, ErrUnavailable)
+	})
+
+	// Test that GetProducts follows continuation tokens across pages
+	t.Run("Continuation", func(t *testing.T) {
+		fake := newFakeCosmos(t)
+		repo := fake.newRepository(t)
+		repo.pageSize = 2
+
+		for i := 0; i < 5; i++ {
+			_, err := repo.CreateProduct(ctx, models.Product{Name: fmt.Sprintf("Product %d", i), Description: "Test Description", Price: 1.0})
+			require.NoError(t, err)
+		}
+
+		products, err := repo.GetProducts(ctx)
+		require.NoError(t, err)
+		assert.Len(t, products, 5)
+		// 5 creates + 3 query pages
+		assert.Equal(t, 8, fake.requestCount())
+	})
+
+	// Test that a query reading too many products to sort in process is refused
+	t.Run("QueryScanLimit", func(t *testing.T) {
+		fake := newFakeCosmos(t)
+		repo := fake.newRepository(t)
+		repo.maxQueryScan = 3
+
+		for i := 0; i < 5; i++ {
+			_, err := repo.CreateProduct(ctx, models.Product{Name: fmt.Sprintf("Product %d", i), Description: "Test Description", Price: float64(i + 1)})
+			require.NoError(t, err)
+		}
+
+		_, err := repo.QueryProducts(ctx, ProductQuery{Limit: 2})
+		assert.ErrorIs(t, err, ErrNotSupported)
+
+		minPrice := 3.0
+		page, err := repo.QueryProducts(ctx, ProductQuery{Limit: 2, Filter: ProductFilter{MinPrice: &minPrice}})
+		require.NoError(t, err)
+		assert.Len(t, page.Products, 2)
+	})
+}
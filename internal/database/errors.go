@@ -0,0 +1,56 @@
+package database
+
+import "errors"
+
+// Errors returned by every ProductRepository implementation. Backends wrap them
+// with detail, so callers should test with errors.Is rather than comparing.
+var (
+	// ErrNotFound is returned when no product has the requested ID
+	ErrNotFound = errors.New("product not found")
+
+	// ErrConflict is returned when a write collides with existing state,
+	// such as creating a product whose ID is already taken
+	ErrConflict = errors.New("product already exists")
+
+	// ErrPreconditionFailed is returned when a conditional write names a version
+	// that no longer matches the stored product
+	ErrPreconditionFailed = errors.New("product version does not match")
+
+	// ErrInsufficientInventory is returned when an inventory adjustment or a
+	// product write would take the inventory count below zero or below the
+	// quantity held by reservations, or when a reservation asks for more than
+	// is available
+	ErrInsufficientInventory = errors.New("insufficient inventory")
+
+	// ErrReservationNotFound is returned when a product has no held reservation
+	// with the requested ID
+	ErrReservationNotFound = errors.New("reservation not found")
+
+	// ErrReservationExpired is returned when confirming a reservation whose
+	// hold has already lapsed
+	ErrReservationExpired = errors.New("reservation has expired")
+
+	// ErrRevisionNotFound is returned when a product's history has no revision
+	// with the requested number, or that revision is a delete
+	ErrRevisionNotFound = errors.New("revision not found")
+
+	// ErrBatchAborted is reported for the operations of an all-or-nothing
+	// BulkWrite that were not applied because another operation failed
+	ErrBatchAborted = errors.New("batch aborted")
+
+	// ErrNotSupported is returned when a backend cannot provide an operation,
+	// such as an atomic BulkWrite spanning partitions
+	ErrNotSupported = errors.New("operation not supported by this storage backend")
+
+	// ErrUnavailable is returned when the storage backend cannot be reached or
+	// is refusing work; the operation may succeed if retried later
+	ErrUnavailable = errors.New("storage backend unavailable")
+
+	// ErrUnknownTenant is returned by a TenantRepository when the context names
+	// no tenant, or one it does not serve
+	ErrUnknownTenant = errors.New("unknown tenant")
+
+	// ErrQuotaExceeded is returned by a TenantRepository when a write would take
+	// a tenant past its product quota
+	ErrQuotaExceeded = errors.New("product quota exceeded")
+)
@@ -0,0 +1,365 @@
+package database
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/yourusername/product-service/internal/models"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+
+	// walHeaderSize is the length (uint32) plus CRC-32C (uint32) framing each record
+	walHeaderSize = 8
+
+	// DefaultSnapshotInterval is how many WAL records trigger a compaction
+	DefaultSnapshotInterval = 1000
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// FileRepository is a durable ProductRepository backed by a directory on local disk.
+// It serves reads from memory exactly like InMemoryRepository, appends every mutation
+// to a write-ahead log before applying it, and compacts the log into a snapshot once
+// it holds snapshotInterval records.
+type FileRepository struct {
+	*InMemoryRepository
+
+	dir              string
+	wal              *os.File
+	walSize          int64
+	seq              uint64 // sequence number of the last record written
+	records          int    // records in the WAL since the last snapshot
+	snapshotInterval int
+}
+
+// walRecord is a single framed entry of the write-ahead log. A single mutation
+// is stored inline; several committed together are stored in Batch.
+type walRecord struct {
+	Seq uint64 `json:"seq"`
+	mutation
+	Batch []mutation `json:"batch,omitempty"`
+}
+
+// mutations returns the mutations the record commits
+func (rec walRecord) mutations() []mutation {
+	if len(rec.Batch) > 0 {
+		return rec.Batch
+	}
+	return []mutation{rec.mutation}
+}
+
+// fileSnapshot is the compacted state written to snapshot.json
+type fileSnapshot struct {
+	Seq          uint64               `json:"seq"`
+	Products     []models.Product     `json:"products"`
+	Reservations []models.Reservation `json:"reservations,omitempty"`
+	History      []models.Revision    `json:"history,omitempty"`
+	Outbox       []models.Event       `json:"outbox,omitempty"`
+}
+
+// NewFileRepository opens (or creates) a file-backed repository in dir and recovers
+// its state from the latest snapshot plus the write-ahead log. A torn record at the
+// end of the log, left by a crash mid-write, is discarded and the log truncated.
+func NewFileRepository(dir string, snapshotInterval int) (*FileRepository, error) {
+	if snapshotInterval <= 0 {
+		snapshotInterval = DefaultSnapshotInterval
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating data directory: %w", err)
+	}
+
+	r := &FileRepository{
+		InMemoryRepository: NewInMemoryRepository(),
+		dir:                dir,
+		snapshotInterval:   snapshotInterval,
+	}
+
+	if err := r.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening write-ahead log: %w", err)
+	}
+	r.wal = wal
+
+	if err := r.replay(); err != nil {
+		wal.Close()
+		return nil, err
+	}
+
+	r.InMemoryRepository.journal = r
+
+	return r, nil
+}
+
+// Compact writes a snapshot of the current state and empties the write-ahead log
+func (r *FileRepository) Compact() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.compact()
+}
+
+// Close releases the write-ahead log. Further mutations fail.
+func (r *FileRepository) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.wal.Close()
+}
+
+// record appends ms to the write-ahead log as one record and fsyncs it
+func (r *FileRepository) record(ms []mutation) error {
+	rec := walRecord{Seq: r.seq + 1}
+	if len(ms) == 1 {
+		rec.mutation = ms[0]
+	} else {
+		rec.Batch = ms
+	}
+
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	frame := make([]byte, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[walHeaderSize:], payload)
+
+	_, err = r.wal.Write(frame)
+	if err == nil {
+		err = r.wal.Sync()
+	}
+	if err != nil {
+		// Roll back a partial write so it cannot be replayed after a restart
+		r.wal.Truncate(r.walSize)
+		r.wal.Seek(r.walSize, io.SeekStart)
+		return fmt.Errorf("%w: writing write-ahead log: %w", ErrUnavailable, err)
+	}
+
+	r.walSize += int64(len(frame))
+	r.seq++
+	r.records++
+
+	return nil
+}
+
+// checkpoint compacts the log once it has grown past the snapshot interval
+func (r *FileRepository) checkpoint() {
+	if r.records < r.snapshotInterval {
+		return
+	}
+
+	// The mutation is already durable in the WAL, so a failed compaction only
+	// delays the next one
+	if err := r.compact(); err != nil {
+		log.Printf("Warning: compacting %s: %v", r.dir, err)
+	}
+}
+
+// compact atomically replaces the snapshot with the current state and truncates the WAL.
+// A crash between the two steps is harmless: replay skips records the snapshot covers.
+func (r *FileRepository) compact() error {
+	snap := fileSnapshot{Seq: r.seq, Products: make([]models.Product, 0, len(r.products))}
+	for _, product := range r.products {
+		snap.Products = append(snap.Products, product)
+	}
+	sort.Slice(snap.Products, func(i, j int) bool { return snap.Products[i].ID < snap.Products[j].ID })
+	for _, held := range r.reservations {
+		for _, reservation := range held {
+			snap.Reservations = append(snap.Reservations, reservation)
+		}
+	}
+	sort.Slice(snap.Reservations, func(i, j int) bool { return snap.Reservations[i].ID < snap.Reservations[j].ID })
+	ids := make([]string, 0, len(r.history))
+	for id := range r.history {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		snap.History = append(snap.History, r.history[id]...)
+	}
+	snap.Outbox = r.outbox
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.dir, snapshotFileName)
+	if err := writeFileSync(path+".tmp", data); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	if err := syncDir(r.dir); err != nil {
+		return err
+	}
+
+	if err := r.wal.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := r.wal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := r.wal.Sync(); err != nil {
+		return err
+	}
+
+	r.walSize = 0
+	r.records = 0
+
+	return nil
+}
+
+// loadSnapshot restores the state saved by the last compaction, if any
+func (r *FileRepository) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(r.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+
+	var snap fileSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("decoding snapshot: %w", err)
+	}
+
+	for _, product := range snap.Products {
+		r.products[product.ID] = product
+	}
+	for _, reservation := range snap.Reservations {
+		reservation := reservation
+		r.apply(mutation{Op: opPutReservation, ID: reservation.ID, Reservation: &reservation})
+	}
+	for _, revision := range snap.History {
+		r.history[revision.ProductID] = append(r.history[revision.ProductID], revision)
+	}
+	r.outbox = snap.Outbox
+	r.seq = snap.Seq
+
+	return nil
+}
+
+// replay applies every intact WAL record newer than the snapshot and truncates
+// whatever follows the last intact record. Records may be any size; a length
+// running past the end of the log marks a torn header.
+func (r *FileRepository) replay() error {
+	info, err := r.wal.Stat()
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(r.wal)
+	header := make([]byte, walHeaderSize)
+	var offset int64
+
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return r.truncateTail(offset, err)
+		}
+
+		size := binary.LittleEndian.Uint32(header[0:4])
+		if int64(size) > info.Size()-offset-walHeaderSize {
+			return r.truncateTail(offset, fmt.Errorf("record length %d out of range", size))
+		}
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return r.truncateTail(offset, err)
+		}
+		if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+			return r.truncateTail(offset, errors.New("checksum mismatch"))
+		}
+
+		var rec walRecord
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return r.truncateTail(offset, err)
+		}
+
+		if rec.Seq > r.seq {
+			for _, m := range rec.mutations() {
+				r.apply(m)
+			}
+			r.seq = rec.Seq
+		}
+		r.records++
+		offset += int64(walHeaderSize) + int64(size)
+	}
+
+	r.walSize = offset
+	_, err = r.wal.Seek(offset, io.SeekStart)
+	return err
+}
+
+// truncateTail drops a torn or corrupt tail starting at offset
+func (r *FileRepository) truncateTail(offset int64, cause error) error {
+	info, err := r.wal.Stat()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Warning: discarding %d bytes of write-ahead log in %s after offset %d: %v",
+		info.Size()-offset, r.dir, offset, cause)
+
+	if err := r.wal.Truncate(offset); err != nil {
+		return fmt.Errorf("truncating write-ahead log: %w", err)
+	}
+	if err := r.wal.Sync(); err != nil {
+		return err
+	}
+
+	r.walSize = offset
+	_, err = r.wal.Seek(offset, io.SeekStart)
+	return err
+}
+
+// writeFileSync writes data to path and fsyncs it before returning
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir fsyncs a directory so a rename inside it is durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,331 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// openFileRepository opens a FileRepository in dir and closes it with the test
+func openFileRepository(t *testing.T, dir string, snapshotInterval int) *FileRepository {
+	t.Helper()
+
+	repo, err := NewFileRepository(dir, snapshotInterval)
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	return repo
+}
+
+func TestFileRepository(t *testing.T) {
+	testProductRepository(t, func(t *testing.T) ProductRepository {
+		return openFileRepository(t, t.TempDir(), 3)
+	})
+}
+
+func TestFileRepositoryRecovery(t *testing.T) {
+	ctx := context.Background()
+
+	// Test that every mutation survives a restart
+	t.Run("ReopenReplaysWAL", func(t *testing.T) {
+		dir := t.TempDir()
+		repo := openFileRepository(t, dir, 100)
+
+		kept, err := repo.CreateProduct(ctx, models.Product{Name: "Kept", Description: "Test Description", Price: 1.0, InventoryCount: 1})
+		require.NoError(t, err)
+		removed, err := repo.CreateProduct(ctx, models.Product{Name: "Removed", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		kept.InventoryCount = 7
+		_, err = repo.UpdateProduct(ctx, kept)
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteProduct(ctx, removed.ID, 0))
+		require.NoError(t, repo.Close())
+
+		reopened := openFileRepository(t, dir, 100)
+		products, err := reopened.GetProducts(ctx)
+		require.NoError(t, err)
+		require.Len(t, products, 1)
+		assert.Equal(t, kept.ID, products[0].ID)
+		assert.Equal(t, 7, products[0].InventoryCount)
+	})
+
+	// Test that compaction writes a snapshot and empties the WAL
+	t.Run("Compaction", func(t *testing.T) {
+		dir := t.TempDir()
+		repo := openFileRepository(t, dir, 3)
+
+		for i := 0; i < 4; i++ {
+			_, err := repo.CreateProduct(ctx, models.Product{Name: fmt.Sprintf("Product %d", i), Description: "Test Description", Price: 1.0})
+			require.NoError(t, err)
+		}
+
+		assert.FileExists(t, filepath.Join(dir, snapshotFileName))
+		assert.Equal(t, 1, repo.records)
+		require.NoError(t, repo.Close())
+
+		reopened := openFileRepository(t, dir, 3)
+		products, err := reopened.GetProducts(ctx)
+		require.NoError(t, err)
+		assert.Len(t, products, 4)
+	})
+
+	// Test that records already covered by the snapshot are not applied twice
+	t.Run("CrashBetweenSnapshotAndTruncate", func(t *testing.T) {
+		dir := t.TempDir()
+		repo := openFileRepository(t, dir, 100)
+
+		product, err := repo.CreateProduct(ctx, models.Product{Name: "Deleted Later", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteProduct(ctx, product.ID, 0))
+
+		wal, err := os.ReadFile(filepath.Join(dir, walFileName))
+		require.NoError(t, err)
+		require.NoError(t, repo.Compact())
+		require.NoError(t, repo.Close())
+
+		// Simulate a crash after the snapshot rename but before the WAL truncate
+		require.NoError(t, os.WriteFile(filepath.Join(dir, walFileName), wal, 0o644))
+
+		reopened := openFileRepository(t, dir, 100)
+		products, err := reopened.GetProducts(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, products)
+
+		// New records continue the sequence after the snapshot
+		_, err = reopened.CreateProduct(ctx, models.Product{Name: "After Crash", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), reopened.seq)
+	})
+
+	// Test that a torn final record is discarded and the log truncated
+	t.Run("TruncatedTail", func(t *testing.T) {
+		dir := t.TempDir()
+		repo := openFileRepository(t, dir, 100)
+
+		_, err := repo.CreateProduct(ctx, models.Product{ID: "intact", Name: "Intact", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		_, err = repo.CreateProduct(ctx, models.Product{ID: "torn", Name: "Torn", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		intactSize := repo.walSize
+		require.NoError(t, repo.Close())
+
+		path := filepath.Join(dir, walFileName)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.NoError(t, os.Truncate(path, info.Size()-5))
+
+		reopened := openFileRepository(t, dir, 100)
+		_, err = reopened.GetProductByID(ctx, "intact")
+		assert.NoError(t, err)
+		_, err = reopened.GetProductByID(ctx, "torn")
+		assert.Error(t, err)
+
+		info, err = os.Stat(path)
+		require.NoError(t, err)
+		assert.Less(t, info.Size(), intactSize)
+
+		// The log stays appendable after recovery
+		_, err = reopened.CreateProduct(ctx, models.Product{ID: "torn", Name: "Torn", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		require.NoError(t, reopened.Close())
+
+		again := openFileRepository(t, dir, 100)
+		products, err := again.GetProducts(ctx)
+		require.NoError(t, err)
+		assert.Len(t, products, 2)
+	})
+
+	// Test that a corrupted final record is detected by its checksum
+	t.Run("CorruptTail", func(t *testing.T) {
+		dir := t.TempDir()
+		repo := openFileRepository(t, dir, 100)
+
+		_, err := repo.CreateProduct(ctx, models.Product{ID: "intact", Name: "Intact", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		_, err = repo.CreateProduct(ctx, models.Product{ID: "corrupt", Name: "Corrupt", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		require.NoError(t, repo.Close())
+
+		path := filepath.Join(dir, walFileName)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		data[len(data)-2] ^= 0xff
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+
+		reopened := openFileRepository(t, dir, 100)
+		products, err := reopened.GetProducts(ctx)
+		require.NoError(t, err)
+		require.Len(t, products, 1)
+		assert.Equal(t, "intact", products[0].ID)
+	})
+
+	// Test that a record larger than any fixed read buffer is replayed with those after it
+	t.Run("LargeRecord", func(t *testing.T) {
+		dir := t.TempDir()
+		repo := openFileRepository(t, dir, 100)
+
+		description := strings.Repeat("x", 17<<20)
+		_, err := repo.CreateProduct(ctx, models.Product{ID: "large", Name: "Large", Description: description, Price: 1.0})
+		require.NoError(t, err)
+		_, err = repo.CreateProduct(ctx, models.Product{ID: "after", Name: "After", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		require.NoError(t, repo.Close())
+
+		reopened := openFileRepository(t, dir, 100)
+		large, err := reopened.GetProductByID(ctx, "large")
+		require.NoError(t, err)
+		assert.Len(t, large.Description, len(description))
+		_, err = reopened.GetProductByID(ctx, "after")
+		assert.NoError(t, err)
+	})
+
+	// Test that concurrent writers are serialised into the log
+	t.Run("ConcurrentWrites", func(t *testing.T) {
+		dir := t.TempDir()
+		repo := openFileRepository(t, dir, 10)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				repo.CreateProduct(ctx, models.Product{Name: fmt.Sprintf("Product %d", i), Description: "Test Description", Price: 1.0})
+			}(i)
+		}
+		wg.Wait()
+		require.NoError(t, repo.Close())
+
+		reopened := openFileRepository(t, dir, 10)
+		products, err := reopened.GetProducts(ctx)
+		require.NoError(t, err)
+		assert.Len(t, products, 50)
+	})
+
+	// Test that reservations and confirmations survive restarts and compaction
+	t.Run("Reservations", func(t *testing.T) {
+		dir := t.TempDir()
+		repo := openFileRepository(t, dir, 4)
+
+		product, err := repo.CreateProduct(ctx, models.Product{Name: "Held", Description: "Test Description", Price: 1.0, InventoryCount: 10})
+		require.NoError(t, err)
+		held, err := repo.ReserveInventory(ctx, product.ID, 2, time.Hour)
+		require.NoError(t, err)
+		confirmed, err := repo.ReserveInventory(ctx, product.ID, 3, time.Hour)
+		require.NoError(t, err)
+		_, err = repo.ConfirmReservation(ctx, product.ID, confirmed.ID)
+		require.NoError(t, err)
+
+		// The fourth record triggered a snapshot; reopen from it
+		assert.FileExists(t, filepath.Join(dir, snapshotFileName))
+		require.NoError(t, repo.Close())
+		reopened := openFileRepository(t, dir, 4)
+
+		availability, err := reopened.CheckProductAvailability(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 7, availability.OnHand)
+		assert.Equal(t, 2, availability.Reserved)
+
+		// Replay a release from the WAL on top of the snapshot
+		_, err = reopened.ReleaseReservation(ctx, product.ID, held.ID)
+		require.NoError(t, err)
+		require.NoError(t, reopened.Close())
+
+		again := openFileRepository(t, dir, 4)
+		_, err = again.GetReservation(ctx, product.ID, held.ID)
+		assert.ErrorIs(t, err, ErrReservationNotFound)
+	})
+
+	// Test that a bulk write is one WAL record and replays in full
+	t.Run("BulkWrite", func(t *testing.T) {
+		dir := t.TempDir()
+		repo := openFileRepository(t, dir, 100)
+
+		ops := make([]BulkOperation, 10)
+		for i := range ops {
+			ops[i] = BulkOperation{Op: BulkCreate, Product: models.Product{Name: fmt.Sprintf("Bulk %d", i), Description: "Test Description", Price: 1.0}}
+		}
+		_, err := repo.BulkWrite(ctx, ops, true)
+		require.NoError(t, err)
+		assert.Equal(t, 1, repo.records)
+		require.NoError(t, repo.Close())
+
+		reopened := openFileRepository(t, dir, 100)
+		products, err := reopened.GetProducts(ctx)
+		require.NoError(t, err)
+		assert.Len(t, products, 10)
+	})
+
+	// Test that history survives compaction and outlives a delete
+	t.Run("History", func(t *testing.T) {
+		dir := t.TempDir()
+		repo := openFileRepository(t, dir, 2)
+		ctx := WithActor(ctx, "alice")
+
+		product, err := repo.CreateProduct(ctx, models.Product{Name: "Tracked", Description: "Test Description", Price: 1.0, InventoryCount: 1})
+		require.NoError(t, err)
+		product.Price = 2.0
+		_, err = repo.UpdateProduct(ctx, product)
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteProduct(ctx, product.ID, 0))
+		require.NoError(t, repo.Close())
+
+		reopened := openFileRepository(t, dir, 2)
+		history, err := reopened.GetProductHistory(ctx, product.ID)
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		assert.Equal(t, models.RevisionDeleted, history[2].Kind)
+		assert.Equal(t, "alice", history[2].Actor)
+		assert.NotNil(t, history[2].Product.DeletedAt)
+
+		restored, err := reopened.RestoreProduct(ctx, product.ID, 2, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2.0, restored.Price)
+		assert.Equal(t, 1, restored.InventoryCount)
+		assert.Equal(t, int64(4), restored.Version)
+		assert.Nil(t, restored.DeletedAt)
+	})
+
+	// Test that the outbox and acknowledgements survive a restart
+	t.Run("Outbox", func(t *testing.T) {
+		dir := t.TempDir()
+		repo := openFileRepository(t, dir, 3)
+
+		product, err := repo.CreateProduct(ctx, models.Product{Name: "Evented", Description: "Test Description", Price: 1.0, InventoryCount: 1})
+		require.NoError(t, err)
+		product.Price = 2.0
+		_, err = repo.UpdateProduct(ctx, product)
+		require.NoError(t, err)
+		events, err := repo.PendingEvents(ctx, 1)
+		require.NoError(t, err)
+		require.NoError(t, repo.AckEvents(ctx, events))
+		require.NoError(t, repo.DeleteProduct(ctx, product.ID, 0))
+		require.NoError(t, repo.Close())
+
+		reopened := openFileRepository(t, dir, 3)
+		pending, err := reopened.PendingEvents(ctx, 100)
+		require.NoError(t, err)
+		types := make([]string, len(pending))
+		for i, event := range pending {
+			types[i] = event.Type
+		}
+		assert.Equal(t, []string{models.EventProductUpdated, models.EventPriceChanged, models.EventProductDeleted}, types)
+	})
+
+	// Test that writes fail once the repository is closed
+	t.Run("WriteAfterClose", func(t *testing.T) {
+		repo := openFileRepository(t, t.TempDir(), 100)
+		require.NoError(t, repo.Close())
+
+		_, err := repo.CreateProduct(ctx, models.Product{Name: "Too Late", Description: "Test Description", Price: 1.0})
+		assert.Error(t, err)
+	})
+}
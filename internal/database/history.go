@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/yourusername/product-service/internal/models"
+)
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx naming who is making the changes, which is
+// recorded in the revisions they produce
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorFrom returns the actor set by WithActor, if any
+func actorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// newRevision describes the change from previous to product, appended to
+// history. previous is nil when the product did not exist.
+func newRevision(history []models.Revision, previous *models.Product, product models.Product, actor string, restoredFrom int64) models.Revision {
+	revision := models.Revision{
+		Seq:          1,
+		ProductID:    product.ID,
+		Actor:        actor,
+		Timestamp:    product.UpdatedAt,
+		RestoredFrom: restoredFrom,
+		Product:      &product,
+	}
+	if len(history) > 0 {
+		revision.Seq = history[len(history)-1].Seq + 1
+	}
+
+	switch {
+	case previous == nil:
+		revision.Kind = models.RevisionCreated
+	case isDeleted(product) && !isDeleted(*previous):
+		revision.Kind = models.RevisionDeleted
+	case restoredFrom != 0 || isDeleted(*previous):
+		revision.Kind = models.RevisionRestored
+	default:
+		revision.Kind = models.RevisionUpdated
+	}
+	if previous != nil {
+		revision.Changes = changedFields(*previous, product)
+	}
+
+	return revision
+}
+
+// changedFields lists the client-editable fields that differ between before and after
+func changedFields(before, after models.Product) []string {
+	var changes []string
+	if before.Name != after.Name {
+		changes = append(changes, "name")
+	}
+	if before.Description != after.Description {
+		changes = append(changes, "description")
+	}
+	if before.Price != after.Price {
+		changes = append(changes, "price")
+	}
+	if before.InventoryCount != after.InventoryCount {
+		changes = append(changes, "inventoryCount")
+	}
+	return changes
+}
+
+// productAsOf returns the product as it stood at at according to history.
+// ErrNotFound is returned if it did not exist then or had been deleted.
+func productAsOf(history []models.Revision, at time.Time) (models.Product, error) {
+	var latest *models.Revision
+	for i := range history {
+		// Clocks on different writers can disagree, so do not assume order
+		revision := &history[i]
+		if !revision.Timestamp.After(at) && (latest == nil || revision.Seq > latest.Seq) {
+			latest = revision
+		}
+	}
+	if latest == nil || isDeleted(*latest.Product) {
+		return models.Product{}, ErrNotFound
+	}
+
+	return *latest.Product, nil
+}
+
+// restoreRevision builds the product written by restoring revision seq over
+// current, taking it out of the trash if need be. Stock is left as it is
+// rather than rewound past the sales and reservations made since.
+func restoreRevision(history []models.Revision, seq int64, current models.Product, now time.Time) (models.Product, error) {
+	var product *models.Product
+	for _, revision := range history {
+		if revision.Seq == seq && !isDeleted(*revision.Product) {
+			product = revision.Product
+		}
+	}
+	if product == nil {
+		return models.Product{}, ErrRevisionNotFound
+	}
+
+	restored := *product
+	restored.InventoryCount = current.InventoryCount
+	restored.CreatedAt = current.CreatedAt
+	restored.UpdatedAt = now
+	restored.Version = current.Version + 1
+
+	return restored, nil
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// adjustInventory applies adjustment to product. The result may not drop below
+// the quantity currently held by reservations (and so never below zero).
+func adjustInventory(product *models.Product, reserved int, adjustment models.InventoryAdjustment) error {
+	count := product.InventoryCount + adjustment.Delta
+	if count < reserved || count < 0 {
+		return fmt.Errorf("%w: %d in stock with %d reserved, adjustment of %d",
+			ErrInsufficientInventory, product.InventoryCount, reserved, adjustment.Delta)
+	}
+
+	product.InventoryCount = count
+	return nil
+}
+
+// checkHeld refuses a write that would leave product with less stock than its
+// unexpired reservations hold, so confirming them can never go below zero
+func checkHeld(product models.Product, reserved int) error {
+	if product.InventoryCount < reserved {
+		return fmt.Errorf("%w: inventory count of %d with %d reserved",
+			ErrInsufficientInventory, product.InventoryCount, reserved)
+	}
+	return nil
+}
+
+// availabilityOf reports the availability of a stored product given the
+// quantity held by its unexpired reservations
+func availabilityOf(product models.Product, reserved int) models.ProductAvailability {
+	atp := product.InventoryCount - reserved
+	if atp < 0 {
+		// Writes keep the count at or above what is held; clamp regardless
+		atp = 0
+	}
+
+	return models.ProductAvailability{
+		ProductID:          product.ID,
+		InventoryCount:     product.InventoryCount,
+		OnHand:             product.InventoryCount,
+		Reserved:           reserved,
+		AvailableToPromise: atp,
+		IsAvailable:        atp > 0,
+	}
+}
+
+// heldQuantity sums the reservations that have not expired by now
+func heldQuantity(reservations map[string]models.Reservation, now time.Time) int {
+	held := 0
+	for _, reservation := range reservations {
+		if !isExpired(reservation, now) {
+			held += reservation.Quantity
+		}
+	}
+	return held
+}
+
+// isExpired reports whether a hold has lapsed by now
+func isExpired(reservation models.Reservation, now time.Time) bool {
+	return !now.Before(reservation.ExpiresAt)
+}
+
+// reserve creates a hold on quantity of product, refusing to promise more
+// than is on hand and not already reserved
+func reserve(product models.Product, reserved, quantity int, ttl time.Duration, now time.Time) (models.Reservation, error) {
+	if atp := availabilityOf(product, reserved).AvailableToPromise; quantity > atp {
+		return models.Reservation{}, fmt.Errorf("%w: %d available to promise, %d requested",
+			ErrInsufficientInventory, atp, quantity)
+	}
+
+	return models.Reservation{
+		ID:        uuid.New().String(),
+		ProductID: product.ID,
+		Quantity:  quantity,
+		Status:    models.ReservationHeld,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, nil
+}
+
+// confirm takes a held reservation's quantity off product and marks the
+// reservation confirmed
+func confirm(product *models.Product, reservation *models.Reservation, now time.Time) error {
+	if isExpired(*reservation, now) {
+		return ErrReservationExpired
+	}
+	if product.InventoryCount < reservation.Quantity {
+		return fmt.Errorf("%w: %d in stock, %d to confirm",
+			ErrInsufficientInventory, product.InventoryCount, reservation.Quantity)
+	}
+
+	product.InventoryCount -= reservation.Quantity
+	product.Version++
+	product.UpdatedAt = now
+	reservation.Status = models.ReservationConfirmed
+
+	return nil
+}
+
+// release reports the final status of a hold that is being removed
+func release(reservation models.Reservation, now time.Time) models.Reservation {
+	reservation.Status = models.ReservationReleased
+	if isExpired(reservation, now) {
+		reservation.Status = models.ReservationExpired
+	}
+	return reservation
+}
+
+// withCurrentStatus marks a stored hold as expired once its time has passed
+func withCurrentStatus(reservation models.Reservation, now time.Time) models.Reservation {
+	if isExpired(reservation, now) {
+		reservation.Status = models.ReservationExpired
+	}
+	return reservation
+}
@@ -2,7 +2,7 @@ package database
 
 import (
 	"context"
-	"errors"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,47 +13,179 @@ import (
 // ProductRepository defines the interface for product database operations
 type ProductRepository interface {
 	GetProducts(ctx context.Context) ([]models.Product, error)
+	CountProducts(ctx context.Context) (int, error)
+	QueryProducts(ctx context.Context, query ProductQuery) (ProductPage, error)
 	GetProductByID(ctx context.Context, id string) (models.Product, error)
 	CreateProduct(ctx context.Context, product models.Product) (models.Product, error)
-	UpdateProduct(ctx context.Context, product models.Product) error
-	DeleteProduct(ctx context.Context, id string) error
+	UpdateProduct(ctx context.Context, product models.Product) (models.Product, error)
+	PatchProduct(ctx context.Context, id string, version int64, patch func(models.Product) (models.Product, error)) (models.Product, error)
+	DeleteProduct(ctx context.Context, id string, version int64) error
+	BulkWrite(ctx context.Context, ops []BulkOperation, atomic bool) ([]BulkResult, error)
 	CheckProductAvailability(ctx context.Context, id string) (models.ProductAvailability, error)
+	CheckProductsAvailability(ctx context.Context, ids []string) (map[string]models.ProductAvailability, error)
+	AdjustInventory(ctx context.Context, id string, adjustment models.InventoryAdjustment) (models.ProductAvailability, error)
+	ReserveInventory(ctx context.Context, productID string, quantity int, ttl time.Duration) (models.Reservation, error)
+	GetReservation(ctx context.Context, productID, reservationID string) (models.Reservation, error)
+	ConfirmReservation(ctx context.Context, productID, reservationID string) (models.Reservation, error)
+	ReleaseReservation(ctx context.Context, productID, reservationID string) (models.Reservation, error)
+	ExpireReservations(ctx context.Context, now time.Time) (int, error)
+	UndeleteProduct(ctx context.Context, id string, version int64) (models.Product, error)
+	PurgeDeletedProducts(ctx context.Context, before time.Time) (int, error)
+	PendingEvents(ctx context.Context, limit int) ([]models.Event, error)
+	AckEvents(ctx context.Context, events []models.Event) error
+	GetProductHistory(ctx context.Context, id string) ([]models.Revision, error)
+	GetProductAsOf(ctx context.Context, id string, at time.Time) (models.Product, error)
+	RestoreProduct(ctx context.Context, id string, seq int64, version int64) (models.Product, error)
 }
 
 // InMemoryRepository implements ProductRepository using in-memory storage
 type InMemoryRepository struct {
-	products map[string]models.Product
-	mutex    sync.RWMutex
+	products     map[string]models.Product
+	reservations map[string]map[string]models.Reservation // held reservations by product ID
+	history      map[string][]models.Revision // revisions by product ID, until purged
+	outbox       []models.Event // undelivered domain events, oldest first
+	mutex        sync.RWMutex
+	journal      journal
 }
 
+// mutation describes a single change to the repository state. Actor is
+// stamped by commit and ends up in the revision a product change records.
+type mutation struct {
+	Op           string              `json:"op"`
+	ID           string              `json:"id"`
+	Product      *models.Product     `json:"product,omitempty"`
+	Reservation  *models.Reservation `json:"reservation,omitempty"`
+	Actor        string              `json:"actor,omitempty"`
+	RestoredFrom int64               `json:"restoredFrom,omitempty"`
+	EventIDs     []string            `json:"eventIds,omitempty"`
+}
+
+const (
+	opPut               = "put"
+	opDelete            = "delete"
+	opPutReservation    = "putReservation"
+	opDeleteReservation = "deleteReservation"
+	opAckEvents         = "ackEvents"
+)
+
+// journal makes InMemoryRepository mutations durable. Both methods are
+// called with the repository's write lock held.
+type journal interface {
+	// record persists ms as one atomic unit; they are only applied if it succeeds
+	record(ms []mutation) error
+	// checkpoint runs after ms have been applied and may compact the journal
+	checkpoint()
+}
+
+// scanCheckInterval is how many products a scan visits between checks of
+// the caller's context
+const scanCheckInterval = 256
+
 // NewInMemoryRepository creates a new in-memory repository
 func NewInMemoryRepository() *InMemoryRepository {
 	return &InMemoryRepository{
-		products: make(map[string]models.Product),
+		products:     make(map[string]models.Product),
+		reservations: make(map[string]map[string]models.Reservation),
+		history:      make(map[string][]models.Revision),
 	}
 }
 
 // GetProducts retrieves all products from the in-memory store
 func (r *InMemoryRepository) GetProducts(ctx context.Context) ([]models.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 	
 	products := make([]models.Product, 0, len(r.products))
 	for _, product := range r.products {
+		if len(products)%scanCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if isDeleted(product) {
+			continue
+		}
 		products = append(products, product)
 	}
 	
+	// Return products in creation order rather than map iteration order
+	order := ProductQuery{}.normalize().Sort
+	sort.Slice(products, func(i, j int) bool {
+		return compareProducts(products[i], products[j], order) < 0
+	})
+	
 	return products, nil
 }
 
+// CountProducts returns how many products are not in the trash
+func (r *InMemoryRepository) CountProducts(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	
+	count := 0
+	for _, product := range r.products {
+		if !isDeleted(product) {
+			count++
+		}
+	}
+	
+	return count, nil
+}
+
+// QueryProducts returns one page of products matching the query, using the
+// sort key of the last product on the previous page as the cursor
+func (r *InMemoryRepository) QueryProducts(ctx context.Context, query ProductQuery) (ProductPage, error) {
+	query = query.normalize()
+	
+	after, err := cursorAfter(query)
+	if err != nil {
+		return ProductPage{}, err
+	}
+	
+	if err := ctx.Err(); err != nil {
+		return ProductPage{}, err
+	}
+	
+	r.mutex.RLock()
+	matches := make([]models.Product, 0)
+	scanned := 0
+	for _, product := range r.products {
+		scanned++
+		if scanned%scanCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				r.mutex.RUnlock()
+				return ProductPage{}, err
+			}
+		}
+		if query.Filter.Matches(product) {
+			matches = append(matches, product)
+		}
+	}
+	r.mutex.RUnlock()
+	
+	return keysetPage(matches, query, after), nil
+}
+
 // GetProductByID retrieves a product by its ID
 func (r *InMemoryRepository) GetProductByID(ctx context.Context, id string) (models.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Product{}, err
+	}
+	
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 	
-	product, exists := r.products[id]
+	product, exists := r.live(id)
 	if !exists {
-		return models.Product{}, errors.New("product not found")
+		return models.Product{}, ErrNotFound
 	}
 	
 	return product, nil
@@ -61,6 +193,10 @@ func (r *InMemoryRepository) GetProductByID(ctx context.Context, id string) (mod
 
 // CreateProduct creates a new product in the in-memory store
 func (r *InMemoryRepository) CreateProduct(ctx context.Context, product models.Product) (models.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Product{}, err
+	}
+	
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	
@@ -71,72 +207,651 @@ func (r *InMemoryRepository) CreateProduct(ctx context.Context, product models.P
 	
 	// Check if product with the same ID already exists
 	if _, exists := r.products[product.ID]; exists {
-		return models.Product{}, errors.New("product with this ID already exists")
+		return models.Product{}, ErrConflict
 	}
 	
-	// Set timestamps
+	// Set timestamps and the initial version
 	now := time.Now()
 	product.CreatedAt = now
 	product.UpdatedAt = now
+	product.Version = 1
+	product.DeletedAt = nil
 	
 	// Store the product
-	r.products[product.ID] = product
+	if err := r.commit(ctx, mutation{Op: opPut, ID: product.ID, Product: &product}); err != nil {
+		return models.Product{}, err
+	}
 	
 	return product, nil
 }
 
-// UpdateProduct updates an existing product
-func (r *InMemoryRepository) UpdateProduct(ctx context.Context, product models.Product) error {
+// UpdateProduct updates an existing product and returns the stored result.
+// When product.Version is non-zero it must match the stored version, otherwise
+// ErrPreconditionFailed is returned; the check and the write happen under one lock.
+func (r *InMemoryRepository) UpdateProduct(ctx context.Context, product models.Product) (models.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Product{}, err
+	}
+	
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	
 	// Check if product exists
-	_, exists := r.products[product.ID]
+	existing, exists := r.live(product.ID)
 	if !exists {
-		return errors.New("product not found")
+		return models.Product{}, ErrNotFound
+	}
+	
+	// Compare-and-swap on the version
+	if product.Version != 0 && product.Version != existing.Version {
+		return models.Product{}, ErrPreconditionFailed
 	}
 	
-	// Update timestamp
-	product.UpdatedAt = time.Now()
+	// Update version and timestamps
+	now := time.Now()
+	product.DeletedAt = nil
+	product.Version = existing.Version + 1
+	product.CreatedAt = existing.CreatedAt
+	product.UpdatedAt = now
+	if err := checkHeld(product, heldQuantity(r.reservations[product.ID], now)); err != nil {
+		return models.Product{}, err
+	}
 	
 	// Update the product
-	r.products[product.ID] = product
+	if err := r.commit(ctx, mutation{Op: opPut, ID: product.ID, Product: &product}); err != nil {
+		return models.Product{}, err
+	}
 	
-	return nil
+	return product, nil
+}
+
+// PatchProduct replaces a product with the result of calling patch on its
+// current state. patch runs under the write lock, so nothing can change the
+// product in between; an error from patch aborts the update and is returned
+// as is. When version is non-zero it must match the stored version.
+func (r *InMemoryRepository) PatchProduct(ctx context.Context, id string, version int64, patch func(models.Product) (models.Product, error)) (models.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Product{}, err
+	}
+	
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	
+	existing, exists := r.live(id)
+	if !exists {
+		return models.Product{}, ErrNotFound
+	}
+	if version != 0 && version != existing.Version {
+		return models.Product{}, ErrPreconditionFailed
+	}
+	
+	now := time.Now()
+	product, err := patchProduct(existing, patch, now)
+	if err != nil {
+		return models.Product{}, err
+	}
+	if err := checkHeld(product, heldQuantity(r.reservations[id], now)); err != nil {
+		return models.Product{}, err
+	}
+	
+	if err := r.commit(ctx, mutation{Op: opPut, ID: id, Product: &product}); err != nil {
+		return models.Product{}, err
+	}
+	
+	return product, nil
 }
 
-// DeleteProduct deletes a product from the in-memory store
-func (r *InMemoryRepository) DeleteProduct(ctx context.Context, id string) error {
+// DeleteProduct moves a product to the trash and releases its held reservations.
+// When version is non-zero it must match the stored version.
+func (r *InMemoryRepository) DeleteProduct(ctx context.Context, id string, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	
 	// Check if product exists
-	if _, exists := r.products[id]; !exists {
-		return errors.New("product not found")
+	existing, exists := r.live(id)
+	if !exists {
+		return ErrNotFound
 	}
 	
-	// Delete the product
-	delete(r.products, id)
+	// Compare-and-swap on the version
+	if version != 0 && version != existing.Version {
+		return ErrPreconditionFailed
+	}
 	
-	return nil
+	// Tombstone the product
+	return r.commit(ctx, r.trash(tombstone(existing, time.Now()))...)
+}
+
+// BulkWrite applies ops in order under a single lock and journals the ones
+// that succeed as one unit. Later operations see the effect of earlier ones.
+// When atomic is set, a single failure aborts the whole batch and the other
+// operations report ErrBatchAborted. The returned error is only set when
+// nothing could be applied at all.
+func (r *InMemoryRepository) BulkWrite(ctx context.Context, ops []BulkOperation, atomic bool) ([]BulkResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	
+	// Products written earlier in the batch, including trashed ones
+	staged := make(map[string]models.Product)
+	lookup := func(id string) (models.Product, bool) {
+		if product, ok := staged[id]; ok {
+			return product, true
+		}
+		product, ok := r.products[id]
+		return product, ok
+	}
+	
+	now := time.Now()
+	results := make([]BulkResult, len(ops))
+	mutations := make([]mutation, 0, len(ops))
+	failed := false
+	for i, op := range ops {
+		existing, exists := lookup(op.Product.ID)
+		product, err := stageBulkOperation(op, existing, exists, now)
+		if err == nil && op.Op != BulkDelete {
+			err = checkHeld(product, heldQuantity(r.reservations[product.ID], now))
+		}
+		if err != nil {
+			results[i].Err = err
+			failed = true
+			continue
+		}
+		
+		staged[product.ID] = product
+		if op.Op == BulkDelete {
+			mutations = append(mutations, r.trash(product)...)
+			continue
+		}
+		mutations = append(mutations, mutation{Op: opPut, ID: product.ID, Product: &product})
+		results[i].Product = product
+	}
+	
+	if atomic && failed {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i] = BulkResult{Err: ErrBatchAborted}
+			}
+		}
+		return results, nil
+	}
+	
+	if len(mutations) > 0 {
+		if err := r.commit(ctx, mutations...); err != nil {
+			return nil, err
+		}
+	}
+	
+	return results, nil
 }
 
 // CheckProductAvailability checks if a product is available
 func (r *InMemoryRepository) CheckProductAvailability(ctx context.Context, id string) (models.ProductAvailability, error) {
-	product, err := r.GetProductByID(ctx, id)
-	if err != nil {
+	if err := ctx.Err(); err != nil {
 		return models.ProductAvailability{}, err
 	}
 	
-	availability := models.ProductAvailability{
-		ProductID:      product.ID,
-		InventoryCount: product.InventoryCount,
-		IsAvailable:    product.InventoryCount > 0,
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	
+	product, exists := r.live(id)
+	if !exists {
+		return models.ProductAvailability{}, ErrNotFound
+	}
+	
+	return availabilityOf(product, heldQuantity(r.reservations[id], time.Now())), nil
+}
+
+// CheckProductsAvailability checks several products in one read. Products that
+// do not exist or are in the trash are left out of the result.
+func (r *InMemoryRepository) CheckProductsAvailability(ctx context.Context, ids []string) (map[string]models.ProductAvailability, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	
+	now := time.Now()
+	availability := make(map[string]models.ProductAvailability, len(ids))
+	for _, id := range ids {
+		if product, exists := r.live(id); exists {
+			availability[id] = availabilityOf(product, heldQuantity(r.reservations[id], now))
+		}
 	}
 	
 	return availability, nil
 }
 
+// AdjustInventory applies a signed delta to a product's inventory count under
+// the write lock, so concurrent adjustments never lose updates
+func (r *InMemoryRepository) AdjustInventory(ctx context.Context, id string, adjustment models.InventoryAdjustment) (models.ProductAvailability, error) {
+	if err := ctx.Err(); err != nil {
+		return models.ProductAvailability{}, err
+	}
+	
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	
+	product, exists := r.live(id)
+	if !exists {
+		return models.ProductAvailability{}, ErrNotFound
+	}
+	
+	now := time.Now()
+	reserved := heldQuantity(r.reservations[id], now)
+	if err := adjustInventory(&product, reserved, adjustment); err != nil {
+		return models.ProductAvailability{}, err
+	}
+	product.Version++
+	product.UpdatedAt = now
+	
+	if err := r.commit(ctx, mutation{Op: opPut, ID: id, Product: &product}); err != nil {
+		return models.ProductAvailability{}, err
+	}
+	
+	return availabilityOf(product, reserved), nil
+}
+
+// ReserveInventory holds quantity of a product's available-to-promise stock
+// until ttl has passed
+func (r *InMemoryRepository) ReserveInventory(ctx context.Context, productID string, quantity int, ttl time.Duration) (models.Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Reservation{}, err
+	}
+	
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	
+	product, exists := r.live(productID)
+	if !exists {
+		return models.Reservation{}, ErrNotFound
+	}
+	
+	now := time.Now()
+	reservation, err := reserve(product, heldQuantity(r.reservations[productID], now), quantity, ttl, now)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	
+	if err := r.commit(ctx, mutation{Op: opPutReservation, ID: reservation.ID, Reservation: &reservation}); err != nil {
+		return models.Reservation{}, err
+	}
+	
+	return reservation, nil
+}
+
+// GetReservation retrieves a reservation that is still held. A hold past its
+// expiry that the reaper has not removed yet is reported as expired.
+func (r *InMemoryRepository) GetReservation(ctx context.Context, productID, reservationID string) (models.Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Reservation{}, err
+	}
+	
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	
+	reservation, err := r.findReservation(productID, reservationID)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	
+	return withCurrentStatus(reservation, time.Now()), nil
+}
+
+// ConfirmReservation turns a hold into a sale: the reserved quantity is taken
+// off the product's inventory and the reservation is removed, atomically
+func (r *InMemoryRepository) ConfirmReservation(ctx context.Context, productID, reservationID string) (models.Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Reservation{}, err
+	}
+	
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	
+	reservation, err := r.findReservation(productID, reservationID)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	
+	product := r.products[productID]
+	now := time.Now()
+	if err := confirm(&product, &reservation, now); err != nil {
+		return models.Reservation{}, err
+	}
+	
+	err = r.commit(ctx, 
+		mutation{Op: opPut, ID: productID, Product: &product},
+		mutation{Op: opDeleteReservation, ID: reservationID, Reservation: &reservation},
+	)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	
+	return reservation, nil
+}
+
+// ReleaseReservation gives a held quantity back to available-to-promise
+func (r *InMemoryRepository) ReleaseReservation(ctx context.Context, productID, reservationID string) (models.Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Reservation{}, err
+	}
+	
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	
+	reservation, err := r.findReservation(productID, reservationID)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	
+	if err := r.commit(ctx, mutation{Op: opDeleteReservation, ID: reservationID, Reservation: &reservation}); err != nil {
+		return models.Reservation{}, err
+	}
+	
+	return release(reservation, time.Now()), nil
+}
+
+// ExpireReservations removes every hold that expired at or before now and
+// returns how many were removed
+func (r *InMemoryRepository) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	
+	var expired []mutation
+	for _, held := range r.reservations {
+		for _, reservation := range held {
+			if isExpired(reservation, now) {
+				reservation := reservation
+				expired = append(expired, mutation{Op: opDeleteReservation, ID: reservation.ID, Reservation: &reservation})
+			}
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	
+	if err := r.commit(ctx, expired...); err != nil {
+		return 0, err
+	}
+	
+	return len(expired), nil
+}
+
+// GetProductHistory returns every revision of a product, oldest first. The
+// history of a product in the trash is kept until it is purged.
+func (r *InMemoryRepository) GetProductHistory(ctx context.Context, id string) ([]models.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	
+	history, exists := r.history[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	
+	return append([]models.Revision(nil), history...), nil
+}
+
+// GetProductAsOf returns a product as it stood at the given time
+func (r *InMemoryRepository) GetProductAsOf(ctx context.Context, id string, at time.Time) (models.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Product{}, err
+	}
+	
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	
+	return productAsOf(r.history[id], at)
+}
+
+// RestoreProduct writes the state recorded by revision seq back as a new
+// revision, taking the product out of the trash if it is there. When version
+// is non-zero it must match the stored version.
+func (r *InMemoryRepository) RestoreProduct(ctx context.Context, id string, seq int64, version int64) (models.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Product{}, err
+	}
+	
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	
+	current, exists := r.products[id]
+	if !exists {
+		return models.Product{}, ErrNotFound
+	}
+	if version != 0 && version != current.Version {
+		return models.Product{}, ErrPreconditionFailed
+	}
+	
+	product, err := restoreRevision(r.history[id], seq, current, time.Now())
+	if err != nil {
+		return models.Product{}, err
+	}
+	
+	if err := r.commit(ctx, mutation{Op: opPut, ID: id, Product: &product, RestoredFrom: seq}); err != nil {
+		return models.Product{}, err
+	}
+	
+	return product, nil
+}
+
+// UndeleteProduct takes a product back out of the trash. When version is
+// non-zero it must match the version of the trashed product.
+func (r *InMemoryRepository) UndeleteProduct(ctx context.Context, id string, version int64) (models.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Product{}, err
+	}
+	
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	
+	existing, exists := r.products[id]
+	if !exists || !isDeleted(existing) {
+		return models.Product{}, ErrNotFound
+	}
+	if version != 0 && version != existing.Version {
+		return models.Product{}, ErrPreconditionFailed
+	}
+	
+	product := untombstone(existing, time.Now())
+	if err := r.commit(ctx, mutation{Op: opPut, ID: id, Product: &product}); err != nil {
+		return models.Product{}, err
+	}
+	
+	return product, nil
+}
+
+// PurgeDeletedProducts permanently removes products, and their history, that
+// went into the trash at or before before, and returns how many were removed.
+// A product with undelivered events is kept until they have been delivered.
+func (r *InMemoryRepository) PurgeDeletedProducts(ctx context.Context, before time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	
+	pending := make(map[string]bool)
+	for _, event := range r.outbox {
+		pending[event.ProductID] = true
+	}
+	
+	var purged []mutation
+	for id, product := range r.products {
+		if purgeable(product, before) && !pending[id] {
+			purged = append(purged, mutation{Op: opDelete, ID: id})
+		}
+	}
+	if len(purged) == 0 {
+		return 0, nil
+	}
+	
+	if err := r.commit(ctx, purged...); err != nil {
+		return 0, err
+	}
+	
+	return len(purged), nil
+}
+
+// live looks up a product that is not in the trash. The caller must hold the lock.
+func (r *InMemoryRepository) live(id string) (models.Product, bool) {
+	product, exists := r.products[id]
+	if !exists || isDeleted(product) {
+		return models.Product{}, false
+	}
+	return product, true
+}
+
+// trash returns the mutations that store a tombstoned product and release
+// its held reservations. The caller must hold the lock.
+func (r *InMemoryRepository) trash(product models.Product) []mutation {
+	ms := []mutation{{Op: opPut, ID: product.ID, Product: &product}}
+	for id, reservation := range r.reservations[product.ID] {
+		reservation := reservation
+		ms = append(ms, mutation{Op: opDeleteReservation, ID: id, Reservation: &reservation})
+	}
+	return ms
+}
+
+// PendingEvents returns up to limit undelivered domain events, oldest first
+func (r *InMemoryRepository) PendingEvents(ctx context.Context, limit int) ([]models.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	
+	if limit > len(r.outbox) {
+		limit = len(r.outbox)
+	}
+	
+	return append([]models.Event(nil), r.outbox[:limit]...), nil
+}
+
+// AckEvents removes delivered events from the outbox. Events that are no
+// longer pending are ignored, so acknowledging twice is harmless.
+func (r *InMemoryRepository) AckEvents(ctx context.Context, events []models.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	
+	return r.commit(ctx, mutation{Op: opAckEvents, EventIDs: ids})
+}
+
+// findReservation looks up a held reservation. The caller must hold the lock.
+func (r *InMemoryRepository) findReservation(productID, reservationID string) (models.Reservation, error) {
+	if _, exists := r.live(productID); !exists {
+		return models.Reservation{}, ErrNotFound
+	}
+	
+	reservation, exists := r.reservations[productID][reservationID]
+	if !exists {
+		return models.Reservation{}, ErrReservationNotFound
+	}
+	
+	return reservation, nil
+}
+
+// commit journals mutations (when a journal is attached) and applies them.
+// The mutations are journaled as one unit, so either all or none survive a crash.
+// The caller must hold the write lock.
+func (r *InMemoryRepository) commit(ctx context.Context, ms ...mutation) error {
+	actor := actorFrom(ctx)
+	for i := range ms {
+		ms[i].Actor = actor
+	}
+	
+	if r.journal != nil {
+		if err := r.journal.record(ms); err != nil {
+			return err
+		}
+	}
+	
+	for _, m := range ms {
+		r.apply(m)
+	}
+	
+	if r.journal != nil {
+		r.journal.checkpoint()
+	}
+	
+	return nil
+}
+
+// apply changes the repository state without journaling. Storing a product
+// appends a revision to its history; removing one (a purge) drops the history.
+func (r *InMemoryRepository) apply(m mutation) {
+	switch m.Op {
+	case opPut:
+		r.record(m)
+		r.products[m.ID] = *m.Product
+	case opDelete:
+		delete(r.products, m.ID)
+		delete(r.reservations, m.ID)
+		delete(r.history, m.ID)
+	case opPutReservation:
+		productID := m.Reservation.ProductID
+		if r.reservations[productID] == nil {
+			r.reservations[productID] = make(map[string]models.Reservation)
+		}
+		r.reservations[productID][m.ID] = *m.Reservation
+	case opDeleteReservation:
+		productID := m.Reservation.ProductID
+		delete(r.reservations[productID], m.ID)
+		if len(r.reservations[productID]) == 0 {
+			delete(r.reservations, productID)
+		}
+	case opAckEvents:
+		acked := make(map[string]bool, len(m.EventIDs))
+		for _, id := range m.EventIDs {
+			acked[id] = true
+		}
+		r.outbox = withoutEvents(r.outbox, acked)
+	}
+}
+
+// record appends the revision storing m.Product produces to its history and
+// the domain events it raises to the outbox
+func (r *InMemoryRepository) record(m mutation) {
+	var previous *models.Product
+	if product, exists := r.products[m.ID]; exists {
+		previous = &product
+	}
+	
+	history := r.history[m.ID]
+	revision := newRevision(history, previous, *m.Product, m.Actor, m.RestoredFrom)
+	r.history[m.ID] = append(history, revision)
+	r.outbox = append(r.outbox, productEvents(previous, revision)...)
+}
+
 // SampleProduct creates a sample product for testing
 func SampleProduct(name, description string, price float64, inventory int) models.Product {
 	return models.Product{
@@ -149,3 +864,20 @@ func SampleProduct(name, description string, price float64, inventory int) model
 		UpdatedAt:      time.Now(),
 	}
 }
+
+// SeedSampleProducts stores a handful of sample products for local testing
+func SeedSampleProducts(ctx context.Context, repo ProductRepository) error {
+	samples := []models.Product{
+		SampleProduct("Laptop", "High-performance laptop", 1299.99, 10),
+		SampleProduct("Smartphone", "Latest smartphone model", 899.99, 15),
+		SampleProduct("Headphones", "Noise-cancelling headphones", 249.99, 20),
+	}
+	
+	for _, product := range samples {
+		if _, err := repo.CreateProduct(ctx, product); err != nil {
+			return err
+		}
+	}
+	
+	return nil
+}
@@ -46,7 +46,7 @@ func TestInMemoryRepository(t *testing.T) {
 		product := products[0]
 		product.Name = "Updated Product"
 
-		err := repo.UpdateProduct(ctx, product)
+		_, err := repo.UpdateProduct(ctx, product)
 		assert.NoError(t, err)
 
 		updatedProduct, _ := repo.GetProductByID(ctx, product.ID)
@@ -58,7 +58,7 @@ func TestInMemoryRepository(t *testing.T) {
 		products, _ := repo.GetProducts(ctx)
 		productID := products[0].ID
 
-		err := repo.DeleteProduct(ctx, productID)
+		err := repo.DeleteProduct(ctx, productID, 0)
 		assert.NoError(t, err)
 
 		_, err = repo.GetProductByID(ctx, productID)
@@ -85,3 +85,9 @@ func TestInMemoryRepository(t *testing.T) {
 		assert.Equal(t, 0, availability.InventoryCount)
 	})
 }
+
+func TestInMemoryRepositoryContract(t *testing.T) {
+	testProductRepository(t, func(t *testing.T) ProductRepository {
+		return NewInMemoryRepository()
+	})
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// productEvents returns the domain events for the change recorded by
+// revision, which followed previous (nil for a create). They are written to
+// the outbox in the same step as the change itself.
+func productEvents(previous *models.Product, revision models.Revision) []models.Event {
+	product := *revision.Product
+	var types []string
+	switch revision.Kind {
+	case models.RevisionCreated:
+		types = append(types, models.EventProductCreated)
+	case models.RevisionDeleted:
+		types = append(types, models.EventProductDeleted)
+	case models.RevisionRestored:
+		types = append(types, models.EventProductRestored)
+	}
+
+	if previous != nil && revision.Kind != models.RevisionDeleted {
+		catalogueChanged := previous.Name != product.Name || previous.Description != product.Description || previous.Price != product.Price
+		if catalogueChanged && revision.Kind == models.RevisionUpdated {
+			types = append(types, models.EventProductUpdated)
+		}
+		if previous.Price != product.Price {
+			types = append(types, models.EventPriceChanged)
+		}
+		if previous.InventoryCount != product.InventoryCount {
+			types = append(types, models.EventInventoryChanged)
+			switch {
+			case previous.InventoryCount > 0 && product.InventoryCount <= 0:
+				types = append(types, models.EventStockDepleted)
+			case previous.InventoryCount <= 0 && product.InventoryCount > 0:
+				types = append(types, models.EventStockReplenished)
+			}
+		}
+	}
+
+	events := make([]models.Event, len(types))
+	for i, eventType := range types {
+		events[i] = models.Event{
+			// Derived from the revision so a replayed write produces the same ID.
+			// Revisions restart at 1 when a purged ID is created again, so the
+			// creation time tells the incarnations of a product apart.
+			ID:         fmt.Sprintf("%s:%d:%d:%s", product.ID, product.CreatedAt.UnixNano(), revision.Seq, eventType),
+			Type:       eventType,
+			ProductID:  product.ID,
+			Version:    product.Version,
+			Revision:   revision.Seq,
+			Actor:      revision.Actor,
+			OccurredAt: revision.Timestamp,
+			Product:    product,
+			Previous:   previous,
+		}
+	}
+
+	return events
+}
+
+// eventIDs collects the IDs of events into a set
+func eventIDs(events []models.Event) map[string]bool {
+	ids := make(map[string]bool, len(events))
+	for _, event := range events {
+		ids[event.ID] = true
+	}
+	return ids
+}
+
+// withoutEvents returns outbox minus the events whose IDs are in acked
+func withoutEvents(outbox []models.Event, acked map[string]bool) []models.Event {
+	kept := outbox[:0:0]
+	for _, event := range outbox {
+		if !acked[event.ID] {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
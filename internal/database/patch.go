@@ -0,0 +1,25 @@
+package database
+
+import (
+	"time"
+
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// patchProduct calls patch on a copy of existing and restores the fields the
+// repository owns: the ID, the creation time, the deletion time and the
+// version, which is bumped.
+func patchProduct(existing models.Product, patch func(models.Product) (models.Product, error), now time.Time) (models.Product, error) {
+	product, err := patch(existing)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	product.ID = existing.ID
+	product.CreatedAt = existing.CreatedAt
+	product.UpdatedAt = now
+	product.Version = existing.Version + 1
+	product.DeletedAt = nil
+
+	return product, nil
+}
@@ -0,0 +1,298 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourusername/product-service/internal/models"
+)
+
+const (
+	// DefaultPageSize is used when a query does not set a limit
+	DefaultPageSize = 50
+
+	// MaxPageSize caps the number of products returned in one page
+	MaxPageSize = 500
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded or
+// was issued for a different sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// sortableFields lists the product fields that can be sorted on, by JSON name
+var sortableFields = map[string]bool{
+	"id":             true,
+	"name":           true,
+	"price":          true,
+	"inventoryCount": true,
+	"createdAt":      true,
+	"updatedAt":      true,
+}
+
+// SortField orders query results by one product field
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// ProductFilter restricts which products a query returns. Nil/empty fields do not filter.
+// Time windows are half-open: After is inclusive, Before is exclusive. Products in
+// the trash are only returned, and then exclusively, when Deleted is set.
+type ProductFilter struct {
+	Deleted       bool
+	MinPrice      *float64
+	MaxPrice      *float64
+	InStock       *bool
+	NamePrefix    string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+}
+
+// ProductQuery describes one page of a filtered, sorted product listing
+type ProductQuery struct {
+	Filter ProductFilter
+	Sort   []SortField
+	Limit  int
+	Cursor string
+}
+
+// ProductPage is one page of query results. NextCursor is empty on the last page.
+type ProductPage struct {
+	Products   []models.Product
+	NextCursor string
+}
+
+// ParseSort parses a sort expression such as "price,-createdAt".
+// A leading '-' sorts that field in descending order.
+func ParseSort(expr string) ([]SortField, error) {
+	var fields []SortField
+	if strings.TrimSpace(expr) == "" {
+		return fields, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		field := SortField{Field: strings.TrimPrefix(part, "-"), Descending: strings.HasPrefix(part, "-")}
+		if !sortableFields[field.Field] {
+			return nil, fmt.Errorf("cannot sort by %q", field.Field)
+		}
+		if seen[field.Field] {
+			return nil, fmt.Errorf("duplicate sort field %q", field.Field)
+		}
+		seen[field.Field] = true
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// sortExpression formats sort fields back into the "price,-createdAt" form
+func sortExpression(fields []SortField) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field.Field
+		if field.Descending {
+			parts[i] = "-" + field.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// normalize applies defaults: a page size within bounds and a total order
+// that always ends with the product ID so cursors are stable
+func (q ProductQuery) normalize() ProductQuery {
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+
+	sortFields := make([]SortField, 0, len(q.Sort)+1)
+	hasID := false
+	for _, field := range q.Sort {
+		sortFields = append(sortFields, field)
+		if field.Field == "id" {
+			hasID = true
+			break // fields after a unique key never matter
+		}
+	}
+	if len(sortFields) == 0 {
+		sortFields = append(sortFields, SortField{Field: "createdAt"})
+	}
+	if !hasID {
+		sortFields = append(sortFields, SortField{Field: "id"})
+	}
+	q.Sort = sortFields
+
+	return q
+}
+
+// Matches reports whether a product satisfies the filter
+func (f ProductFilter) Matches(product models.Product) bool {
+	if isDeleted(product) != f.Deleted {
+		return false
+	}
+	if f.MinPrice != nil && product.Price < *f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != nil && product.Price > *f.MaxPrice {
+		return false
+	}
+	if f.InStock != nil && (product.InventoryCount > 0) != *f.InStock {
+		return false
+	}
+	if f.NamePrefix != "" && !strings.HasPrefix(product.Name, f.NamePrefix) {
+		return false
+	}
+	if f.CreatedAfter != nil && product.CreatedAt.Before(*f.CreatedAfter) {
+		return false
+	}
+	if f.CreatedBefore != nil && !product.CreatedAt.Before(*f.CreatedBefore) {
+		return false
+	}
+	if f.UpdatedAfter != nil && product.UpdatedAt.Before(*f.UpdatedAfter) {
+		return false
+	}
+	if f.UpdatedBefore != nil && !product.UpdatedAt.Before(*f.UpdatedBefore) {
+		return false
+	}
+	return true
+}
+
+// compareProducts orders two products by the given sort fields
+func compareProducts(a, b models.Product, fields []SortField) int {
+	for _, field := range fields {
+		var c int
+		switch field.Field {
+		case "id":
+			c = strings.Compare(a.ID, b.ID)
+		case "name":
+			c = strings.Compare(a.Name, b.Name)
+		case "price":
+			c = compareOrdered(a.Price, b.Price)
+		case "inventoryCount":
+			c = compareOrdered(a.InventoryCount, b.InventoryCount)
+		case "createdAt":
+			c = compareTimes(a.CreatedAt, b.CreatedAt)
+		case "updatedAt":
+			c = compareTimes(a.UpdatedAt, b.UpdatedAt)
+		}
+		if field.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareOrdered[T int | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// pageCursor is the decoded form of an opaque cursor: the sort key of the last
+// product on the previous page, for keyset pagination
+type pageCursor struct {
+	Sort  string          `json:"s"`
+	After *models.Product `json:"a,omitempty"`
+}
+
+// encodeCursor turns a cursor into an opaque URL-safe string
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses an opaque cursor and checks it belongs to the query's sort order
+func decodeCursor(encoded string, fields []SortField) (pageCursor, error) {
+	var cursor pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if cursor.Sort != sortExpression(fields) {
+		return cursor, fmt.Errorf("%w: cursor was issued for a different sort order", ErrInvalidCursor)
+	}
+	return cursor, nil
+}
+
+// cursorKey keeps only the fields of a product that sorting can look at
+func cursorKey(product models.Product) *models.Product {
+	return &models.Product{
+		ID:             product.ID,
+		Name:           product.Name,
+		Price:          product.Price,
+		InventoryCount: product.InventoryCount,
+		CreatedAt:      product.CreatedAt,
+		UpdatedAt:      product.UpdatedAt,
+	}
+}
+
+// cursorAfter returns the sort key a query's keyset cursor resumes after, or
+// nil on the first page
+func cursorAfter(query ProductQuery) (*models.Product, error) {
+	if query.Cursor == "" {
+		return nil, nil
+	}
+	cursor, err := decodeCursor(query.Cursor, query.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if cursor.After == nil {
+		return nil, ErrInvalidCursor
+	}
+	return cursor.After, nil
+}
+
+// keysetPage sorts products that match a normalized query and returns the
+// page following after, with a cursor to the next page when there is one
+func keysetPage(products []models.Product, query ProductQuery, after *models.Product) ProductPage {
+	matches := make([]models.Product, 0, len(products))
+	for _, product := range products {
+		if after == nil || compareProducts(product, *after, query.Sort) > 0 {
+			matches = append(matches, product)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return compareProducts(matches[i], matches[j], query.Sort) < 0
+	})
+
+	page := ProductPage{Products: matches}
+	if len(matches) > query.Limit {
+		page.Products = matches[:query.Limit]
+		page.NextCursor = encodeCursor(pageCursor{
+			Sort:  sortExpression(query.Sort),
+			After: cursorKey(page.Products[query.Limit-1]),
+		})
+	}
+	return page
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSort(t *testing.T) {
+	// Test ascending and descending fields
+	t.Run("Valid", func(t *testing.T) {
+		fields, err := ParseSort("price, -createdAt")
+		require.NoError(t, err)
+		assert.Equal(t, []SortField{{Field: "price"}, {Field: "createdAt", Descending: true}}, fields)
+		assert.Equal(t, "price,-createdAt", sortExpression(fields))
+	})
+
+	// Test that an empty expression means the default order
+	t.Run("Empty", func(t *testing.T) {
+		fields, err := ParseSort("")
+		require.NoError(t, err)
+		assert.Empty(t, fields)
+	})
+
+	// Test unknown and repeated fields
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ParseSort("description")
+		assert.Error(t, err)
+
+		_, err = ParseSort("price,-price")
+		assert.Error(t, err)
+
+		_, err = ParseSort("price,")
+		assert.Error(t, err)
+	})
+}
+
+func TestProductQueryNormalize(t *testing.T) {
+	// Test defaults
+	query := ProductQuery{}.normalize()
+	assert.Equal(t, DefaultPageSize, query.Limit)
+	assert.Equal(t, "createdAt,id", sortExpression(query.Sort))
+
+	// Test the page size cap and the ID tiebreaker
+	query = ProductQuery{Limit: MaxPageSize + 1, Sort: []SortField{{Field: "price", Descending: true}}}.normalize()
+	assert.Equal(t, MaxPageSize, query.Limit)
+	assert.Equal(t, "-price,id", sortExpression(query.Sort))
+
+	// Test that fields after the ID are dropped
+	query = ProductQuery{Sort: []SortField{{Field: "id"}, {Field: "name"}}}.normalize()
+	assert.Equal(t, "id", sortExpression(query.Sort))
+}
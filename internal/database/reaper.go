@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunReservationReaper releases expired reservations every interval until ctx
+// is done. Failures are logged and retried on the next tick.
+func RunReservationReaper(ctx context.Context, repo ProductRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := repo.ExpireReservations(ctx, time.Now())
+			if err != nil {
+				log.Printf("Warning: releasing expired reservations: %v", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("Released %d expired reservations", expired)
+			}
+		}
+	}
+}
+
+// RunTrashPurger permanently removes products that have been in the trash for
+// longer than retention, checking every interval until ctx is done
+func RunTrashPurger(ctx context.Context, repo ProductRepository, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := repo.PurgeDeletedProducts(ctx, time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("Warning: purging deleted products: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d deleted products", purged)
+			}
+		}
+	}
+}
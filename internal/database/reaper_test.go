@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+func TestRunReservationReaper(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := NewInMemoryRepository()
+	product, err := repo.CreateProduct(ctx, models.Product{Name: "Reaped", Description: "Test Description", Price: 1.0, InventoryCount: 1})
+	require.NoError(t, err)
+	reservation, err := repo.ReserveInventory(ctx, product.ID, 1, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		RunReservationReaper(ctx, repo, 5*time.Millisecond)
+		close(done)
+	}()
+
+	// Test that the lapsed hold is removed in the background
+	assert.Eventually(t, func() bool {
+		_, err := repo.GetReservation(ctx, product.ID, reservation.ID)
+		return err != nil
+	}, time.Second, 5*time.Millisecond)
+
+	// Test that the reaper stops with its context
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reaper did not stop after cancel")
+	}
+}
+
+func TestRunTrashPurger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := NewInMemoryRepository()
+	old, err := repo.CreateProduct(ctx, models.Product{Name: "Old", Description: "Test Description", Price: 1.0, InventoryCount: 1})
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteProduct(ctx, old.ID, 0))
+	// Products are only purged once their events have been delivered
+	events, err := repo.PendingEvents(ctx, 100)
+	require.NoError(t, err)
+	require.NoError(t, repo.AckEvents(ctx, events))
+	kept, err := repo.CreateProduct(ctx, models.Product{Name: "Kept", Description: "Test Description", Price: 1.0, InventoryCount: 1})
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		RunTrashPurger(ctx, repo, 5*time.Millisecond, 0)
+		close(done)
+	}()
+
+	// Test that trashed products past retention are purged and live ones kept
+	assert.Eventually(t, func() bool {
+		_, err := repo.GetProductHistory(ctx, old.ID)
+		return err != nil
+	}, time.Second, 5*time.Millisecond)
+	_, err = repo.GetProductByID(ctx, kept.ID)
+	assert.NoError(t, err)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("purger did not stop after cancel")
+	}
+}
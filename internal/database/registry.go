@@ -0,0 +1,115 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/product-service/internal/config"
+)
+
+// BackendFactory creates a ProductRepository from the service configuration
+type BackendFactory func(cfg *config.Config) (ProductRepository, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BackendFactory{
+		"memory": newMemoryBackend,
+		"cosmos": newCosmosBackend,
+		"file":   newFileBackend,
+	}
+)
+
+// RegisterBackend makes a storage backend available under the given name.
+// Registering a name twice replaces the previous factory.
+func RegisterBackend(name string, factory BackendFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	backends[strings.ToLower(name)] = factory
+}
+
+// Backends returns the names of all registered storage backends in sorted order
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NewRepository creates the repository selected by cfg.StorageBackend. With
+// tenants configured, the backend is created once per tenant from
+// TenantConfig and the tenants are served by a TenantRepository.
+func NewRepository(cfg *config.Config) (ProductRepository, error) {
+	name := strings.ToLower(cfg.StorageBackend)
+
+	backendsMu.RLock()
+	factory, exists := backends[name]
+	backendsMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown storage backend %q (available: %s)", cfg.StorageBackend, strings.Join(Backends(), ", "))
+	}
+
+	if len(cfg.Tenants) == 0 {
+		repo, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("initialising %s storage backend: %w", name, err)
+		}
+		return repo, nil
+	}
+
+	repos := make(map[string]ProductRepository, len(cfg.Tenants))
+	quotas := make(map[string]int, len(cfg.Tenants))
+	for _, tenant := range cfg.Tenants {
+		repo, err := factory(TenantConfig(cfg, tenant))
+		if err != nil {
+			return nil, fmt.Errorf("initialising %s storage backend for tenant %s: %w", name, tenant, err)
+		}
+		repos[tenant] = repo
+		quotas[tenant] = cfg.TenantMaxProducts
+		if quota, exists := cfg.TenantProductQuotas[tenant]; exists {
+			quotas[tenant] = quota
+		}
+	}
+
+	return NewTenantRepository(repos, quotas), nil
+}
+
+// TenantConfig returns the configuration a backend is created with for one
+// tenant: the file backend keeps the tenant in DATA_DIR/tenants/<tenant> and
+// the Cosmos DB backend in the container "<COSMOS_CONTAINER_NAME>-<tenant>".
+func TenantConfig(cfg *config.Config, tenant string) *config.Config {
+	tenantCfg := *cfg
+	tenantCfg.Tenants = nil
+	tenantCfg.DataDir = filepath.Join(cfg.DataDir, "tenants", tenant)
+	tenantCfg.ContainerName = cfg.ContainerName + "-" + tenant
+	return &tenantCfg
+}
+
+// newMemoryBackend creates the non-durable in-memory backend
+func newMemoryBackend(cfg *config.Config) (ProductRepository, error) {
+	return NewInMemoryRepository(), nil
+}
+
+// newFileBackend creates the durable file-backed backend in DATA_DIR
+func newFileBackend(cfg *config.Config) (ProductRepository, error) {
+	return NewFileRepository(cfg.DataDir, cfg.SnapshotInterval)
+}
+
+// newCosmosBackend creates the Cosmos DB backend from COSMOS_DB_URI and friends
+func newCosmosBackend(cfg *config.Config) (ProductRepository, error) {
+	if cfg.CosmosDBURI == "" {
+		return nil, errors.New("COSMOS_DB_URI is required")
+	}
+	return NewCosmosDBRepository(cfg.CosmosDBURI, cfg.DatabaseName, cfg.ContainerName)
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/config"
+)
+
+func TestNewRepository(t *testing.T) {
+	// Test the memory backend
+	t.Run("Memory", func(t *testing.T) {
+		repo, err := NewRepository(&config.Config{StorageBackend: "memory"})
+		require.NoError(t, err)
+		assert.IsType(t, &InMemoryRepository{}, repo)
+	})
+
+	// Test the file backend
+	t.Run("File", func(t *testing.T) {
+		repo, err := NewRepository(&config.Config{StorageBackend: "file", DataDir: t.TempDir(), SnapshotInterval: 10})
+		require.NoError(t, err)
+		assert.IsType(t, &FileRepository{}, repo)
+		repo.(*FileRepository).Close()
+	})
+
+	// Test the cosmos backend against the fake account
+	t.Run("Cosmos", func(t *testing.T) {
+		fake := newFakeCosmos(t)
+		repo, err := NewRepository(&config.Config{
+			StorageBackend: "cosmos",
+			CosmosDBURI:    fake.connectionString(),
+			DatabaseName:   fake.database,
+			ContainerName:  fake.container,
+		})
+		require.NoError(t, err)
+		assert.IsType(t, &CosmosDBRepository{}, repo)
+	})
+
+	// Test that a backend which cannot initialise fails loudly
+	t.Run("CosmosWithoutURI", func(t *testing.T) {
+		_, err := NewRepository(&config.Config{StorageBackend: "cosmos", DatabaseName: "db", ContainerName: "coll"})
+		assert.ErrorContains(t, err, "COSMOS_DB_URI")
+	})
+
+	// Test an unknown backend
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := NewRepository(&config.Config{StorageBackend: "mongo"})
+		assert.ErrorContains(t, err, "unknown storage backend")
+		assert.ErrorContains(t, err, "memory")
+	})
+
+	// Test that each tenant gets its own file backend directory
+	t.Run("Tenants", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, err := NewRepository(&config.Config{
+			StorageBackend:      "file",
+			DataDir:             dir,
+			SnapshotInterval:    10,
+			Tenants:             []string{"brand-a", "brand-b"},
+			TenantMaxProducts:   10,
+			TenantProductQuotas: map[string]int{"brand-b": 1},
+		})
+		require.NoError(t, err)
+		require.IsType(t, &TenantRepository{}, repo)
+		tenants := repo.(*TenantRepository)
+		assert.Equal(t, []string{"brand-a", "brand-b"}, tenants.Tenants())
+		assert.Equal(t, 10, tenants.tenants["brand-a"].quota)
+		assert.Equal(t, 1, tenants.tenants["brand-b"].quota)
+		for _, tenant := range tenants.Tenants() {
+			assert.DirExists(t, filepath.Join(dir, "tenants", tenant))
+			tenants.tenants[tenant].repo.(*FileRepository).Close()
+		}
+
+		cfg := TenantConfig(&config.Config{DataDir: dir, ContainerName: "products", Tenants: []string{"brand-a"}}, "brand-a")
+		assert.Equal(t, "products-brand-a", cfg.ContainerName)
+		assert.Empty(t, cfg.Tenants)
+	})
+
+	// Test registering a custom backend
+	t.Run("RegisterBackend", func(t *testing.T) {
+		custom := NewInMemoryRepository()
+		RegisterBackend("custom", func(cfg *config.Config) (ProductRepository, error) {
+			return custom, nil
+		})
+		t.Cleanup(func() {
+			backendsMu.Lock()
+			delete(backends, "custom")
+			backendsMu.Unlock()
+		})
+
+		assert.Contains(t, Backends(), "custom")
+		repo, err := NewRepository(&config.Config{StorageBackend: "Custom"})
+		require.NoError(t, err)
+		assert.Same(t, custom, repo)
+	})
+}
+
+func TestSeedSampleProducts(t *testing.T) {
+	repo := NewInMemoryRepository()
+	ctx := context.Background()
+
+	require.NoError(t, SeedSampleProducts(ctx, repo))
+
+	products, err := repo.GetProducts(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, products, 3)
+}
@@ -0,0 +1,1043 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// testProductRepository runs the behaviour every ProductRepository backend must share.
+// newRepo must return an empty repository each time it is called.
+func testProductRepository(t *testing.T, newRepo func(t *testing.T) ProductRepository) {
+	t.Run("CRUD", func(t *testing.T) { testProductCRUD(t, newRepo(t)) })
+	t.Run("Query", func(t *testing.T) { testProductQuery(t, newRepo(t)) })
+	t.Run("Versioning", func(t *testing.T) { testProductVersioning(t, newRepo(t)) })
+	t.Run("Bulk", func(t *testing.T) { testProductBulk(t, newRepo(t)) })
+	t.Run("Inventory", func(t *testing.T) { testProductInventory(t, newRepo(t)) })
+	t.Run("Reservations", func(t *testing.T) { testProductReservations(t, newRepo(t)) })
+	t.Run("Cancellation", func(t *testing.T) { testProductCancellation(t, newRepo(t)) })
+	t.Run("History", func(t *testing.T) { testProductHistory(t, newRepo(t)) })
+	t.Run("Trash", func(t *testing.T) { testProductTrash(t, newRepo(t)) })
+	t.Run("Events", func(t *testing.T) { testProductEvents(t, newRepo(t)) })
+}
+
+// testProductCRUD covers the basic create, read, update and delete operations
+func testProductCRUD(t *testing.T, repo ProductRepository) {
+	ctx := context.Background()
+
+	// Test CreateProduct assigns an ID and timestamps
+	t.Run("CreateProduct", func(t *testing.T) {
+		product := models.Product{Name: "Contract Product", Description: "Test Description", Price: 10.0, InventoryCount: 5}
+		created, err := repo.CreateProduct(ctx, product)
+
+		require.NoError(t, err)
+		assert.NotEmpty(t, created.ID)
+		assert.Equal(t, product.Name, created.Name)
+		assert.False(t, created.CreatedAt.IsZero())
+		assert.False(t, created.UpdatedAt.IsZero())
+	})
+
+	// Test CreateProduct rejects a duplicate ID
+	t.Run("CreateProductDuplicateID", func(t *testing.T) {
+		product := models.Product{ID: "contract-duplicate", Name: "Duplicate", Description: "Test Description", Price: 1.0, InventoryCount: 1}
+		_, err := repo.CreateProduct(ctx, product)
+		require.NoError(t, err)
+
+		_, err = repo.CreateProduct(ctx, product)
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+
+	// Test GetProducts returns every stored product
+	t.Run("GetProducts", func(t *testing.T) {
+		products, err := repo.GetProducts(ctx)
+		require.NoError(t, err)
+		assert.Len(t, products, 2)
+	})
+
+	// Test GetProductByID round-trips the stored fields
+	t.Run("GetProductByID", func(t *testing.T) {
+		product, err := repo.GetProductByID(ctx, "contract-duplicate")
+		require.NoError(t, err)
+		assert.Equal(t, "Duplicate", product.Name)
+		assert.Equal(t, 1.0, product.Price)
+		assert.Equal(t, 1, product.InventoryCount)
+	})
+
+	// Test GetProductByID fails for an unknown ID
+	t.Run("GetProductByIDNotFound", func(t *testing.T) {
+		_, err := repo.GetProductByID(ctx, "does-not-exist")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	// Test UpdateProduct replaces the stored fields
+	t.Run("UpdateProduct", func(t *testing.T) {
+		product, err := repo.GetProductByID(ctx, "contract-duplicate")
+		require.NoError(t, err)
+		product.Name = "Updated Product"
+		product.InventoryCount = 0
+
+		_, err = repo.UpdateProduct(ctx, product)
+		require.NoError(t, err)
+
+		updated, err := repo.GetProductByID(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Updated Product", updated.Name)
+		assert.Equal(t, 0, updated.InventoryCount)
+	})
+
+	// Test UpdateProduct fails for an unknown ID
+	t.Run("UpdateProductNotFound", func(t *testing.T) {
+		_, err := repo.UpdateProduct(ctx, models.Product{ID: "does-not-exist", Name: "Missing"})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	// Test CheckProductAvailability reflects the inventory count
+	t.Run("CheckProductAvailability", func(t *testing.T) {
+		availability, err := repo.CheckProductAvailability(ctx, "contract-duplicate")
+		require.NoError(t, err)
+		assert.Equal(t, "contract-duplicate", availability.ProductID)
+		assert.False(t, availability.IsAvailable)
+		assert.Equal(t, 0, availability.InventoryCount)
+
+		_, err = repo.CheckProductAvailability(ctx, "does-not-exist")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	// Test DeleteProduct removes the product
+	t.Run("DeleteProduct", func(t *testing.T) {
+		require.NoError(t, repo.DeleteProduct(ctx, "contract-duplicate", 0))
+
+		_, err := repo.GetProductByID(ctx, "contract-duplicate")
+		assert.Error(t, err)
+
+		err = repo.DeleteProduct(ctx, "contract-duplicate", 0)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+// testProductQuery covers filtering, sorting and cursor pagination
+func testProductQuery(t *testing.T, repo ProductRepository) {
+	ctx := context.Background()
+
+	seed := []models.Product{
+		{Name: "Apple Laptop", Description: "Test Description", Price: 1000, InventoryCount: 5},
+		{Name: "Apple Phone", Description: "Test Description", Price: 800, InventoryCount: 0},
+		{Name: "Banana Stand", Description: "Test Description", Price: 20, InventoryCount: 3},
+		{Name: "Cherry Pie", Description: "Test Description", Price: 5, InventoryCount: 0},
+		{Name: "Apple Watch", Description: "Test Description", Price: 300, InventoryCount: 2},
+		{Name: "Durian", Description: "Test Description", Price: 50, InventoryCount: 10},
+	}
+	created := make([]models.Product, len(seed))
+	for i, product := range seed {
+		p, err := repo.CreateProduct(ctx, product)
+		require.NoError(t, err)
+		created[i] = p
+		// Keep creation timestamps distinct so time windows are unambiguous
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// queryAll follows cursors until the last page and returns the product names
+	queryAll := func(t *testing.T, query ProductQuery) []string {
+		var names []string
+		for pages := 0; pages < 20; pages++ {
+			page, err := repo.QueryProducts(ctx, query)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(page.Products), query.normalize().Limit)
+			for _, product := range page.Products {
+				names = append(names, product.Name)
+			}
+			if page.NextCursor == "" {
+				return names
+			}
+			query.Cursor = page.NextCursor
+		}
+		t.Fatal("pagination did not terminate")
+		return nil
+	}
+	floatPtr := func(f float64) *float64 { return &f }
+	boolPtr := func(b bool) *bool { return &b }
+
+	// Test the default order is creation order across pages
+	t.Run("DefaultOrder", func(t *testing.T) {
+		names := queryAll(t, ProductQuery{Limit: 4})
+		assert.Equal(t, []string{"Apple Laptop", "Apple Phone", "Banana Stand", "Cherry Pie", "Apple Watch", "Durian"}, names)
+	})
+
+	// Test sorting by a descending field
+	t.Run("SortDescending", func(t *testing.T) {
+		sortFields, err := ParseSort("-price")
+		require.NoError(t, err)
+
+		names := queryAll(t, ProductQuery{Sort: sortFields, Limit: 4})
+		assert.Equal(t, []string{"Apple Laptop", "Apple Phone", "Apple Watch", "Durian", "Banana Stand", "Cherry Pie"}, names)
+	})
+
+	// Test sorting by several fields one item per page
+	t.Run("SortMultipleFields", func(t *testing.T) {
+		sortFields, err := ParseSort("inventoryCount,-name")
+		require.NoError(t, err)
+
+		names := queryAll(t, ProductQuery{Sort: sortFields, Limit: 1})
+		assert.Equal(t, []string{"Cherry Pie", "Apple Phone", "Apple Watch", "Banana Stand", "Apple Laptop", "Durian"}, names)
+	})
+
+	// Test price range filters
+	t.Run("FilterPrice", func(t *testing.T) {
+		names := queryAll(t, ProductQuery{Filter: ProductFilter{MinPrice: floatPtr(50), MaxPrice: floatPtr(900)}})
+		assert.Equal(t, []string{"Apple Phone", "Apple Watch", "Durian"}, names)
+	})
+
+	// Test the in-stock filter both ways
+	t.Run("FilterInStock", func(t *testing.T) {
+		names := queryAll(t, ProductQuery{Filter: ProductFilter{InStock: boolPtr(true)}})
+		assert.Equal(t, []string{"Apple Laptop", "Banana Stand", "Apple Watch", "Durian"}, names)
+
+		names = queryAll(t, ProductQuery{Filter: ProductFilter{InStock: boolPtr(false)}})
+		assert.Equal(t, []string{"Apple Phone", "Cherry Pie"}, names)
+	})
+
+	// Test the name prefix filter combined with sorting and paging
+	t.Run("FilterNamePrefix", func(t *testing.T) {
+		sortFields, err := ParseSort("price")
+		require.NoError(t, err)
+
+		names := queryAll(t, ProductQuery{Filter: ProductFilter{NamePrefix: "Apple"}, Sort: sortFields, Limit: 2})
+		assert.Equal(t, []string{"Apple Watch", "Apple Phone", "Apple Laptop"}, names)
+	})
+
+	// Test the created time window is inclusive at the start and exclusive at the end
+	t.Run("FilterCreatedWindow", func(t *testing.T) {
+		names := queryAll(t, ProductQuery{Filter: ProductFilter{
+			CreatedAfter:  &created[2].CreatedAt,
+			CreatedBefore: &created[4].CreatedAt,
+		}})
+		assert.Equal(t, []string{"Banana Stand", "Cherry Pie"}, names)
+	})
+
+	// Test the updated time window
+	t.Run("FilterUpdatedWindow", func(t *testing.T) {
+		since := time.Now()
+		time.Sleep(2 * time.Millisecond)
+
+		product, err := repo.GetProductByID(ctx, created[3].ID)
+		require.NoError(t, err)
+		product.Price = 6
+		_, err = repo.UpdateProduct(ctx, product)
+		require.NoError(t, err)
+
+		names := queryAll(t, ProductQuery{Filter: ProductFilter{UpdatedAfter: &since}})
+		assert.Equal(t, []string{"Cherry Pie"}, names)
+
+		names = queryAll(t, ProductQuery{Filter: ProductFilter{UpdatedBefore: &since}})
+		assert.Len(t, names, 5)
+	})
+
+	// Test that broken cursors and cursors from another sort order are rejected
+	t.Run("InvalidCursor", func(t *testing.T) {
+		_, err := repo.QueryProducts(ctx, ProductQuery{Cursor: "not a cursor"})
+		assert.True(t, errors.Is(err, ErrInvalidCursor))
+
+		byPrice, err := ParseSort("price")
+		require.NoError(t, err)
+		page, err := repo.QueryProducts(ctx, ProductQuery{Sort: byPrice, Limit: 1})
+		require.NoError(t, err)
+		require.NotEmpty(t, page.NextCursor)
+
+		byName, err := ParseSort("name")
+		require.NoError(t, err)
+		_, err = repo.QueryProducts(ctx, ProductQuery{Sort: byName, Cursor: page.NextCursor})
+		assert.True(t, errors.Is(err, ErrInvalidCursor))
+	})
+}
+
+// testProductVersioning covers versions and compare-and-swap updates and deletes
+func testProductVersioning(t *testing.T, repo ProductRepository) {
+	ctx := context.Background()
+
+	created, err := repo.CreateProduct(ctx, models.Product{Name: "Versioned", Description: "Test Description", Price: 10.0, InventoryCount: 0})
+	require.NoError(t, err)
+
+	// Test that a new product starts at version 1
+	t.Run("InitialVersion", func(t *testing.T) {
+		assert.Equal(t, int64(1), created.Version)
+	})
+
+	// Test that updates bump the version and keep the creation time
+	t.Run("UpdateBumpsVersion", func(t *testing.T) {
+		product := created
+		product.Version = 0
+		product.Name = "Versioned Again"
+
+		updated, err := repo.UpdateProduct(ctx, product)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), updated.Version)
+		assert.True(t, created.CreatedAt.Equal(updated.CreatedAt))
+		assert.False(t, updated.UpdatedAt.Before(created.UpdatedAt))
+
+		stored, err := repo.GetProductByID(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), stored.Version)
+	})
+
+	// Test that a stale version is rejected
+	t.Run("StaleUpdate", func(t *testing.T) {
+		stale := created
+		stale.Name = "Lost Update"
+
+		_, err := repo.UpdateProduct(ctx, stale)
+		assert.True(t, errors.Is(err, ErrPreconditionFailed))
+
+		stored, err := repo.GetProductByID(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Versioned Again", stored.Name)
+	})
+
+	// Test that concurrent read-modify-write cycles never lose an increment
+	t.Run("ConcurrentUpdates", func(t *testing.T) {
+		const writers = 8
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					product, err := repo.GetProductByID(ctx, created.ID)
+					if !assert.NoError(t, err) {
+						return
+					}
+					product.InventoryCount++
+					_, err = repo.UpdateProduct(ctx, product)
+					if errors.Is(err, ErrPreconditionFailed) {
+						continue
+					}
+					assert.NoError(t, err)
+					return
+				}
+			}()
+		}
+		wg.Wait()
+
+		stored, err := repo.GetProductByID(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, writers, stored.InventoryCount)
+		assert.Equal(t, int64(2+writers), stored.Version)
+	})
+
+	// Test that PatchProduct applies the function to the stored product
+	t.Run("PatchProduct", func(t *testing.T) {
+		stored, err := repo.GetProductByID(ctx, created.ID)
+		require.NoError(t, err)
+
+		patched, err := repo.PatchProduct(ctx, created.ID, stored.Version, func(product models.Product) (models.Product, error) {
+			assert.Equal(t, stored.Version, product.Version)
+			product.Name = "Patched"
+			product.ID = "ignored"
+			product.Version = 1000
+			return product, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, patched.ID)
+		assert.Equal(t, "Patched", patched.Name)
+		assert.Equal(t, stored.Version+1, patched.Version)
+		assert.True(t, patched.CreatedAt.Equal(stored.CreatedAt))
+
+		_, err = repo.PatchProduct(ctx, created.ID, stored.Version, func(product models.Product) (models.Product, error) {
+			return product, nil
+		})
+		assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+		// An error from the patch function aborts the write
+		failure := errors.New("invalid patch")
+		_, err = repo.PatchProduct(ctx, created.ID, 0, func(product models.Product) (models.Product, error) {
+			return models.Product{}, failure
+		})
+		assert.ErrorIs(t, err, failure)
+		unchanged, err := repo.GetProductByID(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, patched.Version, unchanged.Version)
+
+		_, err = repo.PatchProduct(ctx, "missing", 0, func(product models.Product) (models.Product, error) {
+			return product, nil
+		})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	// Test that a stale delete is rejected and a current one succeeds
+	t.Run("ConditionalDelete", func(t *testing.T) {
+		err := repo.DeleteProduct(ctx, created.ID, 1)
+		assert.True(t, errors.Is(err, ErrPreconditionFailed))
+
+		stored, err := repo.GetProductByID(ctx, created.ID)
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteProduct(ctx, created.ID, stored.Version))
+
+		_, err = repo.GetProductByID(ctx, created.ID)
+		assert.Error(t, err)
+	})
+}
+
+// testProductBulk covers mixed batches in both best-effort and atomic mode
+func testProductBulk(t *testing.T, repo ProductRepository) {
+	ctx := context.Background()
+	existing, err := repo.CreateProduct(ctx, models.Product{ID: "existing", Name: "Existing", Description: "Test Description", Price: 1.0})
+	require.NoError(t, err)
+	doomed, err := repo.CreateProduct(ctx, models.Product{ID: "doomed", Name: "Doomed", Description: "Test Description", Price: 1.0})
+	require.NoError(t, err)
+
+	// Test that failures only affect their own operation
+	t.Run("BestEffort", func(t *testing.T) {
+		renamed := existing
+		renamed.Name = "Renamed"
+		results, err := repo.BulkWrite(ctx, []BulkOperation{
+			{Op: BulkCreate, Product: models.Product{ID: "bulk-1", Name: "Bulk 1", Description: "Test Description", Price: 2.0}},
+			{Op: BulkUpdate, Product: models.Product{ID: "bulk-1", Name: "Bulk 1 Updated", Description: "Test Description", Price: 3.0}},
+			{Op: BulkUpdate, Product: renamed},
+			{Op: BulkUpdate, Product: models.Product{ID: "missing", Name: "Missing", Description: "Test Description", Price: 1.0}},
+			{Op: BulkCreate, Product: models.Product{ID: "existing", Name: "Duplicate", Description: "Test Description", Price: 1.0}},
+			{Op: BulkDelete, Product: models.Product{ID: doomed.ID}},
+			{Op: BulkUpsert, Product: models.Product{ID: "bulk-1", Name: "Bulk 1 Upserted", Description: "Test Description", Price: 3.0}},
+			{Op: BulkUpsert, Product: models.Product{ID: "bulk-3", Name: "Bulk 3", Description: "Test Description", Price: 3.0}},
+		}, false)
+		require.NoError(t, err)
+		require.Len(t, results, 8)
+
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, int64(1), results[0].Product.Version)
+		assert.NoError(t, results[1].Err)
+		assert.Equal(t, int64(2), results[1].Product.Version)
+		assert.NoError(t, results[2].Err)
+		assert.ErrorIs(t, results[3].Err, ErrNotFound)
+		assert.ErrorIs(t, results[4].Err, ErrConflict)
+		assert.NoError(t, results[5].Err)
+		assert.NoError(t, results[6].Err)
+		assert.Equal(t, int64(3), results[6].Product.Version)
+		assert.NoError(t, results[7].Err)
+		assert.Equal(t, int64(1), results[7].Product.Version)
+
+		stored, err := repo.GetProductByID(ctx, "bulk-1")
+		require.NoError(t, err)
+		assert.Equal(t, "Bulk 1 Upserted", stored.Name)
+		stored, err = repo.GetProductByID(ctx, existing.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Renamed", stored.Name)
+		_, err = repo.GetProductByID(ctx, doomed.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	// Test that one failure in an atomic batch leaves everything untouched
+	t.Run("Atomic", func(t *testing.T) {
+		results, err := repo.BulkWrite(ctx, []BulkOperation{
+			{Op: BulkCreate, Product: models.Product{ID: "bulk-2", Name: "Bulk 2", Description: "Test Description", Price: 2.0}},
+			{Op: BulkDelete, Product: models.Product{ID: existing.ID, Version: 1}},
+		}, true)
+		if errors.Is(err, ErrNotSupported) {
+			t.Skip("backend does not support atomic batches")
+		}
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, ErrBatchAborted)
+		assert.ErrorIs(t, results[1].Err, ErrPreconditionFailed)
+
+		_, err = repo.GetProductByID(ctx, "bulk-2")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = repo.GetProductByID(ctx, existing.ID)
+		assert.NoError(t, err)
+
+		results, err = repo.BulkWrite(ctx, []BulkOperation{
+			{Op: BulkCreate, Product: models.Product{ID: "bulk-2", Name: "Bulk 2", Description: "Test Description", Price: 2.0}},
+			{Op: BulkDelete, Product: models.Product{ID: existing.ID}},
+		}, true)
+		require.NoError(t, err)
+		assert.NoError(t, results[0].Err)
+		assert.NoError(t, results[1].Err)
+		_, err = repo.GetProductByID(ctx, "bulk-2")
+		assert.NoError(t, err)
+	})
+}
+
+// testProductInventory covers atomic inventory adjustments
+func testProductInventory(t *testing.T, repo ProductRepository) {
+	ctx := context.Background()
+	created, err := repo.CreateProduct(ctx, models.Product{Name: "Stocked", Description: "Test Description", Price: 1.0, InventoryCount: 5})
+	require.NoError(t, err)
+
+	// Test that deltas are applied and the version is bumped
+	t.Run("Adjust", func(t *testing.T) {
+		availability, err := repo.AdjustInventory(ctx, created.ID, models.InventoryAdjustment{Delta: 3, Reason: "restock"})
+		require.NoError(t, err)
+		assert.Equal(t, 8, availability.InventoryCount)
+		assert.True(t, availability.IsAvailable)
+
+		availability, err = repo.AdjustInventory(ctx, created.ID, models.InventoryAdjustment{Delta: -8, Reason: "sale"})
+		require.NoError(t, err)
+		assert.Equal(t, 0, availability.InventoryCount)
+		assert.False(t, availability.IsAvailable)
+
+		stored, err := repo.GetProductByID(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), stored.Version)
+		assert.Equal(t, created.Name, stored.Name)
+	})
+
+	// Test that an adjustment below zero is refused and changes nothing
+	t.Run("Insufficient", func(t *testing.T) {
+		_, err := repo.AdjustInventory(ctx, created.ID, models.InventoryAdjustment{Delta: -1, Reason: "sale"})
+		assert.ErrorIs(t, err, ErrInsufficientInventory)
+
+		stored, err := repo.GetProductByID(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, stored.InventoryCount)
+		assert.Equal(t, int64(3), stored.Version)
+	})
+
+	// Test adjusting a product that does not exist
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := repo.AdjustInventory(ctx, "missing", models.InventoryAdjustment{Delta: 1, Reason: "restock"})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	// Test checking several products at once, leaving out missing and trashed ones
+	t.Run("Batch", func(t *testing.T) {
+		other, err := repo.CreateProduct(ctx, models.Product{Name: "Other", Description: "Test Description", Price: 1.0, InventoryCount: 2})
+		require.NoError(t, err)
+		trashed, err := repo.CreateProduct(ctx, models.Product{Name: "Trashed", Description: "Test Description", Price: 1.0, InventoryCount: 2})
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteProduct(ctx, trashed.ID, 0))
+
+		availability, err := repo.CheckProductsAvailability(ctx, []string{created.ID, other.ID, trashed.ID, "missing"})
+		require.NoError(t, err)
+		assert.Len(t, availability, 2)
+		assert.Equal(t, 0, availability[created.ID].InventoryCount)
+		assert.Equal(t, 2, availability[other.ID].AvailableToPromise)
+
+		availability, err = repo.CheckProductsAvailability(ctx, nil)
+		require.NoError(t, err)
+		assert.Empty(t, availability)
+	})
+
+	// Test that concurrent adjustments are not lost
+	t.Run("Concurrent", func(t *testing.T) {
+		const writers = 8
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.AdjustInventory(ctx, created.ID, models.InventoryAdjustment{Delta: 2, Reason: "return"})
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		availability, err := repo.CheckProductAvailability(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2*writers, availability.InventoryCount)
+	})
+}
+
+// testProductReservations covers holding, confirming, releasing and expiring stock
+func testProductReservations(t *testing.T, repo ProductRepository) {
+	ctx := context.Background()
+	created, err := repo.CreateProduct(ctx, models.Product{Name: "Reserved", Description: "Test Description", Price: 1.0, InventoryCount: 10})
+	require.NoError(t, err)
+
+	availability := func(t *testing.T) models.ProductAvailability {
+		availability, err := repo.CheckProductAvailability(ctx, created.ID)
+		require.NoError(t, err)
+		return availability
+	}
+
+	// Test that a hold reduces available-to-promise but not on-hand
+	t.Run("Reserve", func(t *testing.T) {
+		reservation, err := repo.ReserveInventory(ctx, created.ID, 4, time.Hour)
+		require.NoError(t, err)
+		assert.NotEmpty(t, reservation.ID)
+		assert.Equal(t, models.ReservationHeld, reservation.Status)
+
+		fetched, err := repo.GetReservation(ctx, created.ID, reservation.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 4, fetched.Quantity)
+
+		a := availability(t)
+		assert.Equal(t, 10, a.OnHand)
+		assert.Equal(t, 4, a.Reserved)
+		assert.Equal(t, 6, a.AvailableToPromise)
+
+		_, err = repo.ReserveInventory(ctx, created.ID, 7, time.Hour)
+		assert.ErrorIs(t, err, ErrInsufficientInventory)
+
+		// Adjustments may not take on-hand below what is reserved
+		_, err = repo.AdjustInventory(ctx, created.ID, models.InventoryAdjustment{Delta: -7, Reason: "damage"})
+		assert.ErrorIs(t, err, ErrInsufficientInventory)
+
+		_, err = repo.ReleaseReservation(ctx, created.ID, reservation.ID)
+		require.NoError(t, err)
+	})
+
+	// Test that confirming takes the quantity off on-hand and closes the hold
+	t.Run("Confirm", func(t *testing.T) {
+		reservation, err := repo.ReserveInventory(ctx, created.ID, 3, time.Hour)
+		require.NoError(t, err)
+
+		confirmed, err := repo.ConfirmReservation(ctx, created.ID, reservation.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ReservationConfirmed, confirmed.Status)
+
+		a := availability(t)
+		assert.Equal(t, 7, a.OnHand)
+		assert.Equal(t, 0, a.Reserved)
+		assert.Equal(t, 7, a.AvailableToPromise)
+
+		_, err = repo.GetReservation(ctx, created.ID, reservation.ID)
+		assert.ErrorIs(t, err, ErrReservationNotFound)
+		_, err = repo.ConfirmReservation(ctx, created.ID, reservation.ID)
+		assert.ErrorIs(t, err, ErrReservationNotFound)
+	})
+
+	// Test that releasing gives the quantity back
+	t.Run("Release", func(t *testing.T) {
+		reservation, err := repo.ReserveInventory(ctx, created.ID, 2, time.Hour)
+		require.NoError(t, err)
+
+		released, err := repo.ReleaseReservation(ctx, created.ID, reservation.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ReservationReleased, released.Status)
+		assert.Equal(t, 0, availability(t).Reserved)
+		assert.Equal(t, 7, availability(t).OnHand)
+	})
+
+	// Test that a lapsed hold stops counting, cannot be confirmed and is reaped
+	t.Run("Expire", func(t *testing.T) {
+		lapsed, err := repo.ReserveInventory(ctx, created.ID, 5, 10*time.Millisecond)
+		require.NoError(t, err)
+		held, err := repo.ReserveInventory(ctx, created.ID, 1, time.Hour)
+		require.NoError(t, err)
+		time.Sleep(20 * time.Millisecond)
+
+		assert.Equal(t, 1, availability(t).Reserved)
+		fetched, err := repo.GetReservation(ctx, created.ID, lapsed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ReservationExpired, fetched.Status)
+		_, err = repo.ConfirmReservation(ctx, created.ID, lapsed.ID)
+		assert.ErrorIs(t, err, ErrReservationExpired)
+
+		expired, err := repo.ExpireReservations(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		_, err = repo.GetReservation(ctx, created.ID, lapsed.ID)
+		assert.ErrorIs(t, err, ErrReservationNotFound)
+		_, err = repo.GetReservation(ctx, created.ID, held.ID)
+		assert.NoError(t, err)
+	})
+
+	// Test that concurrent holds never promise more than is on hand
+	t.Run("NoOversell", func(t *testing.T) {
+		product, err := repo.CreateProduct(ctx, models.Product{Name: "Scarce", Description: "Test Description", Price: 1.0, InventoryCount: 5})
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		succeeded := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.ReserveInventory(ctx, product.ID, 1, time.Hour)
+				if err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+					return
+				}
+				assert.ErrorIs(t, err, ErrInsufficientInventory)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 5, succeeded)
+	})
+
+	// Test that writes cannot take stock below what is held, so confirming never oversells
+	t.Run("WritesKeepHeld", func(t *testing.T) {
+		product, err := repo.CreateProduct(ctx, models.Product{Name: "Held", Description: "Test Description", Price: 1.0, InventoryCount: 10})
+		require.NoError(t, err)
+		reservation, err := repo.ReserveInventory(ctx, product.ID, 8, time.Hour)
+		require.NoError(t, err)
+
+		lowered := product
+		lowered.InventoryCount = 2
+		_, err = repo.UpdateProduct(ctx, lowered)
+		assert.ErrorIs(t, err, ErrInsufficientInventory)
+		_, err = repo.PatchProduct(ctx, product.ID, 0, func(p models.Product) (models.Product, error) {
+			p.InventoryCount = 2
+			return p, nil
+		})
+		assert.ErrorIs(t, err, ErrInsufficientInventory)
+		results, err := repo.BulkWrite(ctx, []BulkOperation{{Op: BulkUpdate, Product: lowered}}, false)
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, ErrInsufficientInventory)
+
+		lowered.InventoryCount = 8
+		_, err = repo.UpdateProduct(ctx, lowered)
+		require.NoError(t, err)
+		_, err = repo.ConfirmReservation(ctx, product.ID, reservation.ID)
+		require.NoError(t, err)
+
+		fetched, err := repo.GetProductByID(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, fetched.InventoryCount)
+	})
+
+	// Test that reservations go away with their product
+	t.Run("DeleteProduct", func(t *testing.T) {
+		product, err := repo.CreateProduct(ctx, models.Product{Name: "Short Lived", Description: "Test Description", Price: 1.0, InventoryCount: 1})
+		require.NoError(t, err)
+		_, err = repo.ReserveInventory(ctx, product.ID, 1, time.Hour)
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteProduct(ctx, product.ID, 0))
+
+		_, err = repo.ReserveInventory(ctx, product.ID, 1, time.Hour)
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = repo.GetReservation(ctx, product.ID, "any")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+// testProductCancellation checks that a done context stops work before it starts
+func testProductCancellation(t *testing.T, repo ProductRepository) {
+	created, err := repo.CreateProduct(context.Background(), models.Product{Name: "Cancelled", Description: "Test Description", Price: 1.0})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Test that reads and scans return the context error
+	t.Run("Reads", func(t *testing.T) {
+		_, err := repo.GetProducts(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		_, err = repo.QueryProducts(ctx, ProductQuery{})
+		assert.ErrorIs(t, err, context.Canceled)
+
+		_, err = repo.GetProductByID(ctx, created.ID)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	// Test that writes are not applied
+	t.Run("Writes", func(t *testing.T) {
+		_, err := repo.CreateProduct(ctx, models.Product{Name: "Never Stored", Description: "Test Description", Price: 1.0})
+		assert.ErrorIs(t, err, context.Canceled)
+
+		update := created
+		update.Price = 99.0
+		_, err = repo.UpdateProduct(ctx, update)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		assert.ErrorIs(t, repo.DeleteProduct(ctx, created.ID, 0), context.Canceled)
+
+		products, err := repo.GetProducts(context.Background())
+		require.NoError(t, err)
+		require.Len(t, products, 1)
+		assert.Equal(t, created.Price, products[0].Price)
+	})
+
+	// Test that an expired deadline is reported as such
+	t.Run("DeadlineExceeded", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		_, err := repo.GetProducts(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+// testProductHistory covers revision history, point-in-time reads and restores
+func testProductHistory(t *testing.T, repo ProductRepository) {
+	ctx := WithActor(context.Background(), "alice")
+
+	created, err := repo.CreateProduct(ctx, models.Product{Name: "Original", Description: "Test Description", Price: 10.0, InventoryCount: 5})
+	require.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+	renamed := created
+	renamed.Name = "Renamed"
+	renamed.Price = 12.0
+	renamed, err = repo.UpdateProduct(WithActor(context.Background(), "bob"), renamed)
+	require.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+	_, err = repo.AdjustInventory(ctx, created.ID, models.InventoryAdjustment{Delta: -2, Reason: "sale"})
+	require.NoError(t, err)
+
+	// Test that every write is recorded with who made it and what changed
+	t.Run("GetProductHistory", func(t *testing.T) {
+		history, err := repo.GetProductHistory(ctx, created.ID)
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+
+		assert.Equal(t, []int64{1, 2, 3}, []int64{history[0].Seq, history[1].Seq, history[2].Seq})
+		assert.Equal(t, models.RevisionCreated, history[0].Kind)
+		assert.Equal(t, "alice", history[0].Actor)
+		assert.Equal(t, models.RevisionUpdated, history[1].Kind)
+		assert.Equal(t, "bob", history[1].Actor)
+		assert.Equal(t, []string{"name", "price"}, history[1].Changes)
+		assert.Equal(t, []string{"inventoryCount"}, history[2].Changes)
+		assert.Equal(t, int64(3), history[2].Product.Version)
+
+		_, err = repo.GetProductHistory(ctx, "missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	// Test reading the product as it stood at earlier times
+	t.Run("GetProductAsOf", func(t *testing.T) {
+		product, err := repo.GetProductAsOf(ctx, created.ID, created.UpdatedAt)
+		require.NoError(t, err)
+		assert.Equal(t, "Original", product.Name)
+
+		product, err = repo.GetProductAsOf(ctx, created.ID, renamed.UpdatedAt.Add(time.Millisecond))
+		require.NoError(t, err)
+		assert.Equal(t, "Renamed", product.Name)
+		assert.Equal(t, 5, product.InventoryCount)
+
+		_, err = repo.GetProductAsOf(ctx, created.ID, created.CreatedAt.Add(-time.Second))
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	// Test that restoring brings back the catalogue fields but not old stock
+	t.Run("RestoreProduct", func(t *testing.T) {
+		_, err := repo.RestoreProduct(ctx, created.ID, 1, 1)
+		assert.ErrorIs(t, err, ErrPreconditionFailed)
+		_, err = repo.RestoreProduct(ctx, created.ID, 99, 0)
+		assert.ErrorIs(t, err, ErrRevisionNotFound)
+
+		restored, err := repo.RestoreProduct(ctx, created.ID, 1, 3)
+		require.NoError(t, err)
+		assert.Equal(t, "Original", restored.Name)
+		assert.Equal(t, 10.0, restored.Price)
+		assert.Equal(t, 3, restored.InventoryCount)
+		assert.Equal(t, int64(4), restored.Version)
+		assert.Equal(t, created.CreatedAt.UnixMicro(), restored.CreatedAt.UnixMicro())
+
+		history, err := repo.GetProductHistory(ctx, created.ID)
+		require.NoError(t, err)
+		require.Len(t, history, 4)
+		assert.Equal(t, models.RevisionRestored, history[3].Kind)
+		assert.Equal(t, int64(1), history[3].RestoredFrom)
+	})
+}
+
+// testProductTrash covers soft deletes, the trash listing, undelete and purge
+func testProductTrash(t *testing.T, repo ProductRepository) {
+	ctx := context.Background()
+
+	trashed, err := repo.CreateProduct(ctx, models.Product{Name: "Trashed", Description: "Test Description", Price: 1.0, InventoryCount: 4})
+	require.NoError(t, err)
+	_, err = repo.CreateProduct(ctx, models.Product{Name: "Live", Description: "Test Description", Price: 1.0, InventoryCount: 1})
+	require.NoError(t, err)
+	_, err = repo.ReserveInventory(ctx, trashed.ID, 1, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteProduct(ctx, trashed.ID, trashed.Version))
+
+	// Test that a deleted product disappears from reads and writes
+	t.Run("Hidden", func(t *testing.T) {
+		_, err := repo.GetProductByID(ctx, trashed.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		products, err := repo.GetProducts(ctx)
+		require.NoError(t, err)
+		require.Len(t, products, 1)
+		assert.Equal(t, "Live", products[0].Name)
+		count, err := repo.CountProducts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		_, err = repo.UpdateProduct(ctx, trashed)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, repo.DeleteProduct(ctx, trashed.ID, 0), ErrNotFound)
+		_, err = repo.CheckProductAvailability(ctx, trashed.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// The ID stays taken until the product is purged
+		_, err = repo.CreateProduct(ctx, models.Product{ID: trashed.ID, Name: "Reused", Description: "Test Description", Price: 1.0})
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+
+	// Test listing the trash
+	t.Run("Trash", func(t *testing.T) {
+		page, err := repo.QueryProducts(ctx, ProductQuery{Filter: ProductFilter{Deleted: true}})
+		require.NoError(t, err)
+		require.Len(t, page.Products, 1)
+		assert.Equal(t, trashed.ID, page.Products[0].ID)
+		assert.NotNil(t, page.Products[0].DeletedAt)
+		assert.Equal(t, trashed.Version+1, page.Products[0].Version)
+	})
+
+	// Test taking a product back out of the trash
+	t.Run("UndeleteProduct", func(t *testing.T) {
+		_, err := repo.UndeleteProduct(ctx, trashed.ID, trashed.Version)
+		assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+		restored, err := repo.UndeleteProduct(ctx, trashed.ID, trashed.Version+1)
+		require.NoError(t, err)
+		assert.Nil(t, restored.DeletedAt)
+		assert.Equal(t, trashed.Version+2, restored.Version)
+
+		// Its reservation was released by the delete
+		availability, err := repo.CheckProductAvailability(ctx, trashed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, availability.Reserved)
+
+		_, err = repo.UndeleteProduct(ctx, trashed.ID, 0)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		history, err := repo.GetProductHistory(ctx, trashed.ID)
+		require.NoError(t, err)
+		kinds := make([]string, len(history))
+		for i, revision := range history {
+			kinds[i] = revision.Kind
+		}
+		assert.Equal(t, []string{models.RevisionCreated, models.RevisionDeleted, models.RevisionRestored}, kinds)
+	})
+
+	// Test that purging only removes products trashed before the cutoff
+	t.Run("PurgeDeletedProducts", func(t *testing.T) {
+		require.NoError(t, repo.DeleteProduct(ctx, trashed.ID, 0))
+
+		purged, err := repo.PurgeDeletedProducts(ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 0, purged)
+
+		// Its undelivered events hold the product back until they are acknowledged
+		purged, err = repo.PurgeDeletedProducts(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 0, purged)
+		events, err := repo.PendingEvents(ctx, 100)
+		require.NoError(t, err)
+		require.NoError(t, repo.AckEvents(ctx, events))
+
+		purged, err = repo.PurgeDeletedProducts(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+
+		_, err = repo.GetProductHistory(ctx, trashed.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = repo.CreateProduct(ctx, models.Product{ID: trashed.ID, Name: "Reused", Description: "Test Description", Price: 1.0})
+		assert.NoError(t, err)
+	})
+}
+
+func testProductEvents(t *testing.T, repo ProductRepository) {
+	ctx := context.Background()
+
+	product, err := repo.CreateProduct(ctx, models.Product{Name: "Evented", Description: "Test Description", Price: 10.0, InventoryCount: 2})
+	require.NoError(t, err)
+	product.Price = 12.0
+	updated, err := repo.UpdateProduct(ctx, product)
+	require.NoError(t, err)
+	_, err = repo.UpdateProduct(ctx, product)
+	require.ErrorIs(t, err, ErrPreconditionFailed)
+	_, err = repo.AdjustInventory(ctx, product.ID, models.InventoryAdjustment{Delta: -2, Reason: "sale"})
+	require.NoError(t, err)
+	_, err = repo.AdjustInventory(ctx, product.ID, models.InventoryAdjustment{Delta: 3, Reason: "restock"})
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteProduct(ctx, product.ID, 0))
+	_, err = repo.UndeleteProduct(ctx, product.ID, 0)
+	require.NoError(t, err)
+
+	// Test that every change raised its events, in order, and failed writes none
+	t.Run("PendingEvents", func(t *testing.T) {
+		events, err := repo.PendingEvents(ctx, 100)
+		require.NoError(t, err)
+
+		types := make([]string, len(events))
+		ids := make(map[string]bool)
+		for i, event := range events {
+			types[i] = event.Type
+			ids[event.ID] = true
+			assert.Equal(t, product.ID, event.ProductID)
+			assert.Equal(t, event.Version, event.Product.Version)
+		}
+		assert.Equal(t, []string{
+			models.EventProductCreated,
+			models.EventProductUpdated, models.EventPriceChanged,
+			models.EventInventoryChanged, models.EventStockDepleted,
+			models.EventInventoryChanged, models.EventStockReplenished,
+			models.EventProductDeleted,
+			models.EventProductRestored,
+		}, types)
+		assert.Len(t, ids, len(events), "event IDs must be unique")
+
+		priceChanged := events[2]
+		assert.Equal(t, updated.Version, priceChanged.Version)
+		require.NotNil(t, priceChanged.Previous)
+		assert.Equal(t, 10.0, priceChanged.Previous.Price)
+		assert.Equal(t, 12.0, priceChanged.Product.Price)
+		assert.Nil(t, events[0].Previous)
+
+		limited, err := repo.PendingEvents(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, events[:2], limited)
+	})
+
+	// Test that acknowledged events leave the outbox and acking is idempotent
+	t.Run("AckEvents", func(t *testing.T) {
+		events, err := repo.PendingEvents(ctx, 3)
+		require.NoError(t, err)
+		require.NoError(t, repo.AckEvents(ctx, events))
+		require.NoError(t, repo.AckEvents(ctx, events))
+
+		remaining, err := repo.PendingEvents(ctx, 100)
+		require.NoError(t, err)
+		require.Len(t, remaining, 6)
+		assert.Equal(t, models.EventInventoryChanged, remaining[0].Type)
+
+		require.NoError(t, repo.AckEvents(ctx, remaining))
+		remaining, err = repo.PendingEvents(ctx, 100)
+		require.NoError(t, err)
+		assert.Empty(t, remaining)
+
+		// Acknowledging leaves the product itself untouched
+		current, err := repo.GetProductByID(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Equal(t, events[0].ProductID, current.ID)
+		history, err := repo.GetProductHistory(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Equal(t, current.Version, history[len(history)-1].Product.Version)
+	})
+
+	// Test that a product created again under a purged ID raises events with new IDs
+	t.Run("RecreatedAfterPurge", func(t *testing.T) {
+		createdIDs := func() []string {
+			events, err := repo.PendingEvents(ctx, 100)
+			require.NoError(t, err)
+			var ids []string
+			for _, event := range events {
+				if event.ProductID == "reborn" && event.Type == models.EventProductCreated {
+					ids = append(ids, event.ID)
+				}
+			}
+			return ids
+		}
+
+		_, err := repo.CreateProduct(ctx, models.Product{ID: "reborn", Name: "Reborn", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		first := createdIDs()
+		require.Len(t, first, 1)
+
+		// Products are only purged once their events have been delivered
+		require.NoError(t, repo.DeleteProduct(ctx, "reborn", 0))
+		delivered, err := repo.PendingEvents(ctx, 100)
+		require.NoError(t, err)
+		require.NoError(t, repo.AckEvents(ctx, delivered))
+		purged, err := repo.PurgeDeletedProducts(ctx, time.Now().Add(time.Second))
+		require.NoError(t, err)
+		require.Equal(t, 1, purged)
+		_, err = repo.CreateProduct(ctx, models.Product{ID: "reborn", Name: "Reborn", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+
+		ids := createdIDs()
+		require.NotEmpty(t, ids)
+		assert.NotEqual(t, first[0], ids[len(ids)-1])
+	})
+}
@@ -0,0 +1,421 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/tenancy"
+)
+
+// TenantRepository is a ProductRepository serving several tenants, each with
+// its own repository. Calls go to the repository of the tenant named in their
+// context, so no call can reach another tenant's products; a context without
+// a known tenant gets ErrUnknownTenant.
+//
+// The background jobs (ExpireReservations, PurgeDeletedProducts, PendingEvents
+// and AckEvents) run for every tenant when the context names none. Events
+// carry the tenant they were raised in.
+type TenantRepository struct {
+	tenants map[string]*tenantPartition
+	names   []string
+
+	mutex sync.Mutex
+	next  int // tenant PendingEvents starts from, so one tenant cannot starve the others
+}
+
+// tenantPartition is one tenant's repository and product quota. The mutex
+// serialises the writes that can add products, so the quota holds under
+// concurrency within this process.
+type tenantPartition struct {
+	repo  ProductRepository
+	quota int // live products allowed; 0 means unlimited
+	mutex sync.Mutex
+}
+
+// NewTenantRepository serves each tenant in repos. A tenant without a
+// positive quota may hold any number of products.
+func NewTenantRepository(repos map[string]ProductRepository, quotas map[string]int) *TenantRepository {
+	r := &TenantRepository{tenants: make(map[string]*tenantPartition, len(repos))}
+	for name, repo := range repos {
+		r.tenants[name] = &tenantPartition{repo: repo, quota: quotas[name]}
+		r.names = append(r.names, name)
+	}
+	sort.Strings(r.names)
+	return r
+}
+
+// Tenants returns the names of the tenants served, in sorted order
+func (r *TenantRepository) Tenants() []string {
+	return append([]string(nil), r.names...)
+}
+
+// partition returns the partition of the tenant named in ctx
+func (r *TenantRepository) partition(ctx context.Context) (*tenantPartition, error) {
+	tenant := tenancy.FromContext(ctx)
+	p, exists := r.tenants[tenant]
+	if !exists {
+		if tenant == "" {
+			return nil, fmt.Errorf("%w: no tenant given", ErrUnknownTenant)
+		}
+		return nil, fmt.Errorf("%w %q", ErrUnknownTenant, tenant)
+	}
+	return p, nil
+}
+
+// scope returns the tenants a background job runs for: the one named in ctx,
+// or every tenant when there is none
+func (r *TenantRepository) scope(ctx context.Context) ([]string, error) {
+	if tenant := tenancy.FromContext(ctx); tenant != "" {
+		if _, err := r.partition(ctx); err != nil {
+			return nil, err
+		}
+		return []string{tenant}, nil
+	}
+	return r.names, nil
+}
+
+// admit checks that n more products keep a partition within its quota. The
+// caller must hold the partition's mutex.
+func (p *tenantPartition) admit(ctx context.Context, n int) error {
+	if p.quota <= 0 || n <= 0 {
+		return nil
+	}
+	count, err := p.repo.CountProducts(ctx)
+	if err != nil {
+		return err
+	}
+	if count+n > p.quota {
+		return fmt.Errorf("%w: the quota is %d products", ErrQuotaExceeded, p.quota)
+	}
+	return nil
+}
+
+// GetProducts retrieves all of the tenant's products
+func (r *TenantRepository) GetProducts(ctx context.Context) ([]models.Product, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.repo.GetProducts(ctx)
+}
+
+// CountProducts counts the tenant's products that are not in the trash
+func (r *TenantRepository) CountProducts(ctx context.Context) (int, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return p.repo.CountProducts(ctx)
+}
+
+// QueryProducts returns one page of the tenant's products
+func (r *TenantRepository) QueryProducts(ctx context.Context, query ProductQuery) (ProductPage, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return ProductPage{}, err
+	}
+	return p.repo.QueryProducts(ctx, query)
+}
+
+// GetProductByID retrieves one of the tenant's products
+func (r *TenantRepository) GetProductByID(ctx context.Context, id string) (models.Product, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.Product{}, err
+	}
+	return p.repo.GetProductByID(ctx, id)
+}
+
+// CreateProduct creates a product if the tenant is within its quota
+func (r *TenantRepository) CreateProduct(ctx context.Context, product models.Product) (models.Product, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err := p.admit(ctx, 1); err != nil {
+		return models.Product{}, err
+	}
+	return p.repo.CreateProduct(ctx, product)
+}
+
+// UpdateProduct updates one of the tenant's products
+func (r *TenantRepository) UpdateProduct(ctx context.Context, product models.Product) (models.Product, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.Product{}, err
+	}
+	return p.repo.UpdateProduct(ctx, product)
+}
+
+// PatchProduct patches one of the tenant's products
+func (r *TenantRepository) PatchProduct(ctx context.Context, id string, version int64, patch func(models.Product) (models.Product, error)) (models.Product, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.Product{}, err
+	}
+	return p.repo.PatchProduct(ctx, id, version, patch)
+}
+
+// DeleteProduct moves one of the tenant's products to the trash
+func (r *TenantRepository) DeleteProduct(ctx context.Context, id string, version int64) error {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return err
+	}
+	return p.repo.DeleteProduct(ctx, id, version)
+}
+
+// BulkWrite applies a batch to the tenant's products. The whole batch is
+// refused when the products it would create exceed the quota.
+func (r *TenantRepository) BulkWrite(ctx context.Context, ops []BulkOperation, atomic bool) ([]BulkResult, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.quota > 0 {
+		creates := 0
+		for _, op := range ops {
+			switch op.Op {
+			case BulkCreate:
+				creates++
+			case BulkUpsert:
+				if _, err := p.repo.GetProductByID(ctx, op.Product.ID); errors.Is(err, ErrNotFound) {
+					creates++
+				} else if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := p.admit(ctx, creates); err != nil {
+			return nil, err
+		}
+	}
+	return p.repo.BulkWrite(ctx, ops, atomic)
+}
+
+// CheckProductAvailability checks one of the tenant's products
+func (r *TenantRepository) CheckProductAvailability(ctx context.Context, id string) (models.ProductAvailability, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.ProductAvailability{}, err
+	}
+	return p.repo.CheckProductAvailability(ctx, id)
+}
+
+// CheckProductsAvailability checks several of the tenant's products
+func (r *TenantRepository) CheckProductsAvailability(ctx context.Context, ids []string) (map[string]models.ProductAvailability, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.repo.CheckProductsAvailability(ctx, ids)
+}
+
+// AdjustInventory adjusts the inventory of one of the tenant's products
+func (r *TenantRepository) AdjustInventory(ctx context.Context, id string, adjustment models.InventoryAdjustment) (models.ProductAvailability, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.ProductAvailability{}, err
+	}
+	return p.repo.AdjustInventory(ctx, id, adjustment)
+}
+
+// ReserveInventory holds units of one of the tenant's products
+func (r *TenantRepository) ReserveInventory(ctx context.Context, productID string, quantity int, ttl time.Duration) (models.Reservation, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	return p.repo.ReserveInventory(ctx, productID, quantity, ttl)
+}
+
+// GetReservation returns a reservation on one of the tenant's products
+func (r *TenantRepository) GetReservation(ctx context.Context, productID, reservationID string) (models.Reservation, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	return p.repo.GetReservation(ctx, productID, reservationID)
+}
+
+// ConfirmReservation confirms a reservation on one of the tenant's products
+func (r *TenantRepository) ConfirmReservation(ctx context.Context, productID, reservationID string) (models.Reservation, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	return p.repo.ConfirmReservation(ctx, productID, reservationID)
+}
+
+// ReleaseReservation releases a reservation on one of the tenant's products
+func (r *TenantRepository) ReleaseReservation(ctx context.Context, productID, reservationID string) (models.Reservation, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	return p.repo.ReleaseReservation(ctx, productID, reservationID)
+}
+
+// ExpireReservations releases lapsed holds of the tenant in ctx, or of every
+// tenant, and returns how many were released
+func (r *TenantRepository) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	names, err := r.scope(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, name := range names {
+		n, err := r.tenants[name].repo.ExpireReservations(tenancy.WithTenant(ctx, name), now)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("tenant %s: %w", name, err)
+		}
+	}
+	return total, nil
+}
+
+// UndeleteProduct takes one of the tenant's products out of the trash if the
+// tenant is within its quota
+func (r *TenantRepository) UndeleteProduct(ctx context.Context, id string, version int64) (models.Product, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err := p.admit(ctx, 1); err != nil {
+		return models.Product{}, err
+	}
+	return p.repo.UndeleteProduct(ctx, id, version)
+}
+
+// PurgeDeletedProducts purges the trash of the tenant in ctx, or of every
+// tenant, and returns how many products were removed
+func (r *TenantRepository) PurgeDeletedProducts(ctx context.Context, before time.Time) (int, error) {
+	names, err := r.scope(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, name := range names {
+		n, err := r.tenants[name].repo.PurgeDeletedProducts(tenancy.WithTenant(ctx, name), before)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("tenant %s: %w", name, err)
+		}
+	}
+	return total, nil
+}
+
+// PendingEvents returns up to limit undelivered events of the tenant in ctx,
+// or of every tenant, each stamped with its tenant. Each call starts from the
+// next tenant in turn.
+func (r *TenantRepository) PendingEvents(ctx context.Context, limit int) ([]models.Event, error) {
+	names, err := r.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	start := r.next
+	r.next++
+	r.mutex.Unlock()
+
+	var pending []models.Event
+	for i := range names {
+		if len(pending) >= limit {
+			break
+		}
+		name := names[(start+i)%len(names)]
+		events, err := r.tenants[name].repo.PendingEvents(tenancy.WithTenant(ctx, name), limit-len(pending))
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", name, err)
+		}
+		for _, event := range events {
+			event.Tenant = name
+			pending = append(pending, event)
+		}
+	}
+	return pending, nil
+}
+
+// AckEvents marks events delivered in the repositories of their tenants
+func (r *TenantRepository) AckEvents(ctx context.Context, events []models.Event) error {
+	byTenant := make(map[string][]models.Event)
+	for _, event := range events {
+		tenant := event.Tenant
+		if tenant == "" {
+			tenant = tenancy.FromContext(ctx)
+		}
+		if _, exists := r.tenants[tenant]; !exists {
+			return fmt.Errorf("%w %q for event %s", ErrUnknownTenant, tenant, event.ID)
+		}
+		byTenant[tenant] = append(byTenant[tenant], event)
+	}
+
+	for _, name := range r.names {
+		if len(byTenant[name]) == 0 {
+			continue
+		}
+		if err := r.tenants[name].repo.AckEvents(tenancy.WithTenant(ctx, name), byTenant[name]); err != nil {
+			return fmt.Errorf("tenant %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// GetProductHistory returns the history of one of the tenant's products
+func (r *TenantRepository) GetProductHistory(ctx context.Context, id string) ([]models.Revision, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.repo.GetProductHistory(ctx, id)
+}
+
+// GetProductAsOf returns one of the tenant's products as it was at a time
+func (r *TenantRepository) GetProductAsOf(ctx context.Context, id string, at time.Time) (models.Product, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.Product{}, err
+	}
+	return p.repo.GetProductAsOf(ctx, id, at)
+}
+
+// RestoreProduct restores a revision of one of the tenant's products. A
+// product restored out of the trash counts against the quota.
+func (r *TenantRepository) RestoreProduct(ctx context.Context, id string, seq int64, version int64) (models.Product, error) {
+	p, err := r.partition(ctx)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, err := p.repo.GetProductByID(ctx, id); errors.Is(err, ErrNotFound) {
+		if err := p.admit(ctx, 1); err != nil {
+			return models.Product{}, err
+		}
+	} else if err != nil {
+		return models.Product{}, err
+	}
+	return p.repo.RestoreProduct(ctx, id, seq, version)
+}
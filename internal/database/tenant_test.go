@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/tenancy"
+)
+
+func TestTenantRepository(t *testing.T) {
+	newRepo := func(quotas map[string]int) *TenantRepository {
+		return NewTenantRepository(map[string]ProductRepository{
+			"brand-a": NewInMemoryRepository(),
+			"brand-b": NewInMemoryRepository(),
+		}, quotas)
+	}
+	brandA := tenancy.WithTenant(context.Background(), "brand-a")
+	brandB := tenancy.WithTenant(context.Background(), "brand-b")
+
+	// Test that tenants cannot see or change each other's products
+	t.Run("Isolation", func(t *testing.T) {
+		repo := newRepo(nil)
+		product, err := repo.CreateProduct(brandA, models.Product{ID: "shared-id", Name: "Brand A", Description: "Test Description", Price: 1.0, InventoryCount: 1})
+		require.NoError(t, err)
+
+		_, err = repo.GetProductByID(brandB, product.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, repo.DeleteProduct(brandB, product.ID, 0), ErrNotFound)
+		_, err = repo.AdjustInventory(brandB, product.ID, models.InventoryAdjustment{Delta: 1, Reason: "restock"})
+		assert.ErrorIs(t, err, ErrNotFound)
+		products, err := repo.GetProducts(brandB)
+		require.NoError(t, err)
+		assert.Empty(t, products)
+
+		// The same ID can be used by another tenant
+		_, err = repo.CreateProduct(brandB, models.Product{ID: "shared-id", Name: "Brand B", Description: "Test Description", Price: 2.0})
+		require.NoError(t, err)
+		fetched, err := repo.GetProductByID(brandA, "shared-id")
+		require.NoError(t, err)
+		assert.Equal(t, "Brand A", fetched.Name)
+	})
+
+	// Test that a call must name a known tenant
+	t.Run("UnknownTenant", func(t *testing.T) {
+		repo := newRepo(nil)
+		_, err := repo.GetProducts(context.Background())
+		assert.ErrorIs(t, err, ErrUnknownTenant)
+		_, err = repo.CreateProduct(tenancy.WithTenant(context.Background(), "brand-c"), models.Product{Name: "Nowhere", Description: "Test Description", Price: 1.0})
+		assert.ErrorIs(t, err, ErrUnknownTenant)
+		_, err = repo.ExpireReservations(tenancy.WithTenant(context.Background(), "brand-c"), time.Now())
+		assert.ErrorIs(t, err, ErrUnknownTenant)
+	})
+
+	// Test that product quotas apply to creates, batches and undeletes
+	t.Run("Quota", func(t *testing.T) {
+		repo := newRepo(map[string]int{"brand-a": 2})
+		first, err := repo.CreateProduct(brandA, models.Product{Name: "One", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		_, err = repo.BulkWrite(brandA, []BulkOperation{
+			{Op: BulkCreate, Product: models.Product{Name: "Two", Description: "Test Description", Price: 1.0}},
+			{Op: BulkUpsert, Product: models.Product{ID: "three", Name: "Three", Description: "Test Description", Price: 1.0}},
+		}, false)
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+		// Upserting an existing product does not add one
+		results, err := repo.BulkWrite(brandA, []BulkOperation{
+			{Op: BulkCreate, Product: models.Product{Name: "Two", Description: "Test Description", Price: 1.0}},
+			{Op: BulkUpsert, Product: models.Product{ID: first.ID, Name: "One", Description: "Test Description", Price: 2.0}},
+		}, false)
+		require.NoError(t, err)
+		for _, result := range results {
+			assert.NoError(t, result.Err)
+		}
+
+		_, err = repo.CreateProduct(brandA, models.Product{Name: "Three", Description: "Test Description", Price: 1.0})
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		count, err := repo.CountProducts(brandA)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		// Trashed products do not count until they come back
+		require.NoError(t, repo.DeleteProduct(brandA, first.ID, 0))
+		third, err := repo.CreateProduct(brandA, models.Product{Name: "Three", Description: "Test Description", Price: 1.0})
+		require.NoError(t, err)
+		_, err = repo.UndeleteProduct(brandA, first.ID, 0)
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		history, err := repo.GetProductHistory(brandA, first.ID)
+		require.NoError(t, err)
+		_, err = repo.RestoreProduct(brandA, first.ID, history[0].Seq, 0)
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+		// Restoring a live product is always allowed
+		_, err = repo.RestoreProduct(brandA, third.ID, 1, 0)
+		assert.NoError(t, err)
+
+		// Other tenants have their own quota
+		for i := 0; i < 3; i++ {
+			_, err := repo.CreateProduct(brandB, models.Product{Name: "Unlimited", Description: "Test Description", Price: 1.0})
+			require.NoError(t, err)
+		}
+	})
+
+	// Test that background jobs cover every tenant and events carry theirs
+	t.Run("BackgroundJobs", func(t *testing.T) {
+		repo := newRepo(nil)
+		for _, ctx := range []context.Context{brandA, brandB} {
+			product, err := repo.CreateProduct(ctx, models.Product{Name: "Evented", Description: "Test Description", Price: 1.0, InventoryCount: 5})
+			require.NoError(t, err)
+			_, err = repo.ReserveInventory(ctx, product.ID, 1, time.Millisecond)
+			require.NoError(t, err)
+		}
+
+		expired, err := repo.ExpireReservations(context.Background(), time.Now().Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, 2, expired)
+
+		events, err := repo.PendingEvents(context.Background(), 100)
+		require.NoError(t, err)
+		tenants := make(map[string]int)
+		for _, event := range events {
+			tenants[event.Tenant]++
+		}
+		assert.Equal(t, 2, len(tenants))
+		assert.Equal(t, tenants["brand-a"], tenants["brand-b"])
+
+		// A limited batch takes turns between tenants
+		first, err := repo.PendingEvents(context.Background(), 1)
+		require.NoError(t, err)
+		second, err := repo.PendingEvents(context.Background(), 1)
+		require.NoError(t, err)
+		require.Len(t, first, 1)
+		require.Len(t, second, 1)
+		assert.NotEqual(t, first[0].Tenant, second[0].Tenant)
+
+		require.NoError(t, repo.AckEvents(context.Background(), events))
+		events, err = repo.PendingEvents(context.Background(), 100)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+
+		assert.ErrorIs(t, repo.AckEvents(context.Background(), []models.Event{{ID: "e1"}}), ErrUnknownTenant)
+	})
+}
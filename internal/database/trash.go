@@ -0,0 +1,35 @@
+package database
+
+import (
+	"time"
+
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// isDeleted reports whether a product is in the trash
+func isDeleted(product models.Product) bool {
+	return product.DeletedAt != nil
+}
+
+// tombstone returns product moved to the trash at now. The product keeps its
+// ID, so it cannot be recreated until it is purged.
+func tombstone(product models.Product, now time.Time) models.Product {
+	deletedAt := now
+	product.DeletedAt = &deletedAt
+	product.UpdatedAt = now
+	product.Version++
+	return product
+}
+
+// untombstone returns a trashed product taken back out of the trash at now
+func untombstone(product models.Product, now time.Time) models.Product {
+	product.DeletedAt = nil
+	product.UpdatedAt = now
+	product.Version++
+	return product
+}
+
+// purgeable reports whether a trashed product was deleted at or before before
+func purgeable(product models.Product, before time.Time) bool {
+	return isDeleted(product) && !product.DeletedAt.After(before)
+}
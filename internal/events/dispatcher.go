@@ -0,0 +1,89 @@
+// Package events delivers the domain events that the repository records in
+// its outbox to a pluggable Sink.
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// Source is an outbox of pending events. database.ProductRepository is one.
+type Source interface {
+	PendingEvents(ctx context.Context, limit int) ([]models.Event, error)
+	AckEvents(ctx context.Context, events []models.Event) error
+}
+
+// Sink receives events from a Dispatcher. Publish returns nil only when every
+// event in the batch has been accepted; otherwise the whole batch is
+// delivered again later, so sinks must tolerate duplicates.
+type Sink interface {
+	Publish(ctx context.Context, events []models.Event) error
+}
+
+// Dispatcher moves events from a Source to a Sink with at-least-once
+// delivery: events are acknowledged only after the sink has accepted them.
+type Dispatcher struct {
+	source    Source
+	sink      Sink
+	batchSize int
+	interval  time.Duration
+}
+
+// NewDispatcher returns a Dispatcher publishing up to batchSize events at a
+// time and polling the source every interval when Run is used
+func NewDispatcher(source Source, sink Sink, batchSize int, interval time.Duration) *Dispatcher {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &Dispatcher{source: source, sink: sink, batchSize: batchSize, interval: interval}
+}
+
+// DispatchOnce publishes pending events batch by batch until the outbox is
+// empty and returns how many were delivered. It stops at the first failure;
+// the failed batch stays pending and is retried by the next call.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	delivered := 0
+	for {
+		batch, err := d.source.PendingEvents(ctx, d.batchSize)
+		if err != nil {
+			return delivered, fmt.Errorf("reading pending events: %w", err)
+		}
+		if len(batch) == 0 {
+			return delivered, nil
+		}
+
+		if err := d.sink.Publish(ctx, batch); err != nil {
+			return delivered, fmt.Errorf("publishing events: %w", err)
+		}
+		if err := d.source.AckEvents(ctx, batch); err != nil {
+			return delivered, fmt.Errorf("acknowledging events: %w", err)
+		}
+		delivered += len(batch)
+
+		if len(batch) < d.batchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// Run dispatches pending events every interval until ctx is done. Failures
+// are logged and retried on the next tick.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.DispatchOnce(ctx); err != nil {
+				log.Printf("Warning: dispatching events: %v", err)
+			}
+		}
+	}
+}
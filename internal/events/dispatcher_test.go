@@ -0,0 +1,121 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// flakySink fails the first failures publishes, then hands events on to next
+type flakySink struct {
+	failures int
+	next     Sink
+}
+
+func (s *flakySink) Publish(ctx context.Context, events []models.Event) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink unavailable")
+	}
+	return s.next.Publish(ctx, events)
+}
+
+func eventTypes(events []models.Event) []string {
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+
+	// Test that events are delivered in order and removed from the outbox
+	t.Run("DispatchOnce", func(t *testing.T) {
+		repo := database.NewInMemoryRepository()
+		sink := NewMemorySink()
+		dispatcher := NewDispatcher(repo, sink, 2, time.Second)
+
+		product, err := repo.CreateProduct(ctx, models.Product{Name: "Widget", Description: "Test Description", Price: 10.0, InventoryCount: 1})
+		require.NoError(t, err)
+		_, err = repo.AdjustInventory(ctx, product.ID, models.InventoryAdjustment{Delta: -1})
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteProduct(ctx, product.ID, 0))
+
+		delivered, err := dispatcher.DispatchOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 4, delivered)
+		assert.Equal(t, []string{
+			models.EventProductCreated,
+			models.EventInventoryChanged,
+			models.EventStockDepleted,
+			models.EventProductDeleted,
+		}, eventTypes(sink.Events()))
+
+		pending, err := repo.PendingEvents(ctx, 10)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+
+		delivered, err = dispatcher.DispatchOnce(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, delivered)
+	})
+
+	// Test that a failed publish leaves the batch pending for the next attempt
+	t.Run("AtLeastOnce", func(t *testing.T) {
+		repo := database.NewInMemoryRepository()
+		sink := NewMemorySink()
+		dispatcher := NewDispatcher(repo, &flakySink{failures: 1, next: sink}, 10, time.Second)
+
+		product, err := repo.CreateProduct(ctx, models.Product{Name: "Widget", Description: "Test Description", Price: 10.0, InventoryCount: 1})
+		require.NoError(t, err)
+
+		_, err = dispatcher.DispatchOnce(ctx)
+		assert.Error(t, err)
+		assert.Empty(t, sink.Events())
+		pending, err := repo.PendingEvents(ctx, 10)
+		require.NoError(t, err)
+		assert.Len(t, pending, 1)
+
+		delivered, err := dispatcher.DispatchOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		require.Len(t, sink.Events(), 1)
+		assert.Equal(t, product.ID, sink.Events()[0].ProductID)
+		assert.Equal(t, pending[0].ID, sink.Events()[0].ID)
+	})
+
+	// Test that Run keeps delivering in the background until cancelled
+	t.Run("Run", func(t *testing.T) {
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		repo := database.NewInMemoryRepository()
+		sink := NewMemorySink()
+		done := make(chan struct{})
+		go func() {
+			NewDispatcher(repo, &flakySink{failures: 2, next: sink}, 10, 5*time.Millisecond).Run(runCtx)
+			close(done)
+		}()
+
+		_, err := repo.CreateProduct(ctx, models.Product{Name: "Widget", Description: "Test Description", Price: 10.0, InventoryCount: 1})
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			return len(sink.Events()) == 1
+		}, time.Second, 5*time.Millisecond)
+
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("dispatcher did not stop after cancel")
+		}
+	})
+}
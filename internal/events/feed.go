@@ -0,0 +1,161 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// subscriberBuffer is how many changes a subscriber may fall behind before
+// it is disconnected
+const subscriberBuffer = 64
+
+// Change is an event numbered by a Feed
+type Change struct {
+	Seq   uint64
+	Event models.Event
+}
+
+// Filter selects the changes a subscriber receives. Empty fields match all.
+type Filter struct {
+	ProductID string
+	Types     map[string]bool
+	Tenant    string
+}
+
+// Matches reports whether event passes the filter
+func (f Filter) Matches(event models.Event) bool {
+	if f.ProductID != "" && f.ProductID != event.ProductID {
+		return false
+	}
+	if len(f.Types) > 0 && !f.Types[event.Type] {
+		return false
+	}
+	if f.Tenant != "" && f.Tenant != event.Tenant {
+		return false
+	}
+	return true
+}
+
+// Feed is a Sink that numbers events with increasing sequence numbers and
+// fans them out to live subscribers. The most recent changes are kept in a
+// bounded buffer so a subscriber that reconnects can resume where it left
+// off. Sequence numbers start again from 1 when the process restarts.
+type Feed struct {
+	mutex       sync.Mutex
+	size        int
+	buffer      []Change
+	buffered    map[string]bool // IDs of the events in buffer
+	seq         uint64
+	subscribers map[*Subscription]bool
+}
+
+// NewFeed returns a Feed remembering the last size changes
+func NewFeed(size int) *Feed {
+	if size <= 0 {
+		size = 1000
+	}
+	return &Feed{
+		size:        size,
+		buffered:    make(map[string]bool),
+		subscribers: make(map[*Subscription]bool),
+	}
+}
+
+// Publish numbers events and sends them to subscribers. An event still in
+// the buffer is a redelivery from the outbox and is not published again.
+func (f *Feed) Publish(ctx context.Context, events []models.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, event := range events {
+		if f.buffered[event.ID] {
+			continue
+		}
+		f.seq++
+		change := Change{Seq: f.seq, Event: event}
+
+		if len(f.buffer) == f.size {
+			delete(f.buffered, f.buffer[0].Event.ID)
+			f.buffer = append(f.buffer[:0], f.buffer[1:]...)
+		}
+		f.buffer = append(f.buffer, change)
+		f.buffered[event.ID] = true
+
+		for sub := range f.subscribers {
+			if !sub.filter.Matches(event) {
+				continue
+			}
+			select {
+			case sub.ch <- change:
+			default:
+				// Too far behind; it can reconnect and resume from the buffer
+				f.unsubscribe(sub)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Seq returns the sequence number of the latest change
+func (f *Feed) Seq() uint64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.seq
+}
+
+// Subscribe returns the buffered changes after seq that pass filter, and a
+// Subscription delivering the ones that follow. complete is false when some
+// changes after seq are no longer buffered, or seq is from before a restart.
+func (f *Feed) Subscribe(seq uint64, filter Filter) (backlog []Change, sub *Subscription, complete bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	complete = seq <= f.seq
+	if len(f.buffer) > 0 && seq+1 < f.buffer[0].Seq {
+		complete = false
+	}
+	for _, change := range f.buffer {
+		if change.Seq > seq && filter.Matches(change.Event) {
+			backlog = append(backlog, change)
+		}
+	}
+
+	ch := make(chan Change, subscriberBuffer)
+	sub = &Subscription{C: ch, feed: f, filter: filter, ch: ch}
+	f.subscribers[sub] = true
+	return backlog, sub, complete
+}
+
+// unsubscribe removes sub and closes its channel. The caller must hold the lock.
+func (f *Feed) unsubscribe(sub *Subscription) {
+	if f.subscribers[sub] {
+		delete(f.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// Subscription receives changes from a Feed on C until it is closed. C is
+// also closed when the subscriber falls too far behind.
+type Subscription struct {
+	C <-chan Change
+
+	feed   *Feed
+	filter Filter
+	ch     chan Change
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.feed.mutex.Lock()
+	defer s.feed.mutex.Unlock()
+
+	s.feed.unsubscribe(s)
+}
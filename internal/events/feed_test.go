@@ -0,0 +1,93 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/models"
+)
+
+func feedEvent(n int, productID, eventType string) models.Event {
+	return models.Event{ID: fmt.Sprintf("e%d", n), Type: eventType, ProductID: productID}
+}
+
+func changeIDs(changes []Change) []string {
+	ids := make([]string, len(changes))
+	for i, change := range changes {
+		ids[i] = change.Event.ID
+	}
+	return ids
+}
+
+func TestFeed(t *testing.T) {
+	ctx := context.Background()
+
+	// Test numbering, redelivery and live fan-out with filters
+	t.Run("Publish", func(t *testing.T) {
+		feed := NewFeed(10)
+		backlog, all, complete := feed.Subscribe(feed.Seq(), Filter{})
+		defer all.Close()
+		assert.Empty(t, backlog)
+		assert.True(t, complete)
+		_, stock, _ := feed.Subscribe(0, Filter{ProductID: "p1", Types: map[string]bool{models.EventStockDepleted: true}})
+		defer stock.Close()
+
+		require.NoError(t, feed.Publish(ctx, []models.Event{
+			feedEvent(1, "p1", models.EventInventoryChanged),
+			feedEvent(2, "p1", models.EventStockDepleted),
+			feedEvent(3, "p2", models.EventStockDepleted),
+		}))
+		// A batch redelivered by the dispatcher is not numbered again
+		require.NoError(t, feed.Publish(ctx, []models.Event{feedEvent(3, "p2", models.EventStockDepleted)}))
+		assert.Equal(t, uint64(3), feed.Seq())
+
+		for seq := uint64(1); seq <= 3; seq++ {
+			change := <-all.C
+			assert.Equal(t, seq, change.Seq)
+		}
+		change := <-stock.C
+		assert.Equal(t, "e2", change.Event.ID)
+		assert.Len(t, stock.C, 0)
+	})
+
+	// Test resuming from the buffer, and detecting changes that fell out of it
+	t.Run("Resume", func(t *testing.T) {
+		feed := NewFeed(3)
+		for i := 1; i <= 5; i++ {
+			require.NoError(t, feed.Publish(ctx, []models.Event{feedEvent(i, "p1", models.EventPriceChanged)}))
+		}
+
+		backlog, sub, complete := feed.Subscribe(3, Filter{})
+		sub.Close()
+		assert.True(t, complete)
+		assert.Equal(t, []string{"e4", "e5"}, changeIDs(backlog))
+
+		backlog, sub, complete = feed.Subscribe(1, Filter{})
+		sub.Close()
+		assert.False(t, complete, "e2 is no longer buffered")
+		assert.Equal(t, []string{"e3", "e4", "e5"}, changeIDs(backlog))
+
+		_, sub, complete = feed.Subscribe(99, Filter{})
+		sub.Close()
+		assert.False(t, complete, "sequence numbers from before a restart")
+	})
+
+	// Test that a subscriber that falls behind is disconnected
+	t.Run("SlowSubscriber", func(t *testing.T) {
+		feed := NewFeed(1000)
+		_, sub, _ := feed.Subscribe(0, Filter{})
+		for i := 0; i <= subscriberBuffer; i++ {
+			require.NoError(t, feed.Publish(ctx, []models.Event{feedEvent(i, "p1", models.EventPriceChanged)}))
+		}
+
+		received := 0
+		for range sub.C {
+			received++
+		}
+		assert.Equal(t, subscriberBuffer, received)
+		sub.Close()
+	})
+}
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/yourusername/product-service/internal/models"
+)
+
+// MemorySink keeps published events in process. It is meant for tests and
+// for embedding the service where events are consumed in the same process.
+type MemorySink struct {
+	mutex  sync.Mutex
+	events []models.Event
+}
+
+// NewMemorySink returns an empty MemorySink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Publish appends events to the sink
+func (s *MemorySink) Publish(ctx context.Context, events []models.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.events = append(s.events, events...)
+	return nil
+}
+
+// Events returns every event published so far, in publication order
+func (s *MemorySink) Events() []models.Event {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]models.Event(nil), s.events...)
+}
+
+// MultiSink publishes each batch to every sink in turn. A failure in any of
+// them fails the batch, so sinks before it may receive it again.
+type MultiSink []Sink
+
+// Publish hands events to each sink, stopping at the first error
+func (m MultiSink) Publish(ctx context.Context, events []models.Event) error {
+	for _, sink := range m {
+		if err := sink.Publish(ctx, events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogSink writes one log line per event
+type LogSink struct{}
+
+// Publish logs events
+func (LogSink) Publish(ctx context.Context, events []models.Event) error {
+	for _, event := range events {
+		log.Printf("Event %s %s product=%s version=%d", event.ID, event.Type, event.ProductID, event.Version)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+// API key scopes, from least to most privileged
+const (
+	APIKeyScopeReadOnly  = "read-only"
+	APIKeyScopeInventory = "inventory-only"
+	APIKeyScopeFull      = "full"
+)
+
+// APIKey lets a service call the API without a token. Only a hash of the key
+// is stored; Key is set when the key is minted or rotated and never again.
+// A key belongs to the tenant it was minted in and only acts for that tenant.
+// @Description API key
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	Tenant     string     `json:"tenant,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	Hash       string     `json:"-"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	RotatedAt  *time.Time `json:"rotatedAt,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// APIKeyRequest asks for a new API key. Without ExpiresAt the key does not expire.
+// @Description API key request
+type APIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scope     string     `json:"scope" binding:"required,oneof=read-only inventory-only full"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// APIKeyRotation optionally moves a key's expiry when it is rotated
+// @Description API key rotation
+type APIKeyRotation struct {
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
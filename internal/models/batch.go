@@ -0,0 +1,19 @@
+package models
+
+// BatchRequest is a list of product writes submitted together
+// @Description Batch of product writes
+type BatchRequest struct {
+	// Atomic applies either every operation or none of them
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations" binding:"required,min=1,max=1000"`
+}
+
+// BatchOperation is one create, update or delete in a batch. Updates and
+// deletes name the product by ID and may pin the version they expect.
+// @Description Batch operation
+type BatchOperation struct {
+	Op      string   `json:"op" enums:"create,update,delete"`
+	ID      string   `json:"id,omitempty"`
+	Version int64    `json:"version,omitempty"`
+	Product *Product `json:"product,omitempty"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// Domain event types. A change can produce several events: a price cut that
+// also sells out the last unit is a ProductUpdated, a PriceChanged, an
+// InventoryChanged and a StockDepleted.
+const (
+	EventProductCreated   = "ProductCreated"
+	EventProductUpdated   = "ProductUpdated"
+	EventPriceChanged     = "PriceChanged"
+	EventInventoryChanged = "InventoryChanged"
+	EventStockDepleted    = "StockDepleted"
+	EventStockReplenished = "StockReplenished"
+	EventProductDeleted   = "ProductDeleted"
+	EventProductRestored  = "ProductRestored"
+)
+
+// EventTypes lists every domain event type
+var EventTypes = []string{
+	EventProductCreated,
+	EventProductUpdated,
+	EventPriceChanged,
+	EventInventoryChanged,
+	EventStockDepleted,
+	EventStockReplenished,
+	EventProductDeleted,
+	EventProductRestored,
+}
+
+// Event is a domain event recorded in the outbox together with the change
+// that caused it. ID is stable across redeliveries, so consumers can use it
+// to discard duplicates. Product is the state after the change and Previous
+// the state before it, which is nil for ProductCreated. Tenant names the
+// catalogue the product belongs to when the service has several.
+// @Description Product domain event
+type Event struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	ProductID  string    `json:"productId"`
+	Version    int64     `json:"version"`
+	Revision   int64     `json:"revision"`
+	Actor      string    `json:"actor,omitempty"`
+	Tenant     string    `json:"tenant,omitempty"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Product    Product   `json:"product"`
+	Previous   *Product  `json:"previous,omitempty"`
+}
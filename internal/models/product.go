@@ -14,12 +14,27 @@ type Product struct {
 	InventoryCount int     `json:"inventoryCount" binding:"required,gte=0"`
 	CreatedAt    time.Time `json:"createdAt,omitempty"`
 	UpdatedAt    time.Time `json:"updatedAt,omitempty"`
+	Version      int64     `json:"version"`
+	// DeletedAt is set while the product is in the trash
+	DeletedAt    *time.Time `json:"deletedAt,omitempty"`
 }
 
-// ProductAvailability represents the product availability information
+// ProductAvailability represents the product availability information.
+// OnHand is the physical stock, Reserved the quantity held by unexpired
+// reservations and AvailableToPromise what can still be sold.
 // @Description Product availability information
 type ProductAvailability struct {
 	ProductID      string `json:"productId"`
 	IsAvailable    bool   `json:"isAvailable"`
 	InventoryCount int    `json:"inventoryCount"`
+	OnHand         int    `json:"onHand"`
+	Reserved       int    `json:"reserved"`
+	AvailableToPromise int `json:"availableToPromise"`
+}
+
+// InventoryAdjustment is a signed change to a product's inventory count
+// @Description Inventory adjustment
+type InventoryAdjustment struct {
+	Delta  int    `json:"delta" binding:"required"`
+	Reason string `json:"reason" binding:"required,oneof=restock sale return damage correction"`
 }
\ No newline at end of file
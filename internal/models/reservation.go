@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Reservation statuses. Only held reservations are stored; confirming or
+// releasing one removes it and reports the final status to the caller.
+const (
+	ReservationHeld      = "held"
+	ReservationConfirmed = "confirmed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Reservation holds a quantity of a product's stock until it expires
+// @Description Stock reservation
+type Reservation struct {
+	ID        string    `json:"id"`
+	ProductID string    `json:"productId"`
+	Quantity  int       `json:"quantity"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ReservationRequest asks to hold a quantity of stock for TTLSeconds
+// @Description Stock reservation request
+type ReservationRequest struct {
+	Quantity   int `json:"quantity" binding:"required,gt=0"`
+	TTLSeconds int `json:"ttlSeconds,omitempty" binding:"omitempty,gt=0,lte=86400"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// Revision kinds
+const (
+	RevisionCreated  = "created"
+	RevisionUpdated  = "updated"
+	RevisionDeleted  = "deleted"
+	RevisionRestored = "restored"
+)
+
+// Revision is one entry of a product's append-only change history. Seq numbers
+// a product's revisions from 1. Product is the state after the change; a
+// delete records the product as it went into the trash.
+// @Description Product revision
+type Revision struct {
+	Seq          int64     `json:"seq"`
+	ProductID    string    `json:"productId"`
+	Kind         string    `json:"kind"`
+	Actor        string    `json:"actor,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+	Changes      []string  `json:"changes,omitempty"`
+	RestoredFrom int64     `json:"restoredFrom,omitempty"`
+	Product      *Product  `json:"product"`
+}
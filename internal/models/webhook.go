@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+)
+
+// Webhook delivery states. A delivery is pending until the receiver accepts
+// it, and dead once every attempt has failed.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+// Webhook subscribes a receiver to domain events. Each matching event is
+// POSTed to URL as JSON and signed with Secret; no EventTypes means every
+// event. Secret is only returned when the webhook is created. A webhook
+// created for a tenant only receives that tenant's events; Tenant is set by
+// the service.
+// @Description Webhook subscription
+type Webhook struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url" binding:"required,http_url"`
+	EventTypes []string  `json:"eventTypes,omitempty" binding:"omitempty,dive,oneof=ProductCreated ProductUpdated PriceChanged InventoryChanged StockDepleted StockReplenished ProductDeleted ProductRestored"`
+	Secret     string    `json:"secret,omitempty" binding:"omitempty,min=16"`
+	Tenant     string    `json:"tenant,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Subscribes reports whether the webhook wants events of type eventType
+func (w Webhook) Subscribes(eventType string) bool {
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range w.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event on its way to one webhook, with the outcome
+// of the latest attempt
+// @Description Webhook delivery
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	WebhookID      string     `json:"webhookId"`
+	Event          Event      `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"responseStatus,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	LastAttemptAt  *time.Time `json:"lastAttemptAt,omitempty"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+}
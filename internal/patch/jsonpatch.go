@@ -0,0 +1,367 @@
+package patch
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// operation is one entry of an RFC 6902 JSON Patch document. Value is kept raw
+// so that a missing value can be told apart from an explicit null.
+type operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// JSONPatch applies an RFC 6902 JSON Patch to doc. Operations are applied in
+// order and the patch fails as a whole if any of them fails.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []operation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, invalid("a JSON Patch must be an array of operations: %v", err)
+	}
+
+	for i, op := range operations {
+		target, err = op.apply(target)
+		if err != nil {
+			return nil, wrapOperation(i, op, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+// apply runs a single operation against doc and returns the new document
+func (op operation) apply(doc interface{}) (interface{}, error) {
+	if op.Path == nil {
+		return nil, invalid("missing path")
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			return replace(doc, path, value)
+		default:
+			current, err := get(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !equal(current, value) {
+				return nil, ErrTestFailed
+			}
+			return doc, nil
+		}
+	case "remove":
+		doc, _, err := remove(doc, path)
+		return doc, err
+	case "move", "copy":
+		if op.From == nil {
+			return nil, invalid("missing from")
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			value, err := get(doc, from)
+			if err != nil {
+				return nil, err
+			}
+			return add(doc, path, deepCopy(value))
+		}
+		if strings.HasPrefix(*op.Path, *op.From+"/") {
+			return nil, invalid("cannot move a value into one of its children")
+		}
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	default:
+		return nil, invalid("unknown op %q", op.Op)
+	}
+}
+
+// value decodes the operation's value, which add, replace and test require
+func (op operation) value() (interface{}, error) {
+	if len(op.Value) == 0 {
+		return nil, invalid("missing value")
+	}
+	return decode(op.Value)
+}
+
+// wrapOperation adds the position and kind of a failed operation to err
+func wrapOperation(i int, op operation, err error) error {
+	path := ""
+	if op.Path != nil {
+		path = *op.Path
+	}
+	return &operationError{index: i, op: op.Op, path: path, err: err}
+}
+
+// operationError reports which operation of a patch failed
+type operationError struct {
+	index int
+	op    string
+	path  string
+	err   error
+}
+
+func (e *operationError) Error() string {
+	return "operation " + strconv.Itoa(e.index) + " (" + e.op + " " + strconv.Quote(e.path) + "): " + e.err.Error()
+}
+
+func (e *operationError) Unwrap() error {
+	return e.err
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, invalid("path %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// get returns the value at path
+func get(doc interface{}, path []string) (interface{}, error) {
+	node := doc
+	for _, token := range path {
+		switch container := node.(type) {
+		case map[string]interface{}:
+			child, ok := container[token]
+			if !ok {
+				return nil, invalid("member %q does not exist", token)
+			}
+			node = child
+		case []interface{}:
+			i, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = container[i]
+		default:
+			return nil, invalid("cannot index into a scalar with %q", token)
+		}
+	}
+	return node, nil
+}
+
+// update walks to the container holding the last token of path and calls fn
+// on it. Containers on the way are written back because fn may reallocate a slice.
+func update(doc interface{}, path []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		child, ok := container[path[0]]
+		if !ok {
+			return nil, invalid("member %q does not exist", path[0])
+		}
+		updated, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		container[path[0]] = updated
+		return container, nil
+	case []interface{}:
+		i, err := arrayIndex(path[0], len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update(container[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		container[i] = updated
+		return container, nil
+	default:
+		return nil, invalid("cannot index into a scalar with %q", path[0])
+	}
+}
+
+// add inserts value at path; "-" appends to an array
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			i := len(c)
+			if token != "-" {
+				var err error
+				if i, err = arrayIndex(token, len(c)); err != nil {
+					return nil, err
+				}
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		default:
+			return nil, invalid("cannot add %q to a scalar", token)
+		}
+	})
+}
+
+// remove deletes the value at path and returns it
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, invalid("cannot remove the whole document")
+	}
+
+	var removed interface{}
+	doc, err := update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			value, ok := c[token]
+			if !ok {
+				return nil, invalid("member %q does not exist", token)
+			}
+			removed = value
+			delete(c, token)
+			return c, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = c[i]
+			return append(c[:i], c[i+1:]...), nil
+		default:
+			return nil, invalid("cannot remove %q from a scalar", token)
+		}
+	})
+	return doc, removed, err
+}
+
+// replace swaps the value at path, which must already exist
+func replace(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, ok := c[token]; !ok {
+				return nil, invalid("member %q does not exist", token)
+			}
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			c[i] = value
+			return c, nil
+		default:
+			return nil, invalid("cannot replace %q in a scalar", token)
+		}
+	})
+}
+
+// arrayIndex parses an array index token that may be at most max
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, invalid("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max {
+		return 0, invalid("array index %q out of range", token)
+	}
+	return i, nil
+}
+
+// equal compares two decoded JSON values, treating numbers by value
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for name, value := range x {
+			other, ok := y[name]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// deepCopy duplicates a decoded JSON value so copies do not share containers
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for name, child := range v {
+			c[name] = deepCopy(child)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, child := range v {
+			c[i] = deepCopy(child)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,42 @@
+package patch
+
+import (
+	"encoding/json"
+)
+
+// MergePatch applies an RFC 7396 merge patch to doc. Objects in the patch are
+// merged recursively, null removes a member and any other value replaces it.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := decode(patch)
+	if err != nil {
+		return nil, invalid("%v", err)
+	}
+
+	return json.Marshal(merge(target, changes))
+}
+
+// merge implements the MergePatch algorithm from RFC 7396 section 2
+func merge(target, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		object = make(map[string]interface{})
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(object, name)
+			continue
+		}
+		object[name] = merge(object[name], value)
+	}
+
+	return object
+}
@@ -0,0 +1,45 @@
+// Package patch applies RFC 7396 JSON Merge Patch and RFC 6902 JSON Patch
+// documents to JSON values.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Media types of the two patch formats
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+var (
+	// ErrInvalidPatch is returned for malformed patches and for operations
+	// that cannot be applied, such as removing a member that does not exist
+	ErrInvalidPatch = errors.New("invalid patch")
+
+	// ErrTestFailed is returned when a JSON Patch "test" operation does not match
+	ErrTestFailed = errors.New("patch test failed")
+)
+
+// decode parses JSON keeping numbers as json.Number so they round-trip exactly
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+// invalid wraps err as an ErrInvalidPatch
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidPatch, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,89 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Test cases from RFC 7396 appendix A
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+		require.NoError(t, err, tt.patch)
+		assert.JSONEq(t, tt.want, string(got), tt.patch)
+	}
+
+	// Test that a malformed patch is reported as invalid
+	_, err := MergePatch([]byte(`{}`), []byte(`{"a":`))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
+
+func TestJSONPatch(t *testing.T) {
+	doc := `{"name":"Widget","price":10,"tags":["a","b"],"dims":{"w":1}}`
+
+	// Test each operation
+	t.Run("Operations", func(t *testing.T) {
+		tests := []struct {
+			patch, want string
+		}{
+			{`[{"op":"add","path":"/color","value":"red"}]`, `{"name":"Widget","price":10,"tags":["a","b"],"dims":{"w":1},"color":"red"}`},
+			{`[{"op":"add","path":"/tags/1","value":"x"}]`, `{"name":"Widget","price":10,"tags":["a","x","b"],"dims":{"w":1}}`},
+			{`[{"op":"add","path":"/tags/-","value":"c"}]`, `{"name":"Widget","price":10,"tags":["a","b","c"],"dims":{"w":1}}`},
+			{`[{"op":"remove","path":"/tags/0"}]`, `{"name":"Widget","price":10,"tags":["b"],"dims":{"w":1}}`},
+			{`[{"op":"replace","path":"/price","value":12.5}]`, `{"name":"Widget","price":12.5,"tags":["a","b"],"dims":{"w":1}}`},
+			{`[{"op":"move","from":"/dims/w","path":"/width"}]`, `{"name":"Widget","price":10,"tags":["a","b"],"dims":{},"width":1}`},
+			{`[{"op":"copy","from":"/tags","path":"/labels"}]`, `{"name":"Widget","price":10,"tags":["a","b"],"dims":{"w":1},"labels":["a","b"]}`},
+			{`[{"op":"test","path":"/price","value":10.0},{"op":"replace","path":"/name","value":null}]`, `{"name":null,"price":10,"tags":["a","b"],"dims":{"w":1}}`},
+			{`[{"op":"add","path":"/a~1b","value":1}]`, `{"name":"Widget","price":10,"tags":["a","b"],"dims":{"w":1},"a/b":1}`},
+		}
+
+		for _, tt := range tests {
+			got, err := JSONPatch([]byte(doc), []byte(tt.patch))
+			require.NoError(t, err, tt.patch)
+			assert.JSONEq(t, tt.want, string(got), tt.patch)
+		}
+	})
+
+	// Test that failing and malformed operations are rejected
+	t.Run("Errors", func(t *testing.T) {
+		invalidPatches := []string{
+			`{"op":"add"}`,
+			`[{"op":"add","path":"/x"}]`,
+			`[{"op":"replace","path":"/missing","value":1}]`,
+			`[{"op":"remove","path":"/tags/5"}]`,
+			`[{"op":"add","path":"/tags/01","value":1}]`,
+			`[{"op":"add","path":"name","value":1}]`,
+			`[{"op":"move","from":"/dims","path":"/dims/inner"}]`,
+			`[{"op":"copy","path":"/x"}]`,
+			`[{"op":"frobnicate","path":"/x"}]`,
+		}
+		for _, p := range invalidPatches {
+			_, err := JSONPatch([]byte(doc), []byte(p))
+			assert.ErrorIs(t, err, ErrInvalidPatch, p)
+		}
+
+		_, err := JSONPatch([]byte(doc), []byte(`[{"op":"test","path":"/name","value":"Gadget"}]`))
+		assert.ErrorIs(t, err, ErrTestFailed)
+		assert.ErrorContains(t, err, "operation 0")
+	})
+}
@@ -0,0 +1,56 @@
+// Package requestid carries the identifier that ties a request to the log
+// lines and error responses it produced. Clients may choose the identifier,
+// or pass on the trace ID of a W3C Trace Context; otherwise one is generated.
+package requestid
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+type idKey struct{}
+
+var (
+	// idPattern is what a client-chosen identifier may look like, so it is
+	// safe to log and echo
+	idPattern = regexp.MustCompile(`^[A-Za-z0-9._:+=/-]{1,128}$`)
+
+	// traceparentPattern matches a traceparent header, capturing its trace ID
+	traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+)
+
+// Resolve returns the identifier of a request: id when it is acceptable,
+// else the trace ID of a valid traceparent, else a new identifier
+func Resolve(id, traceparent string) string {
+	if idPattern.MatchString(id) {
+		return id
+	}
+	if match := traceparentPattern.FindStringSubmatch(traceparent); match != nil && match[1] != "00000000000000000000000000000000" {
+		return match[1]
+	}
+	return uuid.New().String()
+}
+
+// WithID returns a copy of ctx carrying the request identifier id
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// FromContext returns the identifier set by WithID, or "" if there is none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey{}).(string)
+	return id
+}
+
+// Printf logs like log.Printf, prefixed with the request identifier in ctx
+func Printf(ctx context.Context, format string, args ...interface{}) {
+	if id := FromContext(ctx); id != "" {
+		log.Printf("[%s] %s", id, fmt.Sprintf(format, args...))
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,41 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	// Test that an acceptable client identifier is kept
+	t.Run("ClientID", func(t *testing.T) {
+		assert.Equal(t, "req-42", Resolve("req-42", traceparent))
+	})
+
+	// Test that the trace ID is used when no identifier is given
+	t.Run("Traceparent", func(t *testing.T) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", Resolve("", traceparent))
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", Resolve("bad id\n", traceparent))
+	})
+
+	// Test that a new identifier replaces missing or unsafe ones
+	t.Run("Generated", func(t *testing.T) {
+		for _, id := range []string{"", "line\nbreak", strings.Repeat("x", 129)} {
+			for _, parent := range []string{"", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "garbage"} {
+				generated := Resolve(id, parent)
+				assert.Len(t, generated, 36)
+				assert.NotEqual(t, generated, Resolve(id, parent))
+			}
+		}
+	})
+
+	// Test carrying the identifier on a context
+	t.Run("Context", func(t *testing.T) {
+		assert.Empty(t, FromContext(context.Background()))
+		assert.Equal(t, "req-42", FromContext(WithID(context.Background(), "req-42")))
+	})
+}
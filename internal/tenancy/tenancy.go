@@ -0,0 +1,39 @@
+// Package tenancy carries the tenant a request acts for. Each tenant is a
+// separate catalogue; with no tenants configured the service has a single,
+// unnamed one and contexts carry no tenant.
+package tenancy
+
+import (
+	"context"
+	"regexp"
+)
+
+type tenantKey struct{}
+
+// namePattern is what a tenant name must look like: a DNS label, so it can
+// also be a subdomain, a directory and part of a Cosmos DB container name
+var namePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidName reports whether name can be used as a tenant name
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// WithTenant returns a copy of ctx acting for tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// FromContext returns the tenant set by WithTenant, or "" if there is none
+func FromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// Visible reports whether something owned by owner may be seen from ctx.
+// Without a tenant in ctx everything is visible, as in a single-tenant
+// service; otherwise only what the same tenant owns is.
+func Visible(ctx context.Context, owner string) bool {
+	tenant := FromContext(ctx)
+	return tenant == "" || tenant == owner
+}
@@ -0,0 +1,488 @@
+// Package webhooks pushes domain events to partner endpoints. Deliveries are
+// signed with the subscription's secret, retried with exponential backoff and
+// dead-lettered once every attempt has failed.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/tenancy"
+)
+
+var (
+	// ErrNotFound is returned when a webhook does not exist
+	ErrNotFound = errors.New("webhook not found")
+
+	// ErrDeliveryNotFound is returned when a delivery does not exist
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+	// ErrNotDead is returned when redelivering a delivery that has not been dead-lettered
+	ErrNotDead = errors.New("webhook delivery is not dead-lettered")
+)
+
+// Options tunes delivery
+type Options struct {
+	// MaxAttempts is how many times a delivery is tried before it is dead-lettered
+	MaxAttempts int
+	// Backoff is the wait before the first retry; it doubles with every attempt
+	Backoff time.Duration
+	// MaxBackoff caps the wait between attempts
+	MaxBackoff time.Duration
+	// Timeout bounds a single attempt
+	Timeout time.Duration
+	// LogSize is how many succeeded deliveries are kept per webhook
+	LogSize int
+}
+
+// DefaultOptions returns the options used when none are configured
+func DefaultOptions() Options {
+	return Options{
+		MaxAttempts: 6,
+		Backoff:     10 * time.Second,
+		MaxBackoff:  time.Hour,
+		Timeout:     10 * time.Second,
+		LogSize:     100,
+	}
+}
+
+// Service manages webhook subscriptions and delivers events to them. It is an
+// events.Sink: Publish queues a delivery per subscribed webhook, and Run (or
+// DeliverDue) sends them. Subscriptions and deliveries are held in memory.
+type Service struct {
+	client  *http.Client
+	options Options
+
+	mutex      sync.Mutex
+	webhooks   map[string]models.Webhook
+	deliveries map[string]*models.WebhookDelivery
+	log        map[string][]string // delivery IDs by webhook, oldest first
+	inFlight   map[string]bool     // deliveries being attempted right now
+}
+
+// NewService returns a Service sending requests with client
+func NewService(client *http.Client, options Options) *Service {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Service{
+		client:     client,
+		options:    options,
+		webhooks:   make(map[string]models.Webhook),
+		deliveries: make(map[string]*models.WebhookDelivery),
+		log:        make(map[string][]string),
+		inFlight:   make(map[string]bool),
+	}
+}
+
+// List returns the webhooks of the tenant in ctx, oldest first
+func (s *Service) List(ctx context.Context) ([]models.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	webhooks := make([]models.Webhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		if tenancy.Visible(ctx, webhook.Tenant) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+		}
+		return webhooks[i].ID < webhooks[j].ID
+	})
+
+	return webhooks, nil
+}
+
+// Get returns one of the webhooks of the tenant in ctx
+func (s *Service) Get(ctx context.Context, id string) (models.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Webhook{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	webhook, exists := s.webhooks[id]
+	if !exists || !tenancy.Visible(ctx, webhook.Tenant) {
+		return models.Webhook{}, ErrNotFound
+	}
+	return webhook, nil
+}
+
+// Create registers a webhook for the tenant in ctx. A random secret is
+// generated when none is given.
+func (s *Service) Create(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Webhook{}, err
+	}
+
+	if webhook.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return models.Webhook{}, err
+		}
+		webhook.Secret = secret
+	}
+	now := time.Now().UTC()
+	webhook.ID = uuid.New().String()
+	webhook.Tenant = tenancy.FromContext(ctx)
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.webhooks[webhook.ID] = webhook
+	return webhook, nil
+}
+
+// Update replaces a webhook's URL and event types, and its secret when a new
+// one is given. Pending deliveries go to the updated URL.
+func (s *Service) Update(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Webhook{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	existing, exists := s.webhooks[webhook.ID]
+	if !exists || !tenancy.Visible(ctx, existing.Tenant) {
+		return models.Webhook{}, ErrNotFound
+	}
+	existing.URL = webhook.URL
+	existing.EventTypes = webhook.EventTypes
+	if webhook.Secret != "" {
+		existing.Secret = webhook.Secret
+	}
+	existing.UpdatedAt = time.Now().UTC()
+
+	s.webhooks[existing.ID] = existing
+	return existing, nil
+}
+
+// Delete removes a webhook together with its deliveries
+func (s *Service) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.visible(ctx, id) {
+		return ErrNotFound
+	}
+	for _, deliveryID := range s.log[id] {
+		delete(s.deliveries, deliveryID)
+	}
+	delete(s.log, id)
+	delete(s.webhooks, id)
+	return nil
+}
+
+// Deliveries returns a webhook's delivery log, newest first
+func (s *Service) Deliveries(ctx context.Context, id string) ([]models.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.visible(ctx, id) {
+		return nil, ErrNotFound
+	}
+	ids := s.log[id]
+	deliveries := make([]models.WebhookDelivery, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, *s.deliveries[ids[i]])
+	}
+	return deliveries, nil
+}
+
+// DeadLetters returns every dead-lettered delivery across the webhooks of the
+// tenant in ctx, newest first
+func (s *Service) DeadLetters(ctx context.Context) ([]models.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	dead := []models.WebhookDelivery{}
+	for _, delivery := range s.deliveries {
+		if delivery.Status == models.DeliveryDead && s.visible(ctx, delivery.WebhookID) {
+			dead = append(dead, *delivery)
+		}
+	}
+	sort.Slice(dead, func(i, j int) bool {
+		if !dead[i].CreatedAt.Equal(dead[j].CreatedAt) {
+			return dead[i].CreatedAt.After(dead[j].CreatedAt)
+		}
+		return dead[i].ID > dead[j].ID
+	})
+	return dead, nil
+}
+
+// Redeliver moves a dead-lettered delivery back to pending with a fresh set
+// of attempts, due immediately
+func (s *Service) Redeliver(ctx context.Context, deliveryID string) (models.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delivery, exists := s.deliveries[deliveryID]
+	if !exists || !s.visible(ctx, delivery.WebhookID) {
+		return models.WebhookDelivery{}, ErrDeliveryNotFound
+	}
+	if delivery.Status != models.DeliveryDead {
+		return models.WebhookDelivery{}, ErrNotDead
+	}
+
+	now := time.Now().UTC()
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	return *delivery, nil
+}
+
+// Publish queues a delivery of each event to every webhook of the event's
+// tenant that is subscribed to its type. It never fails once events are
+// queued, so a redelivered batch from the dispatcher is queued again;
+// receivers drop duplicates by event ID.
+func (s *Service) Publish(ctx context.Context, events []models.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now().UTC()
+	for _, event := range events {
+		for id, webhook := range s.webhooks {
+			if !webhook.Subscribes(event.Type) || (webhook.Tenant != "" && webhook.Tenant != event.Tenant) {
+				continue
+			}
+			due := now
+			delivery := &models.WebhookDelivery{
+				ID:            uuid.New().String(),
+				WebhookID:     id,
+				Event:         event,
+				Status:        models.DeliveryPending,
+				CreatedAt:     now,
+				NextAttemptAt: &due,
+			}
+			s.deliveries[delivery.ID] = delivery
+			s.log[id] = append(s.log[id], delivery.ID)
+		}
+	}
+
+	return nil
+}
+
+// DeliverDue attempts every pending delivery due at or before now and returns
+// how many were accepted by their receivers. Webhooks are served in parallel,
+// each one's deliveries in order.
+func (s *Service) DeliverDue(ctx context.Context, now time.Time) int {
+	s.mutex.Lock()
+	due := make(map[string][]models.WebhookDelivery)
+	for webhookID, ids := range s.log {
+		for _, id := range ids {
+			delivery := s.deliveries[id]
+			if delivery.Status != models.DeliveryPending || s.inFlight[id] || delivery.NextAttemptAt.After(now) {
+				continue
+			}
+			s.inFlight[id] = true
+			due[webhookID] = append(due[webhookID], *delivery)
+		}
+	}
+	s.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	delivered := 0
+	for _, deliveries := range due {
+		wg.Add(1)
+		go func(deliveries []models.WebhookDelivery) {
+			defer wg.Done()
+			for _, delivery := range deliveries {
+				if s.attempt(ctx, delivery, now) {
+					mutex.Lock()
+					delivered++
+					mutex.Unlock()
+				}
+			}
+		}(deliveries)
+	}
+	wg.Wait()
+
+	return delivered
+}
+
+// Run delivers due events every interval until ctx is done
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.DeliverDue(ctx, time.Now())
+		}
+	}
+}
+
+// visible reports whether webhook id exists and belongs to the tenant in
+// ctx. The caller must hold the lock.
+func (s *Service) visible(ctx context.Context, id string) bool {
+	webhook, exists := s.webhooks[id]
+	return exists && tenancy.Visible(ctx, webhook.Tenant)
+}
+
+// attempt sends one delivery and records the outcome
+func (s *Service) attempt(ctx context.Context, delivery models.WebhookDelivery, now time.Time) bool {
+	s.mutex.Lock()
+	webhook, exists := s.webhooks[delivery.WebhookID]
+	s.mutex.Unlock()
+
+	status, err := 0, error(nil)
+	if exists {
+		status, err = s.send(ctx, webhook, delivery)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.inFlight, delivery.ID)
+	stored, exists := s.deliveries[delivery.ID]
+	if !exists {
+		// The webhook was deleted while the attempt was under way
+		return false
+	}
+
+	attemptedAt := now.UTC()
+	stored.Attempts++
+	stored.LastAttemptAt = &attemptedAt
+	stored.ResponseStatus = status
+	stored.Error = ""
+	switch {
+	case err == nil:
+		stored.Status = models.DeliverySucceeded
+		stored.NextAttemptAt = nil
+		s.trim(delivery.WebhookID)
+		return true
+	case stored.Attempts >= s.options.MaxAttempts:
+		stored.Status = models.DeliveryDead
+		stored.Error = err.Error()
+		stored.NextAttemptAt = nil
+		log.Printf("Warning: webhook %s delivery %s dead-lettered after %d attempts: %v", webhook.ID, stored.ID, stored.Attempts, err)
+	default:
+		stored.Error = err.Error()
+		next := attemptedAt.Add(s.backoff(stored.Attempts))
+		stored.NextAttemptAt = &next
+	}
+	return false
+}
+
+// send POSTs the event to the webhook and returns the response status. Any
+// status outside 2xx is an error.
+func (s *Service) send(ctx context.Context, webhook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.options.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(EventHeader, delivery.Event.Type)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, time.Now(), body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait after the given number of failed attempts
+func (s *Service) backoff(attempts int) time.Duration {
+	wait := s.options.Backoff
+	for i := 1; i < attempts && wait < s.options.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if s.options.MaxBackoff > 0 && wait > s.options.MaxBackoff {
+		wait = s.options.MaxBackoff
+	}
+	return wait
+}
+
+// trim drops a webhook's oldest succeeded deliveries beyond LogSize. Pending
+// and dead deliveries are always kept. The caller must hold the lock.
+func (s *Service) trim(webhookID string) {
+	ids := s.log[webhookID]
+	excess := len(ids) - s.options.LogSize
+	if excess <= 0 {
+		return
+	}
+
+	kept := ids[:0]
+	for _, id := range ids {
+		if excess > 0 && s.deliveries[id].Status == models.DeliverySucceeded {
+			delete(s.deliveries, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.log[webhookID] = kept
+}
+
+// newSecret returns a random signing secret
+func newSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,261 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/tenancy"
+)
+
+// receiver is an httptest webhook endpoint that answers with the queued
+// statuses in turn (200 once they run out) and records what it received
+type receiver struct {
+	*httptest.Server
+	secret string
+
+	mutex    sync.Mutex
+	statuses []int
+	received []models.Event
+	headers  []http.Header
+	invalid  int
+}
+
+func newReceiver(t *testing.T, secret string, statuses ...int) *receiver {
+	r := &receiver{secret: secret, statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		if err := Verify(r.secret, req.Header.Get(SignatureHeader), body, time.Now(), time.Minute); err != nil {
+			r.invalid++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		if status == http.StatusOK {
+			var event models.Event
+			json.Unmarshal(body, &event)
+			r.received = append(r.received, event)
+			r.headers = append(r.headers, req.Header.Clone())
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) events() []models.Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]models.Event(nil), r.received...)
+}
+
+func testEvent(id, eventType string) models.Event {
+	return models.Event{ID: id, Type: eventType, ProductID: "p1", Version: 1, OccurredAt: time.Now().UTC()}
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	secret := "0123456789abcdef"
+	options := Options{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 3 * time.Second, Timeout: time.Second, LogSize: 10}
+
+	// Test that subscribed events are delivered signed and in order
+	t.Run("Deliver", func(t *testing.T) {
+		endpoint := newReceiver(t, secret)
+		service := NewService(endpoint.Client(), options)
+		webhook, err := service.Create(ctx, models.Webhook{URL: endpoint.URL, EventTypes: []string{models.EventPriceChanged, models.EventStockDepleted}, Secret: secret})
+		require.NoError(t, err)
+
+		require.NoError(t, service.Publish(ctx, []models.Event{
+			testEvent("e1", models.EventProductUpdated),
+			testEvent("e2", models.EventPriceChanged),
+			testEvent("e3", models.EventStockDepleted),
+		}))
+		assert.Equal(t, 2, service.DeliverDue(ctx, time.Now()))
+
+		received := endpoint.events()
+		require.Len(t, received, 2)
+		assert.Equal(t, "e2", received[0].ID)
+		assert.Equal(t, "e3", received[1].ID)
+		assert.Equal(t, models.EventPriceChanged, endpoint.headers[0].Get(EventHeader))
+		assert.NotEmpty(t, endpoint.headers[0].Get(DeliveryHeader))
+		assert.Zero(t, endpoint.invalid)
+
+		deliveries, err := service.Deliveries(ctx, webhook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 2)
+		assert.Equal(t, "e3", deliveries[0].Event.ID, "log is newest first")
+		assert.Equal(t, models.DeliverySucceeded, deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, http.StatusOK, deliveries[0].ResponseStatus)
+
+		// Nothing is left to send
+		assert.Zero(t, service.DeliverDue(ctx, time.Now()))
+	})
+
+	// Test that failures are retried with exponential backoff
+	t.Run("Retry", func(t *testing.T) {
+		endpoint := newReceiver(t, secret, http.StatusInternalServerError, http.StatusServiceUnavailable)
+		service := NewService(endpoint.Client(), options)
+		webhook, err := service.Create(ctx, models.Webhook{URL: endpoint.URL, Secret: secret})
+		require.NoError(t, err)
+		require.NoError(t, service.Publish(ctx, []models.Event{testEvent("e1", models.EventProductCreated)}))
+
+		now := time.Now()
+		assert.Zero(t, service.DeliverDue(ctx, now))
+		deliveries, err := service.Deliveries(ctx, webhook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, models.DeliveryPending, deliveries[0].Status)
+		assert.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseStatus)
+		assert.WithinDuration(t, now.Add(time.Second), *deliveries[0].NextAttemptAt, time.Millisecond)
+
+		// Not due again until the backoff has passed, which then doubles
+		assert.Zero(t, service.DeliverDue(ctx, now.Add(500*time.Millisecond)))
+		assert.Zero(t, service.DeliverDue(ctx, now.Add(time.Second)))
+		deliveries, _ = service.Deliveries(ctx, webhook.ID)
+		assert.Equal(t, 2, deliveries[0].Attempts)
+		assert.WithinDuration(t, now.Add(3*time.Second), *deliveries[0].NextAttemptAt, time.Millisecond)
+
+		assert.Equal(t, 1, service.DeliverDue(ctx, now.Add(3*time.Second)))
+		deliveries, _ = service.Deliveries(ctx, webhook.ID)
+		assert.Equal(t, models.DeliverySucceeded, deliveries[0].Status)
+		assert.Equal(t, 3, deliveries[0].Attempts)
+		assert.Len(t, endpoint.events(), 1)
+	})
+
+	// Test that exhausted deliveries are dead-lettered and can be redriven
+	t.Run("DeadLetter", func(t *testing.T) {
+		endpoint := newReceiver(t, secret, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+		service := NewService(endpoint.Client(), options)
+		_, err := service.Create(ctx, models.Webhook{URL: endpoint.URL, Secret: secret})
+		require.NoError(t, err)
+		require.NoError(t, service.Publish(ctx, []models.Event{testEvent("e1", models.EventProductCreated)}))
+
+		now := time.Now()
+		for i := 0; i < options.MaxAttempts; i++ {
+			service.DeliverDue(ctx, now.Add(time.Duration(i)*time.Minute))
+		}
+		dead, err := service.DeadLetters(ctx)
+		require.NoError(t, err)
+		require.Len(t, dead, 1)
+		assert.Equal(t, models.DeliveryDead, dead[0].Status)
+		assert.Equal(t, options.MaxAttempts, dead[0].Attempts)
+		assert.Equal(t, "receiver responded 500", dead[0].Error)
+		assert.Nil(t, dead[0].NextAttemptAt)
+		assert.Zero(t, service.DeliverDue(ctx, now.Add(time.Hour)))
+
+		redriven, err := service.Redeliver(ctx, dead[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliveryPending, redriven.Status)
+		_, err = service.Redeliver(ctx, dead[0].ID)
+		assert.ErrorIs(t, err, ErrNotDead)
+		_, err = service.Redeliver(ctx, "missing")
+		assert.ErrorIs(t, err, ErrDeliveryNotFound)
+
+		assert.Equal(t, 1, service.DeliverDue(ctx, time.Now()))
+		dead, err = service.DeadLetters(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, dead)
+	})
+
+	// Test that a receiver with another secret rejects the signature
+	t.Run("WrongSecret", func(t *testing.T) {
+		endpoint := newReceiver(t, "some-other-secret-value")
+		service := NewService(endpoint.Client(), options)
+		webhook, err := service.Create(ctx, models.Webhook{URL: endpoint.URL})
+		require.NoError(t, err)
+		assert.Len(t, webhook.Secret, 64, "a secret is generated when none is given")
+
+		require.NoError(t, service.Publish(ctx, []models.Event{testEvent("e1", models.EventProductCreated)}))
+		assert.Zero(t, service.DeliverDue(ctx, time.Now()))
+		assert.Equal(t, 1, endpoint.invalid)
+	})
+
+	// Test subscription management
+	t.Run("Manage", func(t *testing.T) {
+		service := NewService(nil, options)
+		first, err := service.Create(ctx, models.Webhook{URL: "https://example.com/a", Secret: secret})
+		require.NoError(t, err)
+		second, err := service.Create(ctx, models.Webhook{URL: "https://example.com/b"})
+		require.NoError(t, err)
+
+		webhooks, err := service.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, webhooks, 2)
+		assert.Equal(t, first.ID, webhooks[0].ID)
+
+		updated, err := service.Update(ctx, models.Webhook{ID: first.ID, URL: "https://example.com/c", EventTypes: []string{models.EventProductDeleted}})
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/c", updated.URL)
+		assert.Equal(t, secret, updated.Secret, "an empty secret keeps the current one")
+
+		require.NoError(t, service.Publish(ctx, []models.Event{testEvent("e1", models.EventProductCreated)}))
+		deliveries, err := service.Deliveries(ctx, first.ID)
+		require.NoError(t, err)
+		assert.Empty(t, deliveries, "filtered out by event type")
+
+		require.NoError(t, service.Delete(ctx, second.ID))
+		_, err = service.Get(ctx, second.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = service.Deliveries(ctx, second.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, service.Delete(ctx, second.ID), ErrNotFound)
+		_, err = service.Update(ctx, models.Webhook{ID: second.ID, URL: "https://example.com/b"})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	// Test that a tenant's webhooks only see and receive that tenant's events
+	t.Run("Tenants", func(t *testing.T) {
+		service := NewService(nil, options)
+		brandA := tenancy.WithTenant(ctx, "brand-a")
+		brandB := tenancy.WithTenant(ctx, "brand-b")
+		webhook, err := service.Create(brandA, models.Webhook{URL: "https://example.com/a", Secret: secret, Tenant: "brand-b"})
+		require.NoError(t, err)
+		assert.Equal(t, "brand-a", webhook.Tenant, "the tenant comes from the context")
+
+		webhooks, err := service.List(brandB)
+		require.NoError(t, err)
+		assert.Empty(t, webhooks)
+		_, err = service.Get(brandB, webhook.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, service.Delete(brandB, webhook.ID), ErrNotFound)
+
+		mine, theirs := testEvent("e1", models.EventProductCreated), testEvent("e2", models.EventProductCreated)
+		mine.Tenant, theirs.Tenant = "brand-a", "brand-b"
+		require.NoError(t, service.Publish(ctx, []models.Event{mine, theirs}))
+		deliveries, err := service.Deliveries(brandA, webhook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, "e1", deliveries[0].Event.ID)
+		_, err = service.Deliveries(brandB, webhook.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"e1"}`)
+	now := time.Now()
+	header := Sign("secret", now, body)
+
+	assert.NoError(t, Verify("secret", header, body, now, time.Minute))
+	assert.ErrorIs(t, Verify("other", header, body, now, time.Minute), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", header, []byte(`{"id":"e2"}`), now, time.Minute), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", header, body, now.Add(time.Hour), time.Minute), ErrInvalidSignature)
+	assert.NoError(t, Verify("secret", header, body, now.Add(time.Hour), 0))
+	assert.ErrorIs(t, Verify("secret", "v1=abc", body, now, 0), ErrInvalidSignature)
+}
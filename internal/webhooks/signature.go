@@ -0,0 +1,71 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	EventHeader     = "X-Webhook-Event"
+)
+
+// ErrInvalidSignature is returned by Verify for a missing, malformed, stale
+// or mismatched signature
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the signature header for body sent at timestamp, in the form
+// "t=<unix seconds>,v1=<hex HMAC-SHA256>". The MAC covers "<t>.<body>" so a
+// captured request cannot be replayed with a fresh timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, mac(secret, t, body))
+}
+
+// Verify checks a signature header produced by Sign against body. Signatures
+// more than tolerance away from now are rejected; a zero tolerance disables
+// the check.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var t, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	seconds, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || signature == "" {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 {
+		age := now.Sub(time.Unix(seconds, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrInvalidSignature
+		}
+	}
+	if !hmac.Equal([]byte(signature), []byte(mac(secret, t, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func mac(secret, t string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(t))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
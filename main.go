@@ -4,16 +4,25 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
+	"github.com/yourusername/product-service/api/graphqlapi"
+	"github.com/yourusername/product-service/api/grpcapi"
 	"github.com/yourusername/product-service/api/handlers"
 	"github.com/yourusername/product-service/api/middleware"
 	_ "github.com/yourusername/product-service/docs"
+	"github.com/yourusername/product-service/internal/apikeys"
 	"github.com/yourusername/product-service/internal/config"
 	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/events"
+	"github.com/yourusername/product-service/internal/tenancy"
+	"github.com/yourusername/product-service/internal/webhooks"
 )
 
 // @title Product Service API
@@ -31,6 +40,16 @@ import (
 // @host localhost:8080
 // @BasePath /
 // @schemes http
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer " followed by a JWT, required when AUTH_ENABLED is true
+
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description An API key minted through /api/keys, accepted instead of a bearer token
 func main() {
 	// Load configuration
 	cfg, err := config.LoadConfig()
@@ -38,43 +57,70 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 	
-	// Initialize in-memory repository for testing
-	repo := database.NewInMemoryRepository()
-	
-	// Add some sample products for testing
-	ctx := context.Background()
-	repo.CreateProduct(ctx, database.SampleProduct("Laptop", "High-performance laptop", 1299.99, 10))
-	repo.CreateProduct(ctx, database.SampleProduct("Smartphone", "Latest smartphone model", 899.99, 15))
-	repo.CreateProduct(ctx, database.SampleProduct("Headphones", "Noise-cancelling headphones", 249.99, 20))
+	// Initialize the repository selected by STORAGE_BACKEND
+	repo, err := database.NewRepository(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	log.Printf("Using %s storage backend", cfg.StorageBackend)
 	
-	// Initialize handlers
-	productHandler := handlers.NewProductHandler(repo)
+	// Add some sample products only when explicitly requested, in each tenant's catalogue
+	if cfg.SeedSampleData {
+		seedContexts := []context.Context{context.Background()}
+		if len(cfg.Tenants) > 0 {
+			seedContexts = seedContexts[:0]
+			for _, tenant := range cfg.Tenants {
+				seedContexts = append(seedContexts, tenancy.WithTenant(context.Background(), tenant))
+			}
+		}
+		for _, ctx := range seedContexts {
+			if err := database.SeedSampleProducts(ctx, repo); err != nil {
+				log.Fatalf("Failed to seed sample products: %v", err)
+			}
+		}
+	}
 	
-	// Set up Gin router
-	router := gin.Default()
+	// Release reservations whose hold has lapsed and purge the trash
+	go database.RunReservationReaper(context.Background(), repo, cfg.ReservationReapInterval)
+	go database.RunTrashPurger(context.Background(), repo, cfg.TrashPurgeInterval, cfg.TrashRetention)
 	
-	// Add middleware
-	router.Use(middleware.Logger())
+	// Deliver domain events from the outbox to the log, webhooks and the change feed
+	options := webhooks.DefaultOptions()
+	options.MaxAttempts = cfg.WebhookMaxAttempts
+	options.Backoff = cfg.WebhookRetryBackoff
+	options.Timeout = cfg.WebhookTimeout
+	hooks := webhooks.NewService(&http.Client{}, options)
+	feed := events.NewFeed(cfg.ChangeFeedBuffer)
+	dispatcher := events.NewDispatcher(repo, events.MultiSink{events.LogSink{}, hooks, feed}, cfg.EventBatchSize, cfg.EventDispatchInterval)
+	go dispatcher.Run(context.Background())
+	go hooks.Run(context.Background(), cfg.EventDispatchInterval)
 	
-	// Health check endpoint
-	router.GET("/health", middleware.HealthCheck())
-	
-	// API routes
-	api := router.Group("/api")
-	{
-		products := api.Group("/products")
-		{
-			products.GET("", productHandler.GetProducts)
-			products.GET("/:id", productHandler.GetProductByID)
-			products.POST("", productHandler.CreateProduct)
-			products.PUT("/:id", productHandler.UpdateProduct)
-			products.DELETE("/:id", productHandler.DeleteProduct)
-			products.GET("/:id/availability", productHandler.CheckProductAvailability)
+	// Require bearer tokens or API keys on the API when enabled
+	keys := apikeys.NewService(apikeys.NewMemoryStore())
+	var authenticator *middleware.Authenticator
+	if cfg.AuthEnabled {
+		authenticator, err = middleware.NewAuthenticator(middleware.AuthOptions{
+			HMACSecret: cfg.JWTSecret,
+			JWKSFile: cfg.JWKSFile,
+			Issuer: cfg.JWTIssuer,
+			Audience: cfg.JWTAudience,
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize authentication: %v", err)
 		}
+	} else {
+		log.Printf("Authentication is disabled; set AUTH_ENABLED to require bearer tokens")
 	}
 	
-	// Swagger documentation
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// Serve the gRPC API on its own port, with the same credentials as REST
+	go serveGRPC(repo, feed, cfg.GRPCPort, grpcapi.Options{
+		Authenticator: authenticator,
+		Keys: keys,
+		Tenants: cfg.Tenants,
+	})
+	
+	// Set up the router, middleware and routes
+	router := GetGinEngine(repo, cfg, hooks, feed, authenticator, keys)
 	
 	// Start server
 	serverAddr := fmt.Sprintf(":%d", cfg.ServerPort)
@@ -84,32 +130,144 @@ func main() {
 	}
 }
 
-// GetGinEngine configures and returns a new Gin engine
-func GetGinEngine(repo database.ProductRepository) *gin.Engine {
+// serveGRPC serves the gRPC API, with health and reflection, on port
+func serveGRPC(repo database.ProductRepository, feed *events.Feed, port int, options grpcapi.Options) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC: %v", err)
+	}
+	
+	log.Printf("Starting gRPC server on %s", listener.Addr())
+	if err := grpcapi.NewGRPCServer(repo, feed, options).Serve(listener); err != nil {
+		log.Fatalf("Failed to start gRPC server: %v", err)
+	}
+}
+
+// GetGinEngine configures and returns a new Gin engine. With a nil
+// authenticator the API is anonymous and role checks are skipped; API keys
+// are only accepted alongside an authenticator.
+func GetGinEngine(repo database.ProductRepository, cfg *config.Config, hooks *webhooks.Service, feed *events.Feed, authenticator *middleware.Authenticator, keys *apikeys.Service) *gin.Engine {
 	// Initialize handlers
 	productHandler := handlers.NewProductHandler(repo)
+	webhookHandler := handlers.NewWebhookHandler(hooks)
+	changeFeedHandler := handlers.NewChangeFeedHandler(feed)
+	apiKeyHandler := handlers.NewAPIKeyHandler(keys)
+	graphqlHandler := graphqlapi.NewHandler(repo, graphqlapi.Limits{
+		MaxComplexity: cfg.GraphQLMaxComplexity,
+		MaxDepth: cfg.GraphQLMaxDepth,
+	})
 	
 	// Set up Gin router
-	router := gin.Default()
+	router := gin.New()
 	
-	// Add middleware
+	// Add middleware; request IDs come first so every log line carries one,
+	// and panics are recovered inside Logger so their 500s are logged too
+	router.Use(middleware.RequestID())
 	router.Use(middleware.Logger())
+	router.Use(middleware.Recovery())
 	
 	// Health check endpoint
 	router.GET("/health", middleware.HealthCheck())
 	
 	// API routes
+	// The change feed is a long-lived stream, so it is unbounded unless configured
+	timeouts := map[string]time.Duration{"GET /api/products/changes": 0}
+	for route, timeout := range cfg.RouteTimeouts {
+		timeouts[route] = timeout
+	}
+	
+	// Every API call gets a deadline, an actor and, when enabled, a verified caller
+	apiMiddleware := []gin.HandlerFunc{middleware.Timeout(cfg.RequestTimeout, timeouts), middleware.Actor()}
+	if authenticator != nil {
+		apiMiddleware = append(apiMiddleware, middleware.Authenticate(authenticator, keys))
+	}
+	
+	// Budget each client's reads and writes, by API key, token subject or IP
+	apiMiddleware = append(apiMiddleware, middleware.RateLimit(middleware.RateLimitOptions{
+		Read: middleware.Limit{Requests: cfg.RateLimitRead, Window: cfg.RateLimitWindow},
+		Write: middleware.Limit{Requests: cfg.RateLimitWrite, Window: cfg.RateLimitWindow},
+		Store: middleware.NewMemoryLimiterStore(),
+	}))
+	
+	// Scope every API call to one catalogue when serving several tenants
+	if len(cfg.Tenants) > 0 {
+		apiMiddleware = append(apiMiddleware, middleware.Tenant(middleware.TenantOptions{
+			Tenants: cfg.Tenants,
+			Domain: cfg.TenantDomain,
+		}))
+	}
+	
+	// Let clients retry POSTs safely with an Idempotency-Key
+	apiMiddleware = append(apiMiddleware, middleware.Idempotency(middleware.IdempotencyOptions{
+		TTL: cfg.IdempotencyKeyTTL,
+		Store: middleware.NewMemoryIdempotencyStore(),
+	}))
+	
+	// Roles required by each route
+	read := middleware.RequireRole(middleware.RoleCatalogRead)
+	write := middleware.RequireRole(middleware.RoleCatalogWrite)
+	inventory := middleware.RequireRole(middleware.RoleInventoryWrite)
+	admin := middleware.RequireRole(middleware.RoleAdmin)
+	
 	api := router.Group("/api")
+	api.Use(apiMiddleware...)
 	{
+		// Custom methods on the collection, e.g. POST /api/products:batch
+		api.POST("/products:method", write, handlers.CustomMethods(map[string]gin.HandlerFunc{
+			":batch": productHandler.BulkWrite,
+		}))
+		
 		products := api.Group("/products")
 		{
-			products.GET("", productHandler.GetProducts)
-			products.GET("/:id", productHandler.GetProductByID)
-			products.POST("", productHandler.CreateProduct)
-			products.PUT("/:id", productHandler.UpdateProduct)
-			products.DELETE("/:id", productHandler.DeleteProduct)
-			products.GET("/:id/availability", productHandler.CheckProductAvailability)
+			products.GET("", read, productHandler.GetProducts)
+			products.GET("/trash", read, productHandler.GetTrash)
+			products.GET("/changes", read, changeFeedHandler.StreamChanges)
+			products.GET("/export", read, productHandler.ExportProducts)
+			products.POST("/import", write, productHandler.ImportProducts)
+			products.GET("/:id", read, productHandler.GetProductByID)
+			products.POST("", write, productHandler.CreateProduct)
+			products.PUT("/:id", write, productHandler.UpdateProduct)
+			products.PATCH("/:id", write, productHandler.PatchProduct)
+			products.DELETE("/:id", write, productHandler.DeleteProduct)
+			products.POST("/:id/restore", write, productHandler.UndeleteProduct)
+			products.GET("/:id/availability", read, productHandler.CheckProductAvailability)
+			products.GET("/:id/history", read, productHandler.GetProductHistory)
+			products.POST("/:id/history/:seq/restore", write, productHandler.RestoreProduct)
+			products.POST("/:id/inventory/adjustments", inventory, productHandler.AdjustInventory)
+			products.POST("/:id/reservations", inventory, productHandler.ReserveInventory)
+			products.GET("/:id/reservations/:reservationId", read, productHandler.GetReservation)
+			products.POST("/:id/reservations/:reservationId/confirm", inventory, productHandler.ConfirmReservation)
+			products.POST("/:id/reservations/:reservationId/release", inventory, productHandler.ReleaseReservation)
+		}
+		
+		webhookRoutes := api.Group("/webhooks", admin)
+		{
+			webhookRoutes.GET("", webhookHandler.GetWebhooks)
+			webhookRoutes.POST("", webhookHandler.CreateWebhook)
+			webhookRoutes.GET("/dead-letters", webhookHandler.GetDeadLetters)
+			webhookRoutes.POST("/dead-letters/:deliveryId/retry", webhookHandler.RetryDeadLetter)
+			webhookRoutes.GET("/:id", webhookHandler.GetWebhook)
+			webhookRoutes.PUT("/:id", webhookHandler.UpdateWebhook)
+			webhookRoutes.DELETE("/:id", webhookHandler.DeleteWebhook)
+			webhookRoutes.GET("/:id/deliveries", webhookHandler.GetWebhookDeliveries)
 		}
+		
+		keyRoutes := api.Group("/keys", admin)
+		{
+			keyRoutes.GET("", apiKeyHandler.GetAPIKeys)
+			keyRoutes.POST("", apiKeyHandler.CreateAPIKey)
+			keyRoutes.GET("/:id", apiKeyHandler.GetAPIKey)
+			keyRoutes.POST("/:id/rotate", apiKeyHandler.RotateAPIKey)
+			keyRoutes.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+		}
+	}
+	
+	// GraphQL shares the API's middleware; mutations also need catalog:write
+	graphql := router.Group("/graphql")
+	graphql.Use(apiMiddleware...)
+	{
+		graphql.GET("", read, graphqlHandler.Serve)
+		graphql.POST("", read, graphqlHandler.Serve)
 	}
 	
 	// Swagger documentation
@@ -2,17 +2,29 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/product-service/api/middleware"
+	"github.com/yourusername/product-service/internal/apikeys"
+	"github.com/yourusername/product-service/internal/config"
 	"github.com/yourusername/product-service/internal/database"
+	"github.com/yourusername/product-service/internal/events"
+	"github.com/yourusername/product-service/internal/models"
+	"github.com/yourusername/product-service/internal/webhooks"
 )
 
 func TestGetGinEngine(t *testing.T) {
 	repo := database.NewInMemoryRepository()
-	router := GetGinEngine(repo)
+	router := GetGinEngine(repo, config.Default(), webhooks.NewService(nil, webhooks.DefaultOptions()), events.NewFeed(10), nil, apikeys.NewService(apikeys.NewMemoryStore()))
 
 	// Test health check endpoint
 	t.Run("HealthCheck", func(t *testing.T) {
@@ -22,6 +34,72 @@ func TestGetGinEngine(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "UP")
+		assert.NotEmpty(t, w.Header().Get(middleware.RequestIDHeader))
+	})
+
+	// Test that custom methods are routed next to the collection
+	t.Run("CustomMethod", func(t *testing.T) {
+		body := strings.NewReader(`{"operations":[{"op":"create","product":{"name":"Routed","description":"Test Description","price":1,"inventoryCount":1}}]}`)
+		req, _ := http.NewRequest(http.MethodPost, "/api/products:batch", body)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMultiStatus, w.Code)
+	})
+
+	// Test that GraphQL is served with the actor attributed
+	t.Run("GraphQL", func(t *testing.T) {
+		body := strings.NewReader(`{"query":"mutation { createProduct(input: {name: \"Graphed\", description: \"Test Description\", price: 1, inventoryCount: 1}) { id } }"}`)
+		req, _ := http.NewRequest(http.MethodPost, "/graphql", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Actor", "graphql-tester")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"createProduct"`)
+	})
+
+	// Test that the API is rate limited and the health check is not
+	t.Run("RateLimit", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/api/products", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "1200", w.Header().Get("RateLimit-Limit"))
+
+		req, _ = http.NewRequest(http.MethodGet, "/health", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	})
+
+	// Test that a retried create with an Idempotency-Key adds one product
+	t.Run("Idempotency", func(t *testing.T) {
+		create := func() *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(http.MethodPost, "/api/products", strings.NewReader(`{"name":"Retried","description":"Test Description","price":1,"inventoryCount":1}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(middleware.IdempotencyKeyHeader, "retried-create")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+		first := create()
+		assert.Equal(t, http.StatusCreated, first.Code)
+		retry := create()
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+
+		products, err := repo.GetProducts(context.Background())
+		require.NoError(t, err)
+		retried := 0
+		for _, product := range products {
+			if product.Name == "Retried" {
+				retried++
+			}
+		}
+		assert.Equal(t, 1, retried)
 	})
 
 	// Test not found route
@@ -34,3 +112,132 @@ func TestGetGinEngine(t *testing.T) {
 	})
 
 }
+
+func TestGetGinEngineAuth(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	authenticator, err := middleware.NewAuthenticator(middleware.AuthOptions{HMACSecret: secret})
+	require.NoError(t, err)
+
+	repo := database.NewInMemoryRepository()
+	keys := apikeys.NewService(apikeys.NewMemoryStore())
+	router := GetGinEngine(repo, config.Default(), webhooks.NewService(nil, webhooks.DefaultOptions()), events.NewFeed(10), authenticator, keys)
+	product, err := repo.CreateProduct(context.Background(), models.Product{Name: "Guarded", Description: "Test Description", Price: 1.0, InventoryCount: 1})
+	require.NoError(t, err)
+
+	token := func(roles ...string) string {
+		claims := middleware.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "alice", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+			Roles:            roles,
+		}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return signed
+	}
+	do := func(method, path, token, body string) int {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Test that the health check stays public and the API does not
+	t.Run("Anonymous", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/health", "", ""))
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/products", "", ""))
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/graphql", "", `{"query":"{ products { nextCursor } }"}`))
+	})
+
+	// Test the role each kind of route requires
+	t.Run("Roles", func(t *testing.T) {
+		reader := token(middleware.RoleCatalogRead)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/products/"+product.ID, reader, ""))
+		assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/api/products/"+product.ID, reader, ""))
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/products/"+product.ID+"/inventory/adjustments", token(middleware.RoleCatalogWrite), `{"delta":1,"reason":"restock"}`))
+		assert.Equal(t, http.StatusOK, do(http.MethodPost, "/api/products/"+product.ID+"/inventory/adjustments", token(middleware.RoleInventoryWrite), `{"delta":1,"reason":"restock"}`))
+		assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/webhooks", token(middleware.RoleCatalogWrite), ""))
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/webhooks", token(middleware.RoleAdmin), ""))
+		assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/products/"+product.ID, token(middleware.RoleCatalogWrite), ""))
+	})
+
+	// Test that GraphQL mutations need catalog:write
+	t.Run("GraphQL", func(t *testing.T) {
+		mutation := `{"query":"mutation { deleteProduct(id: \"x\") }"}`
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/graphql", token(middleware.RoleCatalogRead), mutation))
+		assert.Equal(t, http.StatusOK, do(http.MethodPost, "/graphql", token(middleware.RoleCatalogRead), `{"query":"{ products { nextCursor } }"}`))
+	})
+
+	// Test that API keys are minted by admins and grant their scope's roles
+	t.Run("APIKeys", func(t *testing.T) {
+		withKey := func(method, path, key, body string) int {
+			req, _ := http.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(middleware.APIKeyHeader, key)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/keys", token(middleware.RoleCatalogWrite), `{"name":"orders","scope":"inventory-only"}`))
+		assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/keys", token(middleware.RoleAdmin), `{"name":"orders","scope":"inventory-only"}`))
+
+		key, err := keys.Mint(context.Background(), models.APIKeyRequest{Name: "orders", Scope: models.APIKeyScopeInventory})
+		require.NoError(t, err)
+		other, err := repo.CreateProduct(context.Background(), models.Product{Name: "Keyed", Description: "Test Description", Price: 1.0, InventoryCount: 1})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, withKey(http.MethodGet, "/api/products/"+other.ID, key.Key, ""))
+		assert.Equal(t, http.StatusOK, withKey(http.MethodPost, "/api/products/"+other.ID+"/inventory/adjustments", key.Key, `{"delta":1,"reason":"restock"}`))
+		assert.Equal(t, http.StatusForbidden, withKey(http.MethodDelete, "/api/products/"+other.ID, key.Key, ""))
+		assert.Equal(t, http.StatusForbidden, withKey(http.MethodGet, "/api/keys", key.Key, ""))
+		assert.Equal(t, http.StatusUnauthorized, withKey(http.MethodGet, "/api/products", "pk_unknown", ""))
+
+		_, err = keys.Revoke(context.Background(), key.ID)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, withKey(http.MethodGet, "/api/products", key.Key, ""))
+	})
+}
+
+func TestGetGinEngineTenants(t *testing.T) {
+	cfg := config.Default()
+	cfg.Tenants = []string{"brand-a", "brand-b"}
+	cfg.TenantProductQuotas = map[string]int{"brand-a": 1}
+	repo, err := database.NewRepository(cfg)
+	require.NoError(t, err)
+	router := GetGinEngine(repo, cfg, webhooks.NewService(nil, webhooks.DefaultOptions()), events.NewFeed(10), nil, apikeys.NewService(apikeys.NewMemoryStore()))
+
+	do := func(method, path, tenant, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if tenant != "" {
+			req.Header.Set(middleware.TenantHeader, tenant)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	product := `{"name":"Scoped","description":"Test Description","price":1.0,"inventoryCount":1}`
+
+	// Test that each tenant sees only its own catalogue
+	t.Run("Isolation", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/products", "brand-b", product)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var created models.Product
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		location := "/api/products/" + created.ID
+
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, location, "brand-b", "").Code)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, location, "brand-a", "").Code)
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/products", "", "").Code)
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/products", "brand-c", "").Code)
+	})
+
+	// Test that a tenant past its quota cannot add products
+	t.Run("Quota", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/products", "brand-a", product).Code)
+		w := do(http.MethodPost, "/api/products", "brand-a", product)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "quota_exceeded")
+	})
+}